@@ -10,10 +10,17 @@ import (
 	"personal-analytics-backend/internal/config"
 	"personal-analytics-backend/internal/db"
 	"personal-analytics-backend/internal/handlers"
+	"personal-analytics-backend/internal/jwtkeys"
 	"personal-analytics-backend/internal/logger"
+	"personal-analytics-backend/internal/mail"
+	"personal-analytics-backend/internal/models"
 	"personal-analytics-backend/internal/redis"
 	"personal-analytics-backend/internal/worker"
 
+	// Time zone database built into the binary (~450KB): user time zones (PATCH /me)
+	// load even in containers without /usr/share/zoneinfo
+	_ "time/tzdata"
+
 	"github.com/joho/godotenv"
 )
 
@@ -23,9 +30,9 @@ import (
 //       ↓
 // Struct defines shape (CreateEntryRequest)
 //       ↓
-// DB function saves it (db.go - InsertEntry)
+// Repository saves it (db/entries.go - SQLEntryRepository.Create)
 //       ↓
-// Table schema stores it (db.go - createTables)
+// Table schema stores it (db/migrations/*.sql)
 
 /*
 	ResponseWrite is interface defines 2 function Write() and WriteHeader()
@@ -58,7 +65,19 @@ func main() {
 		os.Exit(1)
 	}
 
-	slog.Info("Configuration loaded", "port", cfg.Port, "log_level", cfg.LogLevel)
+	slog.Info("Configuration loaded", "port", cfg.Port, "log_level", cfg.LogLevel, "db_driver", cfg.DBDriver)
+
+	// "migrate" subcommand: go run ./cmd/server migrate [up | down [n] | status]
+	// Manages the schema and exits without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, os.Args[2:]))
+	}
+
+	// "role" subcommand: go run ./cmd/server role <email> <user|admin>
+	// The only way to make the first admin
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		os.Exit(runRole(cfg, os.Args[2:]))
+	}
 
 	// Apply rate limit configuration to handlers package
 	handlers.RateLimitRequests = cfg.RateLimitRequests
@@ -70,17 +89,56 @@ func main() {
 	// Apply request timeout configuration
 	handlers.RequestTimeout = cfg.RequestTimeout
 
-	// Initialize database
+	// Apply token lifetimes (short access token, long refresh token)
+	handlers.AccessTokenTTL = cfg.AccessTokenTTL
+	handlers.RefreshTokenTTL = cfg.RefreshTokenTTL
+
+	// Apply login lockout configuration (brute-force protection per email and per IP)
+	handlers.LoginMaxFailures = cfg.LoginMaxFailures
+	handlers.LoginMaxFailuresPerIP = cfg.LoginMaxFailuresPerIP
+	handlers.LoginLockoutBase = cfg.LoginLockoutBase
+	handlers.LoginLockoutMax = cfg.LoginLockoutMax
+
+	// Apply email settings: links in mails, their lifetimes, and who delivers the mails
+	handlers.AppBaseURL = cfg.AppBaseURL
+	handlers.VerifyTokenTTL = cfg.VerifyTokenTTL
+	handlers.ResetTokenTTL = cfg.ResetTokenTTL
+	mail.Default, err = mail.NewSender(cfg.MailSender, cfg.MailDir)
+	if err != nil {
+		slog.Error("Failed to set up mail sender", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Mail sender configured", "sender", cfg.MailSender, "base_url", cfg.AppBaseURL)
+
+	// Load JWT signing keys once (JWT_KEYS for rotation, otherwise the single JWT_SECRET)
+	if cfg.JWTKeys != "" {
+		handlers.SigningKeys, err = jwtkeys.Load(cfg.JWTKeys, cfg.JWTKeyGrace)
+	} else {
+		handlers.SigningKeys, err = jwtkeys.FromSecret(cfg.JWTSecret)
+	}
+	if err != nil {
+		slog.Error("Failed to load JWT signing keys", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("JWT signing keys loaded", "active_kid", handlers.SigningKeys.ActiveKID())
+
+	// Initialize database (also applies pending schema migrations)
 	// dbPath := os.Getenv("DB_PATH")
 	// if dbPath == "" {
 	// 	dbPath = "./data.db"
 	// }
-	err = db.InitDB(cfg.DBPath)
+	conn, err := db.InitDB(cfg.DBDriver, cfg.DataSource())
 	if err != nil {
 		slog.Error("Failed to initialize database", "error", err)
 		os.Exit(1)
 	}
 
+	// Handlers get the repositories injected instead of reaching for a global DB
+	// Same repositories for SQLite and Postgres - the connection knows its SQL dialect
+	entries := db.NewSQLEntryRepository(conn)
+	h := handlers.New(entries, db.NewSQLUserRepository(conn), db.NewSQLRefreshTokenRepository(conn),
+		db.NewSQLUserTokenRepository(conn), db.NewSQLAPIKeyRepository(conn), conn)
+
 	err = redis.InitRedis(cfg.RedisAddr)
 	if err != nil {
 		slog.Error("Failed to connect to Redis", "error", err)
@@ -88,11 +146,25 @@ func main() {
 	}
 	defer redis.CloseRedis()
 
-	// Start background worker pool (3 workers)
-	worker.StartWorkerPool(cfg.WorkerPoolSize)
+	// Start background worker pool (3 workers) on the durable job queue in the database
+	worker.Store = db.NewSQLJobRepository(conn)
+	worker.MaxAttempts = cfg.JobMaxAttempts
+	worker.VisibilityTimeout = cfg.JobVisibilityTimeout
+	h.RegisterJobs() // the job types and how each may run (handlers/jobs.go)
+
+	// Recurring jobs (trash and job purges), fired by one server at a time (worker/scheduler.go)
+	scheduler := worker.NewScheduler(db.NewSQLScheduleRepository(conn))
+	if err := h.ScheduleJobs(scheduler, cfg.TrashRetention, cfg.TrashPurgeInterval); err != nil {
+		slog.Error("Invalid job schedule", "error", err)
+		os.Exit(1)
+	}
+
+	workerPool := worker.NewWorkerPool(cfg.WorkerPoolSize)
+	workerPool.Start(context.Background())
+	scheduler.Start(context.Background())
 
 	// The "Defer" Magic: defer is a Go keyword that says: "Wait until this entire function (main) is finished, then immediately run this command."
-	defer db.CloseDB()
+	defer db.CloseDB(conn)
 
 	// As functions are values(First class citizens) you can pass a function just
 	// like another function just like you pass int or string
@@ -103,7 +175,7 @@ func main() {
 	// if a request comes in for the path run the function
 
 	// Middleware chain order (outside → inside):
-	// RequestID → Timeout → Metrics → RateLimit → Logging → [Auth] → Handler
+	// RequestID → Timeout → Metrics → RateLimit → Logging → [Auth] → [RequireRole / RequireScope / SessionOnly] → Handler
 	//
 	// Why this order?
 	// 1. RequestID first: all downstream logs include request_id
@@ -111,37 +183,167 @@ func main() {
 	// 3. Metrics third: tracks all requests including timeouts
 	// 4. RateLimit: prevents abuse before doing expensive work
 	// 5. Logging: logs request details
-	// 6. Auth: validates JWT (only on protected routes)
+	// 6. Auth: validates the JWT or API key (only on protected routes)
+	// 7. RequireRole: checks the role from the JWT (only on admin routes)
+	//    RequireScope: checks an API key's scopes (JWTs pass), see handlers/apikeys.go
+	//    SessionOnly: rejects API keys (managing keys, logging out everywhere, /me)
 
-	http.HandleFunc("/health", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(handlers.HealthHandler))))))
+	http.HandleFunc("/health", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(h.HealthHandler))))))
 	http.HandleFunc("/ping", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(handlers.PingHandler))))))
 
 	// Auth endpoints (no protection needed)
-	http.HandleFunc("/register", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(handlers.Register))))))
-	http.HandleFunc("/login", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(handlers.Login))))))
+	http.HandleFunc("/register", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(h.Register))))))
+	http.HandleFunc("/login", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(h.Login))))))
+
+	// Email verification and password reset: the token from the mail is the credential
+	http.HandleFunc("/verify", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(h.VerifyEmail))))))
+	http.HandleFunc("/password/forgot", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(h.ForgotPassword))))))
+	http.HandleFunc("/password/reset", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(h.ResetPassword))))))
+
+	// Public keys for verifying our tokens (JWKS), no auth: they are public by design
+	http.HandleFunc("/.well-known/jwks.json", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(handlers.JWKSHandler))))))
 
-	// Entries endpoints (PROTECTED - requires authentication)
+	// Refresh token endpoints: authenticated by the refresh token in the body, not by a JWT
+	http.HandleFunc("/token/refresh", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(h.RefreshToken))))))
+	http.HandleFunc("/logout", handlers.RequestIDMiddleware(handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(h.Logout))))))
+
+	// POST /logout/all - revoke every session of the user (PROTECTED: needs a valid access token)
+	http.HandleFunc("/logout/all", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.SessionOnly(h.LogoutAll))))))))
+
+	// API keys for scripts (PROTECTED, and only with a real login - a key can't make keys)
+	http.HandleFunc("/apikeys", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.SessionOnly(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					// POST /apikeys - create a key (shown once)
+					h.CreateAPIKey(w, r)
+				} else if r.Method == http.MethodGet {
+					// GET /apikeys - list keys (never the key itself)
+					h.ListAPIKeys(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+			}))))))))
+	http.HandleFunc("/apikeys/{id}", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.SessionOnly(h.RevokeAPIKey))))))))
+
+	// The user's own account (PROTECTED, no API keys): profile, password, GDPR export and deletion
+	http.HandleFunc("/me", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.SessionOnly(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					// GET /me - profile and preferences
+					h.GetProfile(w, r)
+				} else if r.Method == http.MethodPatch {
+					// PATCH /me - change display name, timezone, week start, mood labels
+					h.UpdateProfile(w, r)
+				} else if r.Method == http.MethodDelete {
+					// DELETE /me - delete the account for good
+					h.DeleteAccount(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+			}))))))))
+	http.HandleFunc("/me/password", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.SessionOnly(h.ChangePassword))))))))
+	http.HandleFunc("/me/export", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.SessionOnly(h.ExportAccount))))))))
+
+	// Entries endpoints (PROTECTED - requires authentication; API keys need the "entries:*" scope)
 	http.HandleFunc("/entries", handlers.RequestIDMiddleware(
 		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
-			handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			h.AuthMiddleware(handlers.RequireScope("entries")(func(w http.ResponseWriter, r *http.Request) {
 				if r.Method == http.MethodPost {
-					handlers.CreateEntry(w, r)
+					h.CreateEntry(w, r)
 				} else if r.Method == http.MethodGet {
-					handlers.GetEntries(w, r)
+					h.GetEntries(w, r)
 				} else if r.Method == http.MethodPatch {
 					// PATCH /entries?id=5 - Update an entry
-					handlers.UpdateEntry(w, r)
+					h.UpdateEntry(w, r)
 				} else if r.Method == http.MethodDelete {
-					// Delete /entries?id=5 - Delete an entry
-					handlers.DeleteEntry(w, r)
+					// Delete /entries?id=5 - Move an entry to the trash
+					h.DeleteEntry(w, r)
 				} else {
 					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 				}
-			})))))))
+			}))))))))
+
+	// GET /entries/trash - deleted entries that can still be restored (PROTECTED)
+	http.HandleFunc("/entries/trash", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireScope("entries")(h.ListTrash))))))))
+
+	// POST /entries/restore?id=5 - take an entry out of the trash (PROTECTED)
+	http.HandleFunc("/entries/restore", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireScope("entries")(h.RestoreEntry))))))))
+
+	// GET /entries/5/history - earlier versions of an entry (PROTECTED)
+	// {id} is a path wildcard, the handler reads it with r.PathValue("id")
+	http.HandleFunc("/entries/{id}/history", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireScope("entries")(h.GetEntryHistory))))))))
+
+	// GET /entries/search?q= - full-text search (PROTECTED)
+	http.HandleFunc("/entries/search", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireScope("entries")(h.SearchEntries))))))))
+
+	// GET /tags - the user's tags with usage counts (PROTECTED)
+	http.HandleFunc("/tags", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireScope("entries")(h.GetTags))))))))
+
+	// Analytics endpoints (PROTECTED) - read-only aggregations over entries ("analytics:read" for API keys)
+	http.HandleFunc("/analytics/mood", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireScope("analytics")(h.GetMoodAnalytics))))))))
+	http.HandleFunc("/analytics/categories", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireScope("analytics")(h.GetCategoryAnalytics))))))))
 
+	// ADMIN ONLY: AuthMiddleware (who?) then RequireRole (allowed?), see handlers/roles.go
 	http.HandleFunc("/metrics", handlers.RequestIDMiddleware(
-		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(
-			handlers.LoggingMiddleware(handlers.GetMetrics))))))
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(handlers.GetMetrics))))))))
+
+	// Admin API (ADMIN ONLY) - user management, system stats and the dead-letter queue
+	http.HandleFunc("/admin/users", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(h.AdminListUsers))))))))
+	http.HandleFunc("/admin/users/{id}/disable", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(h.AdminDisableUser))))))))
+	http.HandleFunc("/admin/users/{id}/enable", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(h.AdminEnableUser))))))))
+	http.HandleFunc("/admin/stats", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(h.AdminStats))))))))
+	http.HandleFunc("/admin/jobs/failed", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(h.AdminListFailedJobs))))))))
+	http.HandleFunc("/admin/jobs/failed/{id}", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					// GET /admin/jobs/failed/{id} - payload and error history
+					h.AdminGetFailedJob(w, r)
+				} else if r.Method == http.MethodDelete {
+					// DELETE /admin/jobs/failed/{id} - discard
+					h.AdminDiscardFailedJob(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+			}))))))))
+	http.HandleFunc("/admin/jobs/failed/{id}/replay", handlers.RequestIDMiddleware(
+		handlers.TimeoutMiddleware(handlers.MetricsMiddleware(handlers.RateLimitMiddleware(handlers.LoggingMiddleware(
+			h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(h.AdminReplayFailedJob))))))))
 
 	// ========================================
 	// GRACEFUL SHUTDOWN IMPLEMENTATION
@@ -189,7 +391,16 @@ func main() {
 		slog.Error("Server shutdown error", "error", err)
 	}
 
-	// STEP 8: Stop background goroutines and close connections
+	// STEP 8: Let the workers finish their jobs - AFTER the HTTP server, because
+	// its last requests may still add jobs, and after the scheduler (same reason).
+	// Same deadline: the whole shutdown takes at most SHUTDOWN_TIMEOUT (then the
+	// process manager kills us anyway).
+	scheduler.Stop() // releases the lease: another server fires the schedules right away
+	if dropped, err := workerPool.Shutdown(ctx); err != nil || dropped > 0 {
+		slog.Warn("Worker pool did not stop cleanly", "dropped_jobs", dropped, "error", err)
+	}
+
+	// STEP 9: Stop background goroutines and close connections
 	cache.AppCache.StopCleanup() // Stop cache cleanup goroutine
 	slog.Info("Closing connections", "redis", "closing", "database", "closing")
 	slog.Info("Server stopped gracefully")
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"personal-analytics-backend/internal/config"
+	"personal-analytics-backend/internal/db"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `Usage: server migrate <command>
+
+Commands:
+  up          apply all pending migrations
+  down [n]    roll back the last n applied migrations (default 1)
+  status      list migrations and whether they are applied`
+
+// runMigrate handles "server migrate ..." and returns the process exit code
+// It connects to the database WITHOUT auto-applying migrations (db.Open, not db.InitDB)
+func runMigrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	conn, err := db.Open(cfg.DBDriver, cfg.DataSource())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open database:", err)
+		return 1
+	}
+	defer db.CloseDB(conn)
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(conn)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Migration failed:", err)
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+
+		// Left by 0014_normalize_user_emails: same address up to case/spaces
+		ids, err := db.UnnormalizedEmailUserIDs(context.Background(), conn)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to check user emails:", err)
+			return 1
+		}
+		if len(ids) > 0 {
+			fmt.Printf("Accounts whose email could not be normalized (merge them): %v\n", ids)
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, "down: n must be a positive number")
+				return 2
+			}
+			steps = n
+		}
+
+		rolledBack, err := db.MigrateDown(conn, steps)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Rollback failed:", err)
+			return 1
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", rolledBack)
+
+	case "status":
+		statuses, err := db.GetMigrationStatus(conn)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read migration status:", err)
+			return 1
+		}
+
+		// tabwriter lines up the columns
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			status := "pending"
+			switch {
+			case s.Missing:
+				status = "applied (file missing!)"
+			case s.Modified:
+				status = "applied (MODIFIED!)"
+			case s.Applied:
+				status = "applied"
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, status, s.AppliedAt)
+		}
+		tw.Flush()
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
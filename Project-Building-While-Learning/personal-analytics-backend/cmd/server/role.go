@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"personal-analytics-backend/internal/config"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
+)
+
+const roleUsage = `Usage: server role <email> <user|admin>
+
+Sets the role of an existing account. Takes effect with the user's next
+access token (log in again or POST /token/refresh).`
+
+// runRole handles "server role <email> <role>" and returns the process exit code.
+// There is no HTTP endpoint for promoting users: the first admin has to come
+// from someone with access to the server and its database.
+func runRole(cfg *config.Config, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, roleUsage)
+		return 2
+	}
+	email, role := args[0], args[1]
+	if role != models.RoleUser && role != models.RoleAdmin {
+		fmt.Fprintln(os.Stderr, roleUsage)
+		return 2
+	}
+
+	// InitDB, not Open: the role column has to exist (migration 0008)
+	conn, err := db.InitDB(cfg.DBDriver, cfg.DataSource())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open database:", err)
+		return 1
+	}
+	defer db.CloseDB(conn)
+
+	users := db.NewSQLUserRepository(conn)
+	ctx := context.Background()
+
+	user, err := users.GetByEmail(ctx, email)
+	if errors.Is(err, db.ErrNotFound) {
+		fmt.Fprintln(os.Stderr, "No user with email", email)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load user:", err)
+		return 1
+	}
+
+	if err := users.SetRole(ctx, user.ID, role); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to set role:", err)
+		return 1
+	}
+	fmt.Printf("User %d (%s): role %s → %s\n", user.ID, user.Email, user.Role, role)
+	return 0
+}
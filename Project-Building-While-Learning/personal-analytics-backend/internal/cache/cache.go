@@ -50,6 +50,40 @@ func Delete(key string) {
 	})
 }
 
+// GetField retrieves one field of a Redis hash
+// Returns (value, true) if found, ("", false) if not found or error
+func GetField(key string, field string) (string, bool) {
+	var result string
+
+	err := RedisBreaker.Execute(func() error {
+		var err error
+		result, err = redis.Client.HGet(context.Background(), key, field).Result()
+		return err
+	})
+
+	if err != nil {
+		return "", false
+	}
+	return result, true
+}
+
+// SetField stores one field of a Redis hash and (re)sets the TTL of the whole hash
+//
+// Why a hash? Several related values (e.g. entry counts for different filters)
+// live under ONE key, so a single Delete(key) invalidates all of them at once.
+// Note: TTL is per key, not per field - every SetField pushes the expiry out again.
+func SetField(key string, field string, value interface{}, ttl time.Duration) {
+	RedisBreaker.Execute(func() error {
+		ctx := context.Background()
+		// TxPipeline = MULTI/EXEC, both commands are sent in one round-trip
+		pipe := redis.Client.TxPipeline()
+		pipe.HSet(ctx, key, field, value)
+		pipe.Expire(ctx, key, ttl)
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+}
+
 /*
 === INTERVIEW ANSWER: CACHING STRATEGY ===
 
@@ -64,6 +64,8 @@ func NewCache() *Cache {
 
 // cleanupLoop runs every 30 seconds, deleting expired entries
 // This prevents memory leaks from expired-but-never-read entries
+// A plain ticker, not a scheduled job (worker/scheduler.go): every server has its
+// own cache in memory, so every server must clean its own - "once across servers" is wrong here
 func (c *Cache) cleanupLoop() {
 	// time.NewTicker sends a value every 30 seconds
 	// More accurate than time.Sleep because it compensates for processing time
@@ -0,0 +1,101 @@
+package cache
+
+/*
+=== ACCESS TOKEN REVOCATION LIST ===
+
+A JWT is valid until its exp - the server never looks it up. To kill a stolen
+access token early, AuthMiddleware asks this list on every request:
+
+  revoked:jti:<jti>        one token (logout)             TTL = until the token's exp
+  revoked:user:<user_id>   every token issued before then  TTL = AccessTokenTTL
+                           (value = unix time in MILLISECONDS of "revoke all sessions")
+
+Why milliseconds? With whole seconds, a token issued in the same second AFTER
+a password reset or "log out everywhere" - the user logging in again right
+away - counted as revoked, and the new session failed at once. Tokens carry
+their issue time in milliseconds too (iat_ms), compared with a strict <.
+
+Why TTLs? After exp the token is rejected anyway, so the entry can disappear.
+The list never grows beyond "tokens revoked in the last 15 minutes".
+
+=== REDIS + IN-MEMORY FALLBACK ===
+
+Writes go to AppCache (this process) AND Redis (shared by all servers).
+Reads check AppCache first, then Redis through RedisBreaker.
+
+Redis down / breaker open:
+  - revocations made on THIS server still work (AppCache)
+  - revocations made on OTHER servers are missed until Redis is back
+  → fail open, like the rate limiter: Redis being down must not log everyone out.
+A revocation written while Redis was down stays in AppCache only, so it is
+checked there first even after Redis recovers.
+*/
+
+import (
+	"context"
+	"personal-analytics-backend/internal/redis"
+	"strconv"
+	"time"
+)
+
+func revokedTokenKey(jti string) string {
+	return "revoked:jti:" + jti
+}
+
+func revokedUserKey(userID int64) string {
+	return "revoked:user:" + strconv.FormatInt(userID, 10)
+}
+
+// RevokeToken revokes one access token until it expires on its own
+func RevokeToken(jti string, expiresAt time.Time) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return // already expired, nothing to revoke
+	}
+
+	AppCache.Set(revokedTokenKey(jti), true, ttl)
+	Set(revokedTokenKey(jti), 1, ttl)
+}
+
+// RevokeUserTokens revokes every access token of the user issued before issuedBefore.
+// ttl must be at least the access token lifetime: by then all those tokens have expired.
+func RevokeUserTokens(userID int64, issuedBefore time.Time, ttl time.Duration) {
+	cutoff := issuedBefore.UnixMilli()
+
+	AppCache.Set(revokedUserKey(userID), cutoff, ttl)
+	Set(revokedUserKey(userID), cutoff, ttl)
+}
+
+// IsTokenRevoked reports whether the token (jti, issued at issuedAt for userID) was revoked
+func IsTokenRevoked(jti string, userID int64, issuedAt time.Time) bool {
+	// Local first: free, and the only place revocations made while Redis was down live
+	if _, found := AppCache.Get(revokedTokenKey(jti)); found {
+		return true
+	}
+	if cutoff, found := AppCache.Get(revokedUserKey(userID)); found && issuedAt.UnixMilli() < cutoff.(int64) {
+		return true
+	}
+
+	// Shared list: both keys in ONE round-trip (MGET)
+	var values []interface{}
+	err := RedisBreaker.Execute(func() error {
+		var err error
+		values, err = redis.Client.MGet(context.Background(), revokedTokenKey(jti), revokedUserKey(userID)).Result()
+		return err
+	})
+	if err != nil {
+		return false // fail open, see comment at the top
+	}
+
+	// MGET returns nil for missing keys, the stored value as a string otherwise
+	if values[0] != nil {
+		return true
+	}
+	if s, ok := values[1].(string); ok {
+		cutoff, err := strconv.ParseInt(s, 10, 64)
+		if err == nil && issuedAt.UnixMilli() < cutoff {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"personal-analytics-backend/internal/redis"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Redis is down for these tests (nothing listens on port 1): every revocation
+// is answered from AppCache, like on the server that made it during an outage
+func TestIsTokenRevoked(t *testing.T) {
+	previous := redis.Client
+	redis.Client = goredis.NewClient(&goredis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialerRetries: 1})
+	t.Cleanup(func() {
+		redis.Client.Close()
+		redis.Client = previous
+	})
+
+	// "Log out everywhere" at a time with milliseconds; user 2 is someone else
+	cutoff := time.UnixMilli(1_760_000_000_123)
+	RevokeUserTokens(1, cutoff, time.Minute)
+	RevokeToken("logged-out", time.Now().Add(time.Minute))
+	RevokeToken("already-expired", time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name     string
+		jti      string
+		userID   int64
+		issuedAt time.Time
+		want     bool
+	}{
+		{"issued long before the cutoff", "a", 1, cutoff.Add(-time.Hour), true},
+		{"issued 1ms before", "b", 1, cutoff.Add(-time.Millisecond), true},
+		{"issued at the cutoff", "c", 1, cutoff, false},
+		{"logged in again 1ms later, same second", "d", 1, cutoff.Add(time.Millisecond), false},
+		{"another user's token", "e", 2, cutoff.Add(-time.Hour), false},
+		{"revoked jti, any user and time", "logged-out", 2, cutoff.Add(time.Hour), true},
+		{"an expired token isn't stored", "already-expired", 2, cutoff.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTokenRevoked(tt.jti, tt.userID, tt.issuedAt); got != tt.want {
+				t.Errorf("IsTokenRevoked(%q, %d, %s) = %v, want %v", tt.jti, tt.userID, tt.issuedAt.Format(time.StampMilli), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"personal-analytics-backend/internal/models"
+)
+
+/*
+=== ACCOUNT EXPORT AND DELETION ===
+
+GDPR gives users two rights this file is for:
+
+  GET /me/export   "give me my data"    → Export: every entry, the trash included
+  DELETE /me       "forget me"          → PurgeUser, then UserRepository.Delete
+
+Deleting really deletes (no deleted_at like the trash), in two steps:
+
+  1. EntryRepository.PurgeUser   entries, their tags and history
+  2. UserRepository.Delete       tokens, API keys, the account itself
+
+Entries go first: if step 2 fails the account still exists and DELETE /me can
+simply be retried. The other order could leave entries behind that belong to
+no account, and nobody could ever ask for them to be deleted.
+
+SQLite doesn't enforce our foreign keys, so every table is cleared explicitly
+instead of relying on ON DELETE CASCADE (which only some Postgres tables have).
+*/
+
+// Export returns every entry of the user, trashed ones included (with DeletedAt), oldest first
+func (r *SQLEntryRepository) Export(ctx context.Context, userID int64) ([]models.Entry, error) {
+	query := `SELECT ` + entryColumns + `, deleted_at FROM entries WHERE user_id = ? ORDER BY id`
+	rows, err := r.conn.query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.Entry{}
+	for rows.Next() {
+		var deletedAt sql.NullTime
+		entry, err := scanEntry(rows, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		if deletedAt.Valid {
+			entry.DeletedAt = &deletedAt.Time
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.attachTags(ctx, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PurgeUser permanently deletes every entry of the user (trash included), their
+// history and the user's tags, and returns how many entries were removed
+func (r *SQLEntryRepository) PurgeUser(ctx context.Context, userID int64) (int64, error) {
+	var purged int64
+	err := inTransaction(ctx, r.conn, func(tx *Tx) error {
+		// entry_tags rows and FTS rows go with the entries (triggers / ON DELETE CASCADE)
+		if _, err := tx.exec(ctx, `DELETE FROM entry_revisions WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		result, err := tx.exec(ctx, `DELETE FROM entries WHERE user_id = ?`, userID)
+		if err != nil {
+			return err
+		}
+		if purged, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, `DELETE FROM tags WHERE user_id = ?`, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	invalidateCounts(userID)
+	return purged, nil
+}
+
+// Delete removes the account with its refresh tokens, email tokens and API keys, or ErrNotFound.
+// Entries are not touched: call EntryRepository.PurgeUser first.
+func (r *SQLUserRepository) Delete(ctx context.Context, userID int64) error {
+	return inTransaction(ctx, r.conn, func(tx *Tx) error {
+		for _, query := range []string{
+			`DELETE FROM refresh_tokens WHERE user_id = ?`,
+			`DELETE FROM user_tokens WHERE user_id = ?`,
+			`DELETE FROM api_keys WHERE user_id = ?`,
+		} {
+			if _, err := tx.exec(ctx, query, userID); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.exec(ctx, `DELETE FROM users WHERE id = ?`, userID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"personal-analytics-backend/internal/models"
+	"time"
+)
+
+/*
+=== ADMIN QUERIES ===
+
+Every other query in this package is scoped to ONE user (WHERE user_id = ?).
+These are the exceptions: they count across all users for GET /admin/stats,
+so only handlers behind RequireRole(admin) may call them.
+
+Each is a single row of COUNTs - COUNT(column) skips NULLs, so one pass over
+the table answers "how many", "how many disabled", "how many verified"...
+*/
+
+// Stats counts all accounts: total, admins, disabled, email verified
+func (r *SQLUserRepository) Stats(ctx context.Context) (models.UserStats, error) {
+	query := `SELECT COUNT(*),
+	                 COUNT(CASE WHEN role = ? THEN 1 END),
+	                 COUNT(disabled_at),
+	                 COUNT(email_verified_at)
+	          FROM users`
+
+	var s models.UserStats
+	err := r.conn.queryRow(ctx, query, models.RoleAdmin).Scan(&s.Total, &s.Admins, &s.Disabled, &s.Verified)
+	return s, err
+}
+
+// Stats counts the entries of all users: live, in the trash, created in the last 24 hours
+func (r *SQLEntryRepository) Stats(ctx context.Context) (models.EntryStats, error) {
+	query := `SELECT COUNT(CASE WHEN deleted_at IS NULL THEN 1 END),
+	                 COUNT(deleted_at),
+	                 COUNT(CASE WHEN deleted_at IS NULL AND created_at >= ? THEN 1 END)
+	          FROM entries`
+
+	var s models.EntryStats
+	since := storedTimestamp(time.Now().Add(-24 * time.Hour))
+	err := r.conn.queryRow(ctx, query, since).Scan(&s.Total, &s.InTrash, &s.CreatedLast24h)
+	return s, err
+}
@@ -0,0 +1,326 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math"
+	"personal-analytics-backend/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+=== WHOSE "DAY"? ===
+
+created_at is stored in UTC. An entry written at 23:30 in New York on Monday
+is stored as Tuesday 04:30 - grouped by the UTC date it would count for the
+wrong day (and sometimes the wrong week or month).
+
+So the analytics work in the user's time zone (their profile, see users.go):
+
+  - the handler turns "from"/"to" dates into the UTC instants of the user's
+    local midnights - those are the range bounds below
+  - MoodTrend puts every entry in the bucket of its LOCAL date (Calendar)
+
+The grouping stays in SQL (one row per bucket, not per entry), only the local
+date is written per dialect (dialect.localDay):
+
+  - Postgres knows time zones: created_at AT TIME ZONE 'UTC' AT TIME ZONE ?
+  - SQLite has no time zone database, only offsets: date(created_at, '-18000 seconds').
+    Go knows when DST changes the offset (ZoneBounds), so the range is cut at
+    those instants and a CASE picks the offset of each row:
+
+      date(created_at, CASE WHEN created_at < '2026-03-08 07:00:00' THEN '-18000 seconds'
+                            ELSE '-14400 seconds' END)
+*/
+
+// ErrInvalidBucket is returned for a bucket other than "day", "week" or "month"
+var ErrInvalidBucket = errors.New("invalid bucket")
+
+// IsValidBucket reports whether bucket is one of "day", "week" or "month"
+func IsValidBucket(bucket string) bool {
+	return bucket == "day" || bucket == "week" || bucket == "month"
+}
+
+// Calendar is how a user's days and weeks fall: days start at midnight in
+// Location, weeks start on WeekStart
+type Calendar struct {
+	Location  *time.Location
+	WeekStart time.Weekday
+}
+
+// UTCCalendar is the calendar of a user who never set a time zone: UTC days, ISO weeks
+var UTCCalendar = Calendar{Location: time.UTC, WeekStart: time.Monday}
+
+// periodStart returns the first day ("YYYY-MM-DD") of the bucket t falls in, in the user's time zone
+func (c Calendar) periodStart(t time.Time, bucket string) string {
+	year, month, day := t.In(c.Location).Date()
+	// A date without a time zone from here on: UTC only so AddDate never meets DST
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	switch bucket {
+	case "week":
+		// Weekday(): Sunday = 0 … Saturday = 6. Days since the week started:
+		date = date.AddDate(0, 0, -((int(date.Weekday()) - int(c.WeekStart) + 7) % 7))
+	case "month":
+		date = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return date.Format("2006-01-02")
+}
+
+// moodTrend groups (created_at, mood) points into buckets of cal, oldest bucket first.
+// Used by the memory repository; the SQL one does the same in SQL (see above).
+type moodTrend map[string]*moodStats
+
+func (t moodTrend) add(cal Calendar, bucket string, createdAt time.Time, mood int) {
+	period := cal.periodStart(createdAt, bucket)
+	if t[period] == nil {
+		t[period] = &moodStats{}
+	}
+	t[period].add(mood)
+}
+
+func (t moodTrend) buckets() []models.MoodBucket {
+	buckets := []models.MoodBucket{}
+	for period, s := range t {
+		buckets = append(buckets, models.MoodBucket{
+			PeriodStart: period,
+			Count:       s.count,
+			AvgMood:     round2(float64(s.sum) / float64(s.count)),
+			MinMood:     s.min,
+			MaxMood:     s.max,
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].PeriodStart < buckets[j].PeriodStart
+	})
+	return buckets
+}
+
+// moodStats accumulates count/sum/min/max of mood values
+type moodStats struct {
+	count, sum, min, max int
+}
+
+func (s *moodStats) add(mood int) {
+	if s.count == 0 {
+		s.min, s.max = math.MaxInt, math.MinInt
+	}
+	s.count++
+	s.sum += mood
+	s.min = min(s.min, mood)
+	s.max = max(s.max, mood)
+}
+
+// sqliteLocalDay is date(created_at, <UTC offset of loc>). Where [from, to) crosses
+// a DST change the offset is a CASE over the changes, as the database can't look
+// the offset up itself.
+func sqliteLocalDay(loc *time.Location, from time.Time, to time.Time) (string, []interface{}) {
+	offset := func(t time.Time) string {
+		_, seconds := t.In(loc).Zone()
+		return strconv.Itoa(seconds) + " seconds" // SQLite modifier, "-18000 seconds"
+	}
+
+	// to is exclusive and never zero for MoodTrend (created_at < '' matches nothing)
+	var sb strings.Builder
+	var args []interface{}
+	t := from
+	for {
+		_, end := t.In(loc).ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			break // no more changes in the range (or none at all: UTC)
+		}
+		sb.WriteString(` WHEN created_at < ? THEN ?`)
+		args = append(args, storedTimestamp(end), offset(t))
+		t = end
+	}
+	if len(args) == 0 {
+		return `date(created_at, ?)`, []interface{}{offset(t)}
+	}
+	return `date(created_at, CASE` + sb.String() + ` ELSE ? END)`, append(args, offset(t))
+}
+
+// postgresLocalDay is the date created_at (a UTC timestamp without time zone) has in loc.
+// The first AT TIME ZONE says the stored time is UTC, the second converts it to loc
+// - DST included.
+func postgresLocalDay(loc *time.Location, from time.Time, to time.Time) (string, []interface{}) {
+	return `(created_at AT TIME ZONE 'UTC' AT TIME ZONE ?)::date`, []interface{}{loc.String()}
+}
+
+// rangeBound formats a range bound for comparing with created_at ("" for the zero time = no bound)
+func rangeBound(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return storedTimestamp(t)
+}
+
+// MoodTrend returns mood statistics for a user grouped by day, week or month
+// of cal. from is inclusive and to is exclusive.
+func (r *SQLEntryRepository) MoodTrend(ctx context.Context, userID int64, from time.Time, to time.Time, bucket string, cal Calendar) ([]models.MoodBucket, error) {
+	if !IsValidBucket(bucket) {
+		return nil, ErrInvalidBucket
+	}
+
+	d := r.conn.dialect
+	var args []interface{}
+	if bucket == "week" {
+		args = append(args, int(cal.WeekStart))
+	}
+	localDay, dayArgs := d.localDay(cal.Location, from, to)
+	args = append(args, dayArgs...)
+	args = append(args, userID, rangeBound(from), rangeBound(to))
+
+	// The inner query dates each entry in the user's time zone, the outer one
+	// groups those dates into buckets.
+	// mood is optional (NULL): an entry without one says nothing about the mood,
+	// counting it as 0 would drag the average and the minimum down
+	query := `SELECT ` + d.periodStart[bucket] + `, COUNT(*), ROUND(AVG(mood), 2), MIN(mood), MAX(mood)
+	          FROM (SELECT ` + localDay + ` AS day, mood
+	                FROM entries
+	                WHERE user_id = ? AND deleted_at IS NULL AND mood IS NOT NULL
+	                  AND created_at >= ? AND created_at < ?) AS local_entries
+	          GROUP BY 1
+	          ORDER BY 1`
+
+	rows, err := r.conn.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []models.MoodBucket{}
+	for rows.Next() {
+		var b models.MoodBucket
+		if err := rows.Scan(&b.PeriodStart, &b.Count, &b.AvgMood, &b.MinMood, &b.MaxMood); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// MoodSummary returns count, average, min and max mood over a time range.
+// Same range rules as MoodTrend (from inclusive, to exclusive).
+func (r *SQLEntryRepository) MoodSummary(ctx context.Context, userID int64, from time.Time, to time.Time) (models.MoodSummary, error) {
+	// COALESCE: AVG/MIN/MAX return NULL when there are no rows
+	query := `SELECT COUNT(*),
+	                 COALESCE(ROUND(AVG(mood), 2), 0),
+	                 COALESCE(MIN(mood), 0),
+	                 COALESCE(MAX(mood), 0)
+	          FROM entries
+	          WHERE user_id = ? AND deleted_at IS NULL AND mood IS NOT NULL
+	            AND created_at >= ? AND created_at < ?`
+
+	var s models.MoodSummary
+	err := r.conn.queryRow(ctx, query, userID, rangeBound(from), rangeBound(to)).Scan(&s.Count, &s.AvgMood, &s.MinMood, &s.MaxMood)
+	if err != nil {
+		return models.MoodSummary{}, err
+	}
+	return s, nil
+}
+
+// CategoryBreakdown returns per-category entry count, average mood, mood
+// standard deviation and difference from the user's overall average.
+// from (inclusive) and to (exclusive) are optional - pass the zero time for no bound.
+//
+// SQLite has no STDDEV() (Postgres does, but one query for both is simpler) so we fetch SUM(mood) and SUM(mood*mood) per category
+// and compute the (population) standard deviation in Go:
+//
+//	variance = E[x²] - (E[x])²
+//
+// The overall numbers are derived from the same sums, so no second query is needed.
+func (r *SQLEntryRepository) CategoryBreakdown(ctx context.Context, userID int64, from time.Time, to time.Time) (models.CategoryReport, error) {
+	// Without a mood, SUM(mood) of the whole category would be NULL (and the Scan fail)
+	query := `SELECT COALESCE(category, ''), COUNT(*), SUM(mood), SUM(mood * mood)
+	          FROM entries
+	          WHERE user_id = ? AND deleted_at IS NULL AND mood IS NOT NULL`
+	args := []interface{}{userID}
+
+	if !from.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, rangeBound(from))
+	}
+	if !to.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, rangeBound(to))
+	}
+	query += ` GROUP BY 1 ORDER BY COUNT(*) DESC, 1`
+
+	rows, err := r.conn.query(ctx, query, args...)
+	if err != nil {
+		return models.CategoryReport{}, err
+	}
+	defer rows.Close()
+
+	type categorySums struct {
+		category   string
+		count      int
+		sum, sumSq int64
+	}
+
+	var perCategory []categorySums
+	var totalCount int
+	var totalSum, totalSumSq int64
+
+	for rows.Next() {
+		var c categorySums
+		err := rows.Scan(&c.category, &c.count, &c.sum, &c.sumSq)
+		if err != nil {
+			return models.CategoryReport{}, err
+		}
+		perCategory = append(perCategory, c)
+		totalCount += c.count
+		totalSum += c.sum
+		totalSumSq += c.sumSq
+	}
+	if err := rows.Err(); err != nil {
+		return models.CategoryReport{}, err
+	}
+
+	report := models.CategoryReport{
+		Count:      totalCount,
+		Categories: []models.CategoryStats{},
+	}
+	if totalCount == 0 {
+		return report, nil
+	}
+
+	overallAvg, overallStdDev := meanAndStdDev(totalCount, totalSum, totalSumSq)
+	report.AvgMood = round2(overallAvg)
+	report.StdDevMood = round2(overallStdDev)
+
+	for _, c := range perCategory {
+		avg, stdDev := meanAndStdDev(c.count, c.sum, c.sumSq)
+		report.Categories = append(report.Categories, models.CategoryStats{
+			Category:        c.category,
+			Count:           c.count,
+			AvgMood:         round2(avg),
+			StdDevMood:      round2(stdDev),
+			DiffFromOverall: round2(avg - overallAvg),
+		})
+	}
+
+	return report, nil
+}
+
+// meanAndStdDev computes mean and population standard deviation from count, Σx and Σx²
+func meanAndStdDev(count int, sum int64, sumSq int64) (float64, float64) {
+	n := float64(count)
+	mean := float64(sum) / n
+	variance := float64(sumSq)/n - mean*mean
+	if variance < 0 {
+		// Floating point noise can make a zero variance slightly negative
+		variance = 0
+	}
+	return mean, math.Sqrt(variance)
+}
+
+// round2 rounds to 2 decimal places for display (4.666… → 4.67)
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sqliteLocalDay has to know every DST change between from and to. Each case
+// checks how many the expression holds, then evaluates it in SQLite for
+// instants around the changes and compares with what Go says the local date is.
+func TestSQLiteLocalDay(t *testing.T) {
+	conn := newTestConn(t)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(s string) time.Time {
+		t.Helper()
+		at, err := time.Parse(storedTimeLayout, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+
+	// New York 2026: EDT from 03-08 07:00 UTC, EST again from 11-01 06:00 UTC
+	tests := []struct {
+		name    string
+		loc     *time.Location
+		from    string
+		to      string
+		changes int
+		rows    []string // created_at values to evaluate, all inside [from, to)
+	}{
+		{"UTC has no changes", time.UTC, "2026-01-01 00:00:00", "2027-01-01 00:00:00", 0,
+			[]string{"2026-03-08 06:59:59", "2026-12-31 23:59:59"}},
+		{"winter only", newYork, "2026-01-01 05:00:00", "2026-02-01 05:00:00", 0,
+			[]string{"2026-01-01 05:00:00", "2026-01-02 04:59:59", "2026-01-31 23:00:00"}},
+		{"spring forward", newYork, "2026-03-01 05:00:00", "2026-04-01 04:00:00", 1,
+			[]string{"2026-03-08 04:59:59", "2026-03-08 06:59:59", "2026-03-08 07:00:00", "2026-03-09 03:59:59", "2026-03-09 04:00:00"}},
+		{"whole year: both changes", newYork, "2026-01-01 05:00:00", "2027-01-01 05:00:00", 2,
+			[]string{"2026-03-08 07:00:00", "2026-11-01 04:00:00", "2026-11-01 05:59:59", "2026-11-01 06:00:00", "2026-11-02 04:59:59", "2026-11-02 05:00:00"}},
+		{"change exactly at to is left out", newYork, "2026-03-01 05:00:00", "2026-03-08 07:00:00", 0,
+			[]string{"2026-03-08 04:59:59", "2026-03-08 06:59:59"}},
+		{"range starting on the change", newYork, "2026-03-08 07:00:00", "2026-03-15 04:00:00", 0,
+			[]string{"2026-03-08 07:00:00", "2026-03-09 03:59:59", "2026-03-09 04:00:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, args := sqliteLocalDay(tt.loc, utc(tt.from), utc(tt.to))
+			if got := strings.Count(expr, "WHEN"); got != tt.changes {
+				t.Errorf("%d DST changes in %s, want %d", got, expr, tt.changes)
+			}
+			if want := 2*tt.changes + 1; len(args) != want {
+				t.Errorf("%d arguments, want %d", len(args), want)
+			}
+
+			for _, row := range tt.rows {
+				// The expression's placeholders come first in the query text
+				var day string
+				err := conn.queryRow(context.Background(), `SELECT `+expr+` FROM (SELECT ? AS created_at)`,
+					append(slices.Clip(args), row)...).Scan(&day)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := utc(row).In(tt.loc).Format("2006-01-02"); day != want {
+					t.Errorf("%s UTC: local day %s, want %s", row, day, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"personal-analytics-backend/internal/models"
+	"strings"
+	"time"
+)
+
+/*
+=== PERSONAL API KEYS ===
+
+A phone shortcut or a cron job can't type a password and refresh a JWT every
+15 minutes. An API key is a long-lived secret for exactly that:
+
+  - random, shown ONCE          POST /apikeys returns it, afterwards only the prefix
+  - stored as SHA-256           a leaked database doesn't contain working keys
+  - scoped                      "entries:read" can't delete anything
+  - revocable                   DELETE /apikeys/{id} - revoked_at set, the row stays
+                                so the list still shows when the key was last used
+
+Scopes are a comma-separated column ('entries:read,analytics:read'): a short,
+fixed list that is always read together with the key - a join table would buy nothing.
+
+Authenticate runs on every request made with a key (one lookup by the UNIQUE
+key_hash index). Touch is separate so AuthMiddleware can skip the write when
+last_used_at is recent - otherwise every read request would write to the database.
+*/
+
+// SQLAPIKeyRepository is the APIKeyRepository backed by SQLite or Postgres
+type SQLAPIKeyRepository struct {
+	conn *Conn
+}
+
+var _ APIKeyRepository = (*SQLAPIKeyRepository)(nil)
+
+// NewSQLAPIKeyRepository creates an API key repository on an open connection (see InitDB)
+func NewSQLAPIKeyRepository(conn *Conn) *SQLAPIKeyRepository {
+	return &SQLAPIKeyRepository{conn: conn}
+}
+
+// Create saves a new key
+func (r *SQLAPIKeyRepository) Create(ctx context.Context, userID int64, name string, prefix string, keyHash string, scopes []string) (models.APIKey, error) {
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes) VALUES (?, ?, ?, ?, ?)
+	          RETURNING id, created_at`
+
+	key := models.APIKey{UserID: userID, Name: name, Prefix: prefix, Scopes: scopes}
+	err := r.conn.queryRow(ctx, query, userID, name, prefix, keyHash, strings.Join(scopes, ",")).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// List returns the user's keys, newest first
+func (r *SQLAPIKeyRepository) List(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = ? ORDER BY id DESC`
+	rows, err := r.conn.query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke sets revoked_at on one of the user's active keys
+func (r *SQLAPIKeyRepository) Revoke(ctx context.Context, userID int64, keyID int64) error {
+	query := `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+	result, err := r.conn.exec(ctx, query, storedTimestamp(time.Now()), keyID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeUser sets revoked_at on all of the user's active keys
+func (r *SQLAPIKeyRepository) RevokeUser(ctx context.Context, userID int64) error {
+	query := `UPDATE api_keys SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`
+	_, err := r.conn.exec(ctx, query, storedTimestamp(time.Now()), userID)
+	return err
+}
+
+// Authenticate looks an active key up by its hash
+func (r *SQLAPIKeyRepository) Authenticate(ctx context.Context, keyHash string) (models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL`
+	key, err := scanAPIKey(r.conn.queryRow(ctx, query, keyHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrTokenInvalid
+	}
+	return key, err
+}
+
+// Touch sets last_used_at
+func (r *SQLAPIKeyRepository) Touch(ctx context.Context, keyID int64, usedAt time.Time) error {
+	_, err := r.conn.exec(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, storedTimestamp(usedAt), keyID)
+	return err
+}
+
+// apiKeyColumns is the column list scanAPIKey expects, in order
+const apiKeyColumns = `id, user_id, name, prefix, scopes, created_at, last_used_at, revoked_at`
+
+// scanAPIKey reads one api_keys row (sql.ErrNoRows is passed through)
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var k models.APIKey
+	var scopes string
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &k.CreatedAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	k.Scopes = splitRevisionTags(scopes) // same "a,b" format as revision tags
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return k, nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+/*
+=== CURSOR (KEYSET) PAGINATION ===
+
+OFFSET pagination: "skip 10,000 rows, give me the next 10"
+  - SQLite still walks the 10,000 skipped rows → deep pages get slower
+  - If a new entry is inserted between page 1 and page 2, everything shifts
+    by one → the last entry of page 1 shows up again on page 2 (or one is skipped)
+
+Keyset pagination: "give me the 10 rows that come AFTER this one"
+  WHERE (created_at, id) < (last_created_at, last_id)
+  ORDER BY created_at DESC, id DESC
+  - Index seek straight to the position → page 1,000 is as fast as page 1
+  - New inserts land BEFORE the cursor (newest first) → no drift
+
+Why (created_at, id) and not just created_at?
+  Two entries can share the same created_at (second precision).
+  id breaks the tie so the order is total and no row is skipped.
+
+The cursor is opaque to clients (base64 JSON). They just pass back next_cursor.
+That lets us change what's inside later without breaking anyone.
+*/
+
+// ErrInvalidCursor is returned when a client sends a cursor we didn't issue
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// entryCursor is the position of the last entry on a page
+type entryCursor struct {
+	CreatedAt string `json:"t"`  // raw created_at as stored ("2026-01-12 10:30:00")
+	ID        int64  `json:"id"` // tie-breaker
+}
+
+// encodeCursor turns a position into the opaque string sent to clients
+func encodeCursor(c entryCursor) string {
+	data, _ := json.Marshal(c) // marshalling a string + int can't fail
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor string from a client
+// An empty string means "start from the newest entry" and returns nil
+func decodeCursor(s string) (*entryCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c entryCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.CreatedAt == "" || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
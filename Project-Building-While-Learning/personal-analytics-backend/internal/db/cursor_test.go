@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    *entryCursor
+		wantErr error
+	}{
+		{"empty: first page", "", nil, nil},
+		{"round trip", encodeCursor(entryCursor{CreatedAt: "2026-01-12 10:30:00", ID: 42}), &entryCursor{CreatedAt: "2026-01-12 10:30:00", ID: 42}, nil},
+		{"postgres microseconds kept", encodeCursor(entryCursor{CreatedAt: "2026-01-12 10:30:00.123456", ID: 7}), &entryCursor{CreatedAt: "2026-01-12 10:30:00.123456", ID: 7}, nil},
+		{"not base64", "!!!", nil, ErrInvalidCursor},
+		{"padded base64 is not ours", base64.URLEncoding.EncodeToString([]byte(`{"t":"2026-01-12 10:30:00","id":1}`)), nil, ErrInvalidCursor},
+		{"not JSON", raw("hello"), nil, ErrInvalidCursor},
+		{"no time", raw(`{"id":1}`), nil, ErrInvalidCursor},
+		{"no id", raw(`{"t":"2026-01-12 10:30:00"}`), nil, ErrInvalidCursor},
+		{"negative id", raw(`{"t":"2026-01-12 10:30:00","id":-1}`), nil, ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("cursor %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ListAfter over both repositories: entries 1-2 share one created_at, 3-5 a
+// later one, so the pages only come out right if id breaks the ties.
+// Entry 6 belongs to user 2 and must never show up.
+func TestListAfterKeyset(t *testing.T) {
+	earlier := time.Date(2026, 1, 12, 10, 30, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+	createdAt := map[int64]time.Time{1: earlier, 2: earlier, 3: later, 4: later, 5: later}
+
+	repos := []struct {
+		name  string
+		setup func(t *testing.T) EntryRepository
+	}{
+		{"sqlite", func(t *testing.T) EntryRepository {
+			conn := newMigratedConn(t)
+			ctx := context.Background()
+			repo := NewSQLEntryRepository(conn)
+			for _, user := range []int64{1, 2} {
+				if _, err := conn.exec(ctx, `INSERT INTO users (id, email, password_hash) VALUES (?, ?, 'x')`, user, string(rune('a'+user))+"@example.com"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Plain INSERTs: Create would invalidate the count cache in Redis
+			for id := int64(1); id <= 6; id++ {
+				user, at := int64(1), createdAt[id]
+				if id == 6 {
+					user, at = 2, later
+				}
+				_, err := conn.exec(ctx, `INSERT INTO entries (id, user_id, text, mood, category, created_at) VALUES (?, ?, 'entry', 5, 'test', ?)`,
+					id, user, storedTimestamp(at))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			return repo
+		}},
+		{"memory", func(t *testing.T) EntryRepository {
+			repo := NewMemoryEntryRepository()
+			for _, user := range []int64{1, 1, 1, 1, 1, 2} {
+				if _, err := repo.Create(context.Background(), user, EntryInput{Text: "entry", Mood: 5, Category: "test"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for id, at := range createdAt {
+				e := repo.entries[id]
+				e.CreatedAt = at
+				repo.entries[id] = e
+			}
+			return repo
+		}},
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		pages [][]int64
+	}{
+		{"pages of two", 2, [][]int64{{5, 4}, {3, 2}, {1}}},
+		{"page boundary inside a tie", 4, [][]int64{{5, 4, 3, 2}, {1}}},
+		{"exact fit: no extra page", 5, [][]int64{{5, 4, 3, 2, 1}}},
+		{"everything on one page", 10, [][]int64{{5, 4, 3, 2, 1}}},
+	}
+	for _, r := range repos {
+		t.Run(r.name, func(t *testing.T) {
+			repo := r.setup(t)
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					cursor := ""
+					for i, want := range tt.pages {
+						entries, next, err := repo.ListAfter(context.Background(), 1, EntryFilter{}, cursor, tt.limit)
+						if err != nil {
+							t.Fatal(err)
+						}
+						var ids []int64
+						for _, e := range entries {
+							ids = append(ids, e.ID)
+						}
+						if !slices.Equal(ids, want) {
+							t.Fatalf("page %d: ids %v, want %v", i+1, ids, want)
+						}
+						if last := i == len(tt.pages)-1; last != (next == "") {
+							t.Fatalf("page %d: next cursor %q, last page %v", i+1, next, last)
+						}
+						cursor = next
+					}
+				})
+			}
+
+			if _, _, err := repo.ListAfter(context.Background(), 1, EntryFilter{}, "garbage", 2); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("bad cursor: %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
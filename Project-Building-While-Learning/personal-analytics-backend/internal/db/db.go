@@ -1,11 +1,10 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"log/slog"
-	"personal-analytics-backend/internal/cache"
-	"strconv"
+	"strings"
 	"time"
 
 	// The underscore _ means "blank import"
@@ -19,353 +18,136 @@ import (
 	// modernc.org/sqlite raises its hand: "I do!"
 	// Connection established
 	_ "modernc.org/sqlite"
-)
-
-// DB is the global database connection
-// It's the middleman between your Go code and the database file.
-// "Create a variable called DB that will hold the connection to the database."
-// This is like having a phone line open to the database. Once connected,
-//  you use this DB variable to talk to the database from anywhere in your code.
-
-var DB *sql.DB
-
-// InitDB initializes the database connection and creates tables
-func InitDB(dbPath string) error {
-	var err error
-
-	// Open SQLite database (creates file if not exists)
-	DB, err = sql.Open("sqlite", dbPath) // or ":memory:" as in-memory DB
-	if err != nil {
-		return err
-	}
-
-	// Test the connection
-	err = DB.Ping()
-	if err != nil {
-		return err
-	}
 
-	slog.Info("Database connected", "path", dbPath)
+	// Same trick for Postgres: registers the "pgx" driver (DB_DRIVER=postgres)
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
 
-	// Create tables
-	err = createTables()
-	if err != nil {
-		return err
-	}
+// There is no global connection: InitDB/Open return a *Conn and main.go
+// hands it to the repositories (NewSQLEntryRepository, NewSQLUserRepository).
+// *sql.DB is itself a connection POOL and safe to share between goroutines,
+// so one value for the whole process is still what we want - just passed explicitly.
 
-	return nil
+// Conn is an open database: the connection pool plus the SQL dialect it speaks.
+// *sql.DB is embedded, so conn.PingContext(), conn.Close() etc. work as usual.
+type Conn struct {
+	*sql.DB
+	dialect *dialect
 }
 
-// createTables creates all required tables if they don't exist
-func createTables() error {
-	// Users table - stores user accounts
-	usersTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		email TEXT UNIQUE NOT NULL,
-		password_hash TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	_, err := DB.Exec(usersTable)
-	if err != nil {
-		return err
-	}
-
-	// Entries table - stores mood/activity data
-	entriesTable := `
-	CREATE TABLE IF NOT EXISTS entries (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		text TEXT,
-		mood INTEGER,
-		category TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	_, err = DB.Exec(entriesTable)
-	if err != nil {
-		return err
-	}
-
-	slog.Info("Database tables created")
-	return nil
+// Driver returns the DB_DRIVER this connection was opened with ("sqlite" or "postgres")
+func (c *Conn) Driver() string {
+	return c.dialect.name
 }
 
-// InsertEntry inserts a new entry into the database
-// Puts new data INTO the database (like adding a new row to an Excel sheet)
-// Takes 5 inputs: userID (which user), text (what they wrote), mood (their mood score), tags (list of tags), category (entry type)
-func InsertEntry(userID int, text string, mood int, category string) (int64, error) {
-	// The ? marks are placeholders (like blanks in a form)
-	query := `INSERT INTO entries (user_id, text, mood, category) VALUES (?, ?, ?, ?)`
-	// "Execute the query and fill in the ? marks with actual values."
-	result, err := DB.Exec(query, userID, text, mood, category)
+// InitDB opens the database and brings the schema up to date
+// driver is DriverSQLite (dataSource = file path) or DriverPostgres (dataSource = connection URL)
+// It refuses to start (returns an error) if an applied migration was edited - see migrate.go
+func InitDB(driver string, dataSource string) (*Conn, error) {
+	conn, err := Open(driver, dataSource)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	// Get the ID of the inserted row
-	// "If something broke, return 0 as ID and the error message."
-	id, err := result.LastInsertId()
+	// Apply pending migrations (replaces the old CREATE TABLE IF NOT EXISTS setup)
+	applied, err := MigrateUp(conn)
 	if err != nil {
-		return 0, err
+		conn.Close()
+		return nil, err
 	}
 
-	slog.Debug("Entry inserted", "entry_id", id)
-	return id, nil
-}
-
-// GetEntryById retrieves the entry from database for a particular ID
-func GetEntryByID(entryID int, userId int64) (map[string]interface{}, error) {
-
-	query := `SELECT id, user_id, text, mood, category, created_at
-				FROM entries
-				WHERE id = ? and user_id = ?
-			`
+	slog.Info("Database schema up to date", "migrations_applied", applied)
 
-	row := DB.QueryRow(query, entryID, userId)
-
-	var id, userIDResult int64
-	var text, category, created_at string
-	var mood int
-
-	err := row.Scan(&id, &userIDResult, &text, &mood, &category, &created_at)
+	// Not fatal: everyone else can still log in
+	ids, err := UnnormalizedEmailUserIDs(context.Background(), conn)
 	if err != nil {
-		return nil, err
+		slog.Error("Failed to check user emails", "error", err)
+	} else if len(ids) > 0 {
+		slog.Warn("Accounts share an email up to case/spaces and can't log in, merge them", "user_ids", ids)
 	}
-
-	entry := map[string]interface{}{
-		"id":         id,
-		"user_id":    userIDResult,
-		"text":       text,
-		"mood":       mood,
-		"category":   category,
-		"created_at": created_at,
-	}
-
-	return entry, nil
+	return conn, nil
 }
 
-// GetAllEntries retrieves all entries from the database
-// string = field name (like "id", "text", "mood")
-// interface{} = any type of value (number, text, etc.)
-/*
-{
-  "id": 1,
-  "text": "Happy",
-  "mood": 5
-}
-*/
-func GetAllEntries() ([]map[string]interface{}, error) {
-	query := `SELECT id, user_id, text, mood, category, tags, created_at FROM entries ORDER BY created_at DESC`
-
-	rows, err := DB.Query(query)
+// Open connects to the database without touching the schema
+// Used directly by the "migrate" command, which decides itself what to apply
+func Open(driver string, dataSource string) (*Conn, error) {
+	d, err := dialectFor(driver)
 	if err != nil {
 		return nil, err
 	}
-	// "When this function ends, close the rows cursor"
-	defer rows.Close()
-
-	var entries []map[string]interface{}
 
-	for rows.Next() {
-		var id, userID, mood int
-		var text, category, createdAt string
-		err := rows.Scan(&id, &userID, &text, &mood, &category, &createdAt)
-		if err != nil {
-			return nil, err
+	if d.dataSourceParams != "" {
+		separator := "?"
+		if strings.Contains(dataSource, "?") {
+			separator = "&"
 		}
-
-		entry := map[string]interface{}{
-			"id":         id,
-			"user_id":    userID,
-			"text":       text,
-			"mood":       mood,
-			"category":   category,
-			"created_at": createdAt,
-		}
-
-		entries = append(entries, entry)
+		dataSource += separator + d.dataSourceParams
 	}
 
-	return entries, nil
-}
-
-// GetEntriesByUser retrieves all entries for a specific user
-// Used when user is authenticated - only show their own entries
-func GetEntriesByUser(userID int64) ([]map[string]interface{}, error) {
-	query := `SELECT id, user_id, text, mood, category, created_at
-	          FROM entries
-	          WHERE user_id = ?
-	          ORDER BY created_at DESC`
-
-	rows, err := DB.Query(query, userID)
+	// SQLite: creates the file if it doesn't exist (or ":memory:" as in-memory DB)
+	// sql.Open doesn't connect yet - it only prepares the pool
+	sqlDB, err := sql.Open(d.driverName, dataSource)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var entries []map[string]interface{}
-
-	for rows.Next() {
-		var id, userIDResult int64
-		var text, category, createdAt string
-		var mood int
-
-		err := rows.Scan(&id, &userIDResult, &text, &mood, &category, &createdAt)
-		if err != nil {
-			return nil, err
-		}
-
-		entry := map[string]interface{}{
-			"id":         id,
-			"user_id":    userIDResult,
-			"text":       text,
-			"mood":       mood,
-			"category":   category,
-			"created_at": createdAt,
-		}
-
-		entries = append(entries, entry)
-	}
-
-	return entries, nil
-}
-
-// GetEntriesByUserPaginated allow users to paginate through entries instead of getting all at once.
-func GetEntriesByUserPaginated(userId int, page int, limit int) (entries []map[string]interface{}, total int) {
-
-	// 1. Build cache key
-	cacheKey := fmt.Sprintf("count:user:%d", userId)
-
-	// 2. Try to get from cache
-	if cachedCount, found := cache.Get(cacheKey); found {
-		total, _ = strconv.Atoi(cachedCount) // Convert string to int (Redis stores strings)
-	} else {
-		// 3. Cache miss: query database
-		queryForCount := `SELECT COUNT(*) FROM entries WHERE user_id = ?`
-		err := DB.QueryRow(queryForCount, userId).Scan(&total)
-
-		if err != nil {
-			return nil, 0
-		}
-
-		// 4. Store in cache for 60 seconds
-		cache.Set(cacheKey, total, 60*time.Second)
-	}
-
-	queryForEntries := `SELECT id, user_id, text, mood, category, created_at
-	 FROM entries WHERE
-	 user_id = ?
-	 ORDER BY created_at DESC
-	 LIMIT ? OFFset ?
-	 `
-	rows, err := DB.Query(queryForEntries, userId, limit, (page-1)*limit)
 
+	// Test the connection
+	err = sqlDB.Ping()
 	if err != nil {
-		return nil, 0
+		sqlDB.Close()
+		return nil, err
 	}
 
-	defer rows.Close()
-
-	for rows.Next() {
-		var id, userIDResult int64
-		var text, category, created_at string
-		var mood int
-
-		err := rows.Scan(&id, &userIDResult, &text, &mood, &category, &created_at)
-		if err != nil {
-			return nil, 0
-		}
-
-		entry := map[string]interface{}{
-			"id":         id,
-			"user_id":    userId,
-			"text":       text,
-			"mood":       mood,
-			"category":   category,
-			"created_at": created_at,
-		}
-		entries = append(entries, entry)
-	}
-	return entries, total
+	// Never log dataSource: for Postgres it contains the password
+	slog.Info("Database connected", "driver", d.name)
+	return &Conn{DB: sqlDB, dialect: d}, nil
 }
 
-// CreateUser inserts a new user into the database
-// Takes email and hashed password (NOT plain password!)
-func CreateUser(email string, passwordHash string) (int64, error) {
-	query := `INSERT INTO users (email, password_hash) VALUES (?, ?)`
-
-	result, err := DB.Exec(query, email, passwordHash)
-	if err != nil {
-		return 0, err
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-
-	slog.Debug("User created", "user_id", id)
-	return id, nil
+// query, queryRow and exec are QueryContext/QueryRowContext/ExecContext with the
+// query written using ? placeholders and rebound for the dialect (see dialect.go)
+func (c *Conn) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.QueryContext(ctx, c.dialect.rebind(query), args...)
 }
 
-// GetUserByEmail retrieves a user by their email
-// Returns user_id and password_hash for login verification
-func GetUserByEmail(email string) (int64, string, error) {
-	query := `SELECT id, password_hash FROM users WHERE email = ?`
-
-	var userID int64
-	var passwordHash string
-
-	err := DB.QueryRow(query, email).Scan(&userID, &passwordHash)
-	if err != nil {
-		return 0, "", err
-	}
-
-	return userID, passwordHash, nil
+func (c *Conn) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.QueryRowContext(ctx, c.dialect.rebind(query), args...)
 }
 
-// Update Entry
-// Updates an entry only if it belongs to the authenticated user
-func UpdateEntry(entryId int, userID int64, text string, mood int, category string) (int64, error) {
-
-	query := `UPDATE entries SET text = ?, mood = ?, category = ? WHERE id = ? AND user_id = ?`
-
-	// Parameters must match placeholder order: text, mood, category, id, user_id
-	result, err := DB.Exec(query, text, mood, category, entryId, userID)
-	if err != nil {
-		return 0, err
-	}
+func (c *Conn) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.ExecContext(ctx, c.dialect.rebind(query), args...)
+}
 
-	// Check if any row was actually updated
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, err
-	}
+// Tx is a transaction with the same rebinding helpers as Conn (see inTransaction)
+type Tx struct {
+	*sql.Tx
+	dialect *dialect
+}
 
-	// Returns 0 if entry not found OR doesn't belong to user
-	return rowsAffected, nil
+func (t *Tx) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.QueryContext(ctx, t.dialect.rebind(query), args...)
 }
 
-func DeleteEntry(entryId int, userID int64) (int64, error) {
+func (t *Tx) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.QueryRowContext(ctx, t.dialect.rebind(query), args...)
+}
 
-	query := `DELETE FROM ENTRIES WHERE id = ? and user_id = ?`
+func (t *Tx) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.ExecContext(ctx, t.dialect.rebind(query), args...)
+}
 
-	result, err := DB.Exec(query, entryId, userID)
-	if err != nil {
-		return 0, err
-	}
+// storedTimeLayout is how SQLite's CURRENT_TIMESTAMP stores created_at.
+// The in-memory fakes format with it so date filters and cursors compare the same way.
+const storedTimeLayout = "2006-01-02 15:04:05"
 
-	rowAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, err
-	}
-	return rowAffected, nil
+// storedTimestamp formats a time computed in Go (deleted_at, expires_at ...) like
+// CURRENT_TIMESTAMP stores created_at: UTC, storedTimeLayout. Compares the same way
+// in SQLite (text) and Postgres (TIMESTAMP without time zone).
+func storedTimestamp(t time.Time) string {
+	return t.UTC().Format(storedTimeLayout)
 }
 
 // CloseDB closes the database connection
-func CloseDB() {
+func CloseDB(conn *Conn) {
 	// Why Close DB Connection When Server Closes?
 	// Great question! Let me explain with real-world consequences:
 
@@ -397,7 +179,7 @@ func CloseDB() {
 	// Start again → another 10MB
 	// ...
 	// Eventually: Out of memory
-	if DB != nil {
-		DB.Close()
+	if conn != nil {
+		conn.Close()
 	}
 }
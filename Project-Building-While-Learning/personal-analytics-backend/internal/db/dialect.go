@@ -0,0 +1,226 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+/*
+=== SQL DIALECTS: SQLITE AND POSTGRES ===
+
+The repositories are written once, in SQL both databases understand.
+The few places where SQLite and Postgres disagree live in a dialect:
+
+  what                 SQLite                          Postgres
+  placeholders         ? ? ?                           $1 $2 $3
+  full-text search     FTS5 table entries_fts          tsvector column + GIN index
+  duplicate email      extended error code 2067        SQLSTATE 23505
+  lock a row to edit   (whole database is locked)      SELECT ... FOR UPDATE
+  local date of a row  UTC offsets from Go (no tz db)  AT TIME ZONE 'Europe/Paris'
+  concurrent writers   wait up to 5s (busy_timeout)    row locks
+  schema               migrations/sqlite/*.sql         migrations/postgres/*.sql
+
+Queries are always written with ? and go through rebind() right before they
+are sent, so only Postgres pays for the rewrite.
+*/
+
+// Supported values of DB_DRIVER
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// dialect is everything that differs between the supported databases
+type dialect struct {
+	name       string // DB_DRIVER value, also the folder under migrations/
+	driverName string // name the database/sql driver registered itself with
+
+	// dataSourceParams are added to the data source when opening (see Open)
+	dataSourceParams string
+
+	// numberedPlaceholders: $1, $2 ... instead of ?
+	numberedPlaceholders bool
+
+	// cursorTime is created_at as text that sorts and compares like created_at itself (see cursor.go)
+	cursorTime string
+
+	// matchQuery turns user search input into this database's full-text query syntax
+	matchQuery func(input string) string
+
+	// textMatch is the WHERE condition "entry text matches the full-text query ?"
+	textMatch string
+
+	// searchQuery is the SELECT behind Search: entry columns, snippet, score.
+	// Arguments: full-text query, user id, limit.
+	searchQuery string
+
+	// forUpdate is appended to a SELECT inside a transaction to lock the rows
+	// it read until the transaction ends
+	forUpdate string
+
+	// skipLocked is appended to the SELECT that picks a job to claim: rows another
+	// Claim has locked are passed over instead of waited for (see jobs.go)
+	skipLocked string
+
+	// localDay is created_at as the date it has in loc, with its arguments.
+	// from/to is the range queried: SQLite needs the UTC offsets in it (see analytics.go).
+	localDay func(loc *time.Location, from time.Time, to time.Time) (string, []interface{})
+
+	// periodStart is the first day ("YYYY-MM-DD") of the day, week or month bucket
+	// of the date column day. "week" takes one argument: the first weekday (0 = Sunday).
+	periodStart map[string]string
+
+	// migrationsTable creates schema_migrations (timestamp types differ)
+	migrationsTable string
+
+	// isUniqueViolation reports whether err is a UNIQUE constraint failure
+	isUniqueViolation func(err error) bool
+}
+
+var sqliteDialect = &dialect{
+	name:       DriverSQLite,
+	driverName: "sqlite",
+
+	// SQLite lets one connection write at a time. Without a busy timeout a second
+	// writer (a request while a job worker claims a job) fails at once with
+	// SQLITE_BUSY; with it, it waits for the lock
+	dataSourceParams: "_pragma=busy_timeout(5000)",
+
+	// CAST(... AS TEXT) keeps the stored format, the driver would turn
+	// created_at into a time.Time which no longer compares correctly in SQL
+	cursorTime: `CAST(created_at AS TEXT)`,
+
+	matchQuery: ftsMatchQuery,
+	textMatch:  `id IN (SELECT rowid FROM entries_fts WHERE entries_fts MATCH ?)`,
+
+	// The JOIN back to entries is what scopes results to this user:
+	// the FTS index itself holds every user's text.
+	searchQuery: `SELECT ` + searchEntryColumns + `,
+	                     snippet(entries_fts, 0, '<mark>', '</mark>', '…', 12),
+	                     -bm25(entries_fts) AS score
+	              FROM entries_fts
+	              JOIN entries e ON e.id = entries_fts.rowid
+	              WHERE entries_fts MATCH ? AND e.user_id = ? AND e.deleted_at IS NULL
+	              ORDER BY score DESC, e.created_at DESC
+	              LIMIT ?`,
+
+	// No FOR UPDATE in SQLite: only one transaction can write at a time anyway
+	forUpdate:  ``,
+	skipLocked: ``,
+
+	// date() returns text already; a week starts (weekday - first weekday + 7) % 7 days earlier
+	localDay: sqliteLocalDay,
+	periodStart: map[string]string{
+		"day":   `day`,
+		"week":  `date(day, '-' || ((CAST(strftime('%w', day) AS INTEGER) - ? + 7) % 7) || ' days')`,
+		"month": `strftime('%Y-%m-01', day)`,
+	},
+
+	migrationsTable: `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`,
+
+	// Checks the SQLite extended error code instead of matching the message text
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	},
+}
+
+var postgresDialect = &dialect{
+	name:                 DriverPostgres,
+	driverName:           "pgx",
+	numberedPlaceholders: true,
+
+	// Postgres keeps microseconds - the cursor must too, or rows would be skipped
+	cursorTime: `to_char(created_at, 'YYYY-MM-DD HH24:MI:SS.US')`,
+
+	matchQuery: tsQuery,
+	textMatch:  `text_search @@ to_tsquery('simple', ?)`,
+
+	// ts_headline = snippet(), ts_rank = bm25 (already "higher is better")
+	searchQuery: `SELECT ` + searchEntryColumns + `,
+	                     ts_headline('simple', COALESCE(e.text, ''), q.query,
+	                                 'StartSel=<mark>, StopSel=</mark>, MaxFragments=1, MaxWords=12, MinWords=3, FragmentDelimiter=…'),
+	                     ts_rank(e.text_search, q.query) AS score
+	              FROM entries e, to_tsquery('simple', ?) AS q(query)
+	              WHERE e.text_search @@ q.query AND e.user_id = ? AND e.deleted_at IS NULL
+	              ORDER BY score DESC, e.created_at DESC
+	              LIMIT ?`,
+
+	// Two concurrent edits of the same entry: the second waits until the first
+	// commits, so each revision holds the version it really replaced
+	forUpdate: ` FOR UPDATE`,
+
+	// Two workers claiming at once get two different jobs, not one job and one ErrNotFound
+	skipLocked: ` FOR UPDATE SKIP LOCKED`,
+
+	// to_char: the driver would turn a DATE into a time.Time, not "YYYY-MM-DD"
+	localDay: postgresLocalDay,
+	periodStart: map[string]string{
+		"day":   `to_char(day, 'YYYY-MM-DD')`,
+		"week":  `to_char(day - ((EXTRACT(DOW FROM day)::int - ? + 7) % 7), 'YYYY-MM-DD')`,
+		"month": `to_char(date_trunc('month', day), 'YYYY-MM-DD')`,
+	},
+
+	migrationsTable: `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'utc')
+	);`,
+
+	// 23505 = unique_violation (https://www.postgresql.org/docs/current/errcodes-appendix.html)
+	isUniqueViolation: func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && pgErr.Code == "23505"
+	},
+}
+
+// dialectFor returns the dialect for a DB_DRIVER value
+func dialectFor(driver string) (*dialect, error) {
+	switch driver {
+	case DriverSQLite:
+		return sqliteDialect, nil
+	case DriverPostgres:
+		return postgresDialect, nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q (use %q or %q)", driver, DriverSQLite, DriverPostgres)
+}
+
+// rebind rewrites ? placeholders into $1, $2 ... for databases that need it.
+// A ? inside a quoted string literal is left alone.
+func (d *dialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var sb strings.Builder
+	n := 0
+	inString := false
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			inString = !inString // '' (escaped quote) toggles twice, so it still works
+		case c == '?' && !inString:
+			n++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		postgres string // SQLite always gets the query unchanged
+	}{
+		{"no placeholders", `SELECT 1`, `SELECT 1`},
+		{"numbered in order", `SELECT * FROM entries WHERE user_id = ? AND id < ? LIMIT ?`, `SELECT * FROM entries WHERE user_id = $1 AND id < $2 LIMIT $3`},
+		{"? inside a string literal", `SELECT '?' || text FROM entries WHERE id = ?`, `SELECT '?' || text FROM entries WHERE id = $1`},
+		{"escaped quote inside a literal", `SELECT 'it''s ?' WHERE a = ? AND b = '?'`, `SELECT 'it''s ?' WHERE a = $1 AND b = '?'`},
+		{"more than nine", `VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, `VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`},
+		{"non-ASCII text kept", `SELECT snippet(x, '…') WHERE a = ?`, `SELECT snippet(x, '…') WHERE a = $1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sqliteDialect.rebind(tt.query); got != tt.query {
+				t.Errorf("sqlite: %q, want it unchanged", got)
+			}
+			if got := postgresDialect.rebind(tt.query); got != tt.postgres {
+				t.Errorf("postgres: %q, want %q", got, tt.postgres)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	conn := newMigratedConn(t)
+	ctx := context.Background()
+
+	if _, err := conn.exec(ctx, `INSERT INTO users (email, password_hash) VALUES ('a@b.c', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	_, duplicate := conn.exec(ctx, `INSERT INTO users (email, password_hash) VALUES ('a@b.c', 'x')`)
+	_, notNull := conn.exec(ctx, `INSERT INTO users (email, password_hash) VALUES ('d@e.f', NULL)`)
+	if duplicate == nil || notNull == nil {
+		t.Fatalf("fixture inserts didn't fail: %v, %v", duplicate, notNull)
+	}
+
+	tests := []struct {
+		name     string
+		dialect  *dialect
+		err      error
+		expected bool
+	}{
+		{"sqlite duplicate email", sqliteDialect, duplicate, true},
+		{"sqlite duplicate, wrapped", sqliteDialect, fmt.Errorf("create user: %w", duplicate), true},
+		{"sqlite NOT NULL is another constraint", sqliteDialect, notNull, false},
+		{"sqlite nil", sqliteDialect, nil, false},
+		{"sqlite plain error with the same text", sqliteDialect, errors.New(duplicate.Error()), false},
+		{"sqlite doesn't know Postgres errors", sqliteDialect, &pgconn.PgError{Code: "23505"}, false},
+		{"postgres unique_violation", postgresDialect, &pgconn.PgError{Code: "23505"}, true},
+		{"postgres unique_violation, wrapped", postgresDialect, fmt.Errorf("create user: %w", &pgconn.PgError{Code: "23505"}), true},
+		{"postgres foreign_key_violation", postgresDialect, &pgconn.PgError{Code: "23503"}, false},
+		{"postgres nil", postgresDialect, nil, false},
+		{"postgres doesn't know SQLite errors", postgresDialect, duplicate, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.isUniqueViolation(tt.err); got != tt.expected {
+				t.Errorf("isUniqueViolation(%v) = %v, want %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/models"
+	"strconv"
+	"time"
+)
+
+// SQLEntryRepository is the EntryRepository backed by SQLite or Postgres.
+// Queries are written once with ? placeholders, the dialect of conn fills in
+// the differences (see dialect.go).
+type SQLEntryRepository struct {
+	conn *Conn
+}
+
+// Compile-time check: fails to build if a method of the interface is missing
+var _ EntryRepository = (*SQLEntryRepository)(nil)
+
+// NewSQLEntryRepository creates an entry repository on an open connection (see InitDB)
+func NewSQLEntryRepository(conn *Conn) *SQLEntryRepository {
+	return &SQLEntryRepository{conn: conn}
+}
+
+// entryColumns is the SELECT list scanned by scanEntry.
+// text/mood/category are nullable in the schema, COALESCE keeps Scan from failing on old rows.
+const entryColumns = `id, user_id, COALESCE(text, ''), COALESCE(mood, 0), COALESCE(category, ''), created_at`
+
+// rowScanner is what *sql.Row and *sql.Rows have in common
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEntry reads one entryColumns row; extra destinations are scanned after them
+func scanEntry(row rowScanner, extra ...interface{}) (models.Entry, error) {
+	var e models.Entry
+	dest := append([]interface{}{&e.ID, &e.UserID, &e.Text, &e.Mood, &e.Category, &e.CreatedAt}, extra...)
+	err := row.Scan(dest...)
+	return e, err
+}
+
+// countCacheKey is the Redis hash holding a user's entry counts, one field per filter
+func countCacheKey(userID int64) string {
+	return fmt.Sprintf("count:user:%d", userID)
+}
+
+// invalidateCounts drops every cached count of the user after a write.
+// If existing counts are cached they are now stale - this is called cache invalidation.
+func invalidateCounts(userID int64) {
+	cache.Delete(countCacheKey(userID))
+}
+
+// Create inserts a new entry and its tags
+// Puts new data INTO the database (like adding a new row to an Excel sheet)
+func (r *SQLEntryRepository) Create(ctx context.Context, userID int64, input EntryInput) (int64, error) {
+	var id int64
+
+	// Transaction: the entry and its tags are saved together or not at all
+	err := inTransaction(ctx, r.conn, func(tx *Tx) error {
+		// The ? marks are placeholders (like blanks in a form)
+		// RETURNING id hands back the ID of the inserted row - Postgres drivers
+		// don't support LastInsertId(), SQLite (3.35+) understands RETURNING too
+		query := `INSERT INTO entries (user_id, text, mood, category) VALUES (?, ?, ?, ?) RETURNING id`
+		// "Execute the query and fill in the ? marks with actual values."
+		err := tx.queryRow(ctx, query, userID, input.Text, input.Mood, input.Category).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		return setEntryTags(ctx, tx, userID, id, input.Tags)
+	})
+	if err != nil {
+		// "If something broke, return 0 as ID and the error message."
+		return 0, err
+	}
+
+	invalidateCounts(userID)
+	slog.Debug("Entry inserted", "entry_id", id, "tags", len(input.Tags))
+	return id, nil
+}
+
+// GetByID retrieves one entry of the user
+func (r *SQLEntryRepository) GetByID(ctx context.Context, userID int64, entryID int64) (models.Entry, error) {
+	query := `SELECT ` + entryColumns + ` FROM entries WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
+
+	entry, err := scanEntry(r.conn.queryRow(ctx, query, entryID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Entry{}, err
+	}
+
+	entries := []models.Entry{entry}
+	if err := r.attachTags(ctx, entries); err != nil {
+		return models.Entry{}, err
+	}
+	return entries[0], nil
+}
+
+// List allows users to paginate through entries instead of getting all at once.
+// filter narrows the result; total is the number of entries matching the filter (not just this page).
+func (r *SQLEntryRepository) List(ctx context.Context, userID int64, filter EntryFilter, page int, limit int) ([]models.Entry, int, error) {
+	filterSQL, filterArgs := filter.whereClause(r.conn.dialect)
+
+	total, err := r.count(ctx, userID, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + entryColumns + `
+	          FROM entries
+	          WHERE user_id = ? AND deleted_at IS NULL` + filterSQL + `
+	          ORDER BY created_at DESC, id DESC
+	          LIMIT ? OFFSET ?`
+	args := append([]interface{}{userID}, filterArgs...)
+	args = append(args, limit, (page-1)*limit)
+
+	rows, err := r.conn.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []models.Entry{}
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.attachTags(ctx, entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// count returns how many entries match the filter, cached in Redis for 60 seconds.
+// count:user:<id> is a Redis hash: one field per filter combination ("all" = no filter).
+// Writes just Delete("count:user:<id>"), which drops every filtered count in one go.
+func (r *SQLEntryRepository) count(ctx context.Context, userID int64, filter EntryFilter) (int, error) {
+	cacheKey := countCacheKey(userID)
+	cacheField := filter.cacheField()
+
+	// 1. Try to get from cache
+	if cachedCount, found := cache.GetField(cacheKey, cacheField); found {
+		if total, err := strconv.Atoi(cachedCount); err == nil { // Redis stores strings
+			return total, nil
+		}
+	}
+
+	// 2. Cache miss: query database
+	filterSQL, filterArgs := filter.whereClause(r.conn.dialect)
+	query := `SELECT COUNT(*) FROM entries WHERE user_id = ? AND deleted_at IS NULL` + filterSQL
+	args := append([]interface{}{userID}, filterArgs...)
+
+	var total int
+	if err := r.conn.queryRow(ctx, query, args...).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	// 3. Store in cache for 60 seconds
+	cache.SetField(cacheKey, cacheField, total, 60*time.Second)
+	return total, nil
+}
+
+// ListAfter is the keyset-pagination version of List (see cursor.go).
+// cursor is the next_cursor from the previous page ("" = first page).
+// nextCursor is "" when there are no more entries.
+func (r *SQLEntryRepository) ListAfter(ctx context.Context, userID int64, filter EntryFilter, cursor string, limit int) ([]models.Entry, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	filterSQL, filterArgs := filter.whereClause(r.conn.dialect)
+	args := append([]interface{}{userID}, filterArgs...)
+
+	query := `SELECT ` + entryColumns + `, ` + r.conn.dialect.cursorTime + `
+	          FROM entries
+	          WHERE user_id = ? AND deleted_at IS NULL` + filterSQL
+
+	if after != nil {
+		// Row value comparison: (a, b) < (x, y) means a < x OR (a = x AND b < y)
+		query += ` AND (created_at, id) < (?, ?)`
+		args = append(args, after.CreatedAt, after.ID)
+	}
+
+	// Fetch one extra row: if it exists there is another page
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := r.conn.query(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	entries := []models.Entry{}
+	var nextCursor string
+	var last entryCursor
+	for rows.Next() {
+		var rawCreatedAt string
+		entry, err := scanEntry(rows, &rawCreatedAt)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if len(entries) == limit {
+			// This is the extra row - don't return it, just remember there's more
+			nextCursor = encodeCursor(last)
+			break
+		}
+
+		entries = append(entries, entry)
+		// The text form of created_at (dialect.cursorTime), not entry.CreatedAt:
+		// it compares correctly in SQL and keeps the full precision of the database
+		last = entryCursor{CreatedAt: rawCreatedAt, ID: entry.ID}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if err := r.attachTags(ctx, entries); err != nil {
+		return nil, "", err
+	}
+	return entries, nextCursor, nil
+}
+
+// Update changes an entry only if it belongs to the user
+// input.Tags == nil leaves the tags unchanged, an empty slice removes all tags
+// The version being replaced is kept in entry_revisions (see revisions.go)
+func (r *SQLEntryRepository) Update(ctx context.Context, userID int64, entryID int64, input EntryInput) error {
+	err := inTransaction(ctx, r.conn, func(tx *Tx) error {
+		// ErrNotFound = entry not found, doesn't belong to user OR is in the trash
+		if err := saveRevision(ctx, tx, userID, entryID, RevisionUpdate); err != nil {
+			return err
+		}
+
+		query := `UPDATE entries SET text = ?, mood = ?, category = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
+
+		// Parameters must match placeholder order: text, mood, category, id, user_id
+		_, err := tx.exec(ctx, query, input.Text, input.Mood, input.Category, entryID, userID)
+		if err != nil {
+			return err
+		}
+
+		if input.Tags == nil {
+			return nil
+		}
+		return setEntryTags(ctx, tx, userID, entryID, input.Tags)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCounts(userID)
+	return nil
+}
+
+// Delete moves an entry to the trash only if it belongs to the user.
+// The row stays (Restore can bring it back) until PurgeTrash removes it for good.
+// Like Update, it saves the deleted version in entry_revisions.
+func (r *SQLEntryRepository) Delete(ctx context.Context, userID int64, entryID int64) error {
+	err := inTransaction(ctx, r.conn, func(tx *Tx) error {
+		if err := saveRevision(ctx, tx, userID, entryID, RevisionDelete); err != nil {
+			return err
+		}
+
+		query := `UPDATE entries SET deleted_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
+		_, err := tx.exec(ctx, query, storedTimestamp(time.Now()), entryID, userID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateCounts(userID)
+	return nil
+}
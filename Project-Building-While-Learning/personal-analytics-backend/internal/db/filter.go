@@ -0,0 +1,98 @@
+package db
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EntryFilter narrows down which entries are listed/counted for a user
+// Zero values mean "no filter" for that field
+type EntryFilter struct {
+	Category string    // exact match
+	MoodMin  int       // mood >= MoodMin (0 = no lower bound)
+	MoodMax  int       // mood <= MoodMax (0 = no upper bound)
+	From     time.Time // created_at >= From (inclusive, zero = no bound)
+	To       time.Time // created_at <  To   (exclusive, zero = no bound)
+	Query    string    // free text, every word must match (prefix) - see ftsMatchQuery
+	Tags     []string  // entry must have ALL of these (normalized) tags
+}
+
+// whereClause returns the extra SQL conditions for this filter (each starting
+// with " AND ") and the matching arguments, in placeholder order.
+// Only fixed SQL fragments are concatenated - every value goes through a ? placeholder.
+func (f EntryFilter) whereClause(d *dialect) (string, []interface{}) {
+	var sb strings.Builder
+	var args []interface{}
+
+	if f.Category != "" {
+		sb.WriteString(" AND category = ?")
+		args = append(args, f.Category)
+	}
+	if f.MoodMin > 0 {
+		sb.WriteString(" AND mood >= ?")
+		args = append(args, f.MoodMin)
+	}
+	if f.MoodMax > 0 {
+		sb.WriteString(" AND mood <= ?")
+		args = append(args, f.MoodMax)
+	}
+	if !f.From.IsZero() {
+		sb.WriteString(" AND created_at >= ?")
+		args = append(args, rangeBound(f.From))
+	}
+	if !f.To.IsZero() {
+		sb.WriteString(" AND created_at < ?")
+		args = append(args, rangeBound(f.To))
+	}
+	if f.Query != "" {
+		// Full-text index lookup instead of text LIKE '%q%' (see search.go)
+		sb.WriteString(" AND " + d.textMatch)
+		args = append(args, d.matchQuery(f.Query))
+	}
+	if len(f.Tags) > 0 {
+		clause, tagArgs := tagFilterClause(f.Tags)
+		sb.WriteString(clause)
+		args = append(args, tagArgs...)
+	}
+
+	return sb.String(), args
+}
+
+// cacheField returns a stable string identifying this filter combination.
+// Used as the field name inside the per-user count hash (see SQLEntryRepository.count).
+// url.Values.Encode() sorts keys, so the same filters always give the same string.
+func (f EntryFilter) cacheField() string {
+	v := url.Values{}
+	if f.Category != "" {
+		v.Set("category", f.Category)
+	}
+	if f.MoodMin > 0 {
+		v.Set("mood_min", strconv.Itoa(f.MoodMin))
+	}
+	if f.MoodMax > 0 {
+		v.Set("mood_max", strconv.Itoa(f.MoodMax))
+	}
+	if !f.From.IsZero() {
+		v.Set("from", rangeBound(f.From))
+	}
+	if !f.To.IsZero() {
+		v.Set("to", rangeBound(f.To))
+	}
+	if f.Query != "" {
+		v.Set("q", f.Query)
+	}
+	if len(f.Tags) > 0 {
+		// Sorted copy: tags=a,b and tags=b,a are the same filter
+		tags := append([]string(nil), f.Tags...)
+		sort.Strings(tags)
+		v.Set("tags", strings.Join(tags, ","))
+	}
+
+	if len(v) == 0 {
+		return "all"
+	}
+	return v.Encode()
+}
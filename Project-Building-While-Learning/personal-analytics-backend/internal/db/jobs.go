@@ -0,0 +1,350 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"personal-analytics-backend/internal/models"
+	"strings"
+	"time"
+)
+
+/*
+=== DURABLE JOB QUEUE ===
+
+The worker pool used to be a channel: full → job dropped, restart → queue
+gone. Now every job is a row in the jobs table first:
+
+  AddJob          INSERT                         status pending, run_at = now
+  worker          Claim (one UPDATE)             status running, locked_until = now + visibility timeout
+    ok            Succeed                        status succeeded, payload emptied
+    error         Retry                          status pending, run_at = now + backoff
+    last attempt  Fail                           status failed: the DEAD-LETTER QUEUE
+
+VISIBILITY TIMEOUT: a worker that crashes (or the whole server) never reports
+back. Its job stays "running", but once locked_until has passed Claim treats it
+as due again and another worker takes it. That is what makes delivery
+AT-LEAST-ONCE: a job can run twice (crash after the work, before Succeed), it
+is never lost. Jobs must therefore be safe to repeat.
+
+An expired lease still counts as an attempt: a job that kills its worker every
+time must not be claimed forever. Once attempts reaches max_attempts, Claim
+leaves an expired job alone and FailExpired moves it to failed with a "lease
+expired" error. The worker pool calls FailExpired on a ticker, not on every
+Claim: an idle worker polls every second and shouldn't write each time.
+
+Why the database and not Redis: see worker/worker.go.
+
+Claim is ONE UPDATE ... WHERE id = (oldest due job) RETURNING, so two workers
+can't both get a job:
+  - SQLite: only one write at a time, the second UPDATE sees the job running
+  - Postgres: the subquery SELECTs FOR UPDATE SKIP LOCKED, so the second
+    UPDATE passes over the row the first one locked and takes the next due
+    job instead of waiting for it and finding nothing
+
+Succeed, Retry and Fail only touch a job still locked by the same worker: if a
+slow worker's claim expired and someone else took the job, its late answer
+must not undo the new claim.
+
+=== DEAD-LETTER QUEUE ===
+
+A failed job is not deleted: it keeps its payload and Claim never picks it up
+again, so the failed rows of the jobs table are the dead-letter queue. Every
+failed attempt (Retry and Fail) also adds a row to job_errors, in the same
+transaction, so an admin sees the whole story and not only last_error:
+
+  GET    /admin/jobs/failed              ListFailed
+  GET    /admin/jobs/failed/{id}         Get, with the error history
+  POST   /admin/jobs/failed/{id}/replay  Replay: pending again, attempts start over
+  DELETE /admin/jobs/failed/{id}         Discard
+
+Send_email payloads contain reset links, so a succeeded job keeps no payload
+and old succeeded jobs are deleted (PurgeSucceeded).
+*/
+
+// SQLJobRepository is the JobRepository backed by SQLite or Postgres
+type SQLJobRepository struct {
+	conn *Conn
+}
+
+var _ JobRepository = (*SQLJobRepository)(nil)
+
+// NewSQLJobRepository creates a job repository on an open connection (see InitDB)
+func NewSQLJobRepository(conn *Conn) *SQLJobRepository {
+	return &SQLJobRepository{conn: conn}
+}
+
+// jobDue is the WHERE condition "may be claimed now"; arguments: now, now.
+// An expired lease only if an attempt is left (see FailExpired).
+const jobDue = `((status = 'pending' AND run_at <= ?) OR
+                 (status = 'running' AND locked_until < ? AND attempts < max_attempts))`
+
+// leaseExpiredError is last_error and the job_errors entry of a job whose last
+// attempt never reported back
+const leaseExpiredError = "lease expired: the worker did not finish within the visibility timeout"
+
+// Enqueue adds a pending job
+func (r *SQLJobRepository) Enqueue(ctx context.Context, jobType string, payload []byte, runAt time.Time, maxAttempts int) (int64, error) {
+	query := `INSERT INTO jobs (type, payload, max_attempts, run_at) VALUES (?, ?, ?, ?) RETURNING id`
+
+	var id int64
+	err := r.conn.queryRow(ctx, query, jobType, string(payload), maxAttempts, storedTimestamp(runAt)).Scan(&id)
+	return id, err
+}
+
+// Claim takes the oldest due job for workerID that isn't one of skipTypes
+func (r *SQLJobRepository) Claim(ctx context.Context, workerID string, now time.Time, lockedUntil time.Time, skipTypes []string) (models.Job, error) {
+	at := storedTimestamp(now)
+	args := []any{workerID, storedTimestamp(lockedUntil), at, at}
+
+	skip := ""
+	if len(skipTypes) > 0 {
+		skip = ` AND type NOT IN (?` + strings.Repeat(`, ?`, len(skipTypes)-1) + `)`
+		for _, t := range skipTypes {
+			args = append(args, t)
+		}
+	}
+	args = append(args, at, at)
+
+	// jobDue twice: the subquery finds the job, the outer WHERE makes sure it is
+	// still due when the row is actually updated (see the comment at the top)
+	query := `UPDATE jobs
+	          SET status = 'running', attempts = attempts + 1, locked_by = ?, locked_until = ?
+	          WHERE id = (SELECT id FROM jobs WHERE ` + jobDue + skip + ` ORDER BY run_at, id LIMIT 1` +
+		r.conn.dialect.skipLocked + `)
+	            AND ` + jobDue + `
+	          RETURNING ` + jobColumns
+
+	job, err := scanJob(r.conn.queryRow(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Job{}, ErrNotFound
+	}
+	return job, err
+}
+
+// FailExpired fails the running jobs whose lease expired on their last
+// attempt: the worker crashed or hung, and no attempt is left to give the job
+// to another one. Each gets a job_errors row, like Fail.
+// The UPDATE comes first: with Postgres, a second FailExpired (another server)
+// waits for its row locks and then finds nothing, so no job gets two history rows.
+func (r *SQLJobRepository) FailExpired(ctx context.Context, now time.Time) (int64, error) {
+	at := storedTimestamp(now)
+	var ids []int64
+	err := inTransaction(ctx, r.conn, func(tx *Tx) error {
+		rows, err := tx.query(ctx, `UPDATE jobs SET status = 'failed', last_error = ?, locked_until = NULL, finished_at = ?
+		                            WHERE status = 'running' AND locked_until < ? AND attempts >= max_attempts
+		                            RETURNING id`, leaseExpiredError, at, at)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// locked_by is still the worker whose lease ran out
+		for _, id := range ids {
+			_, err := tx.exec(ctx, `INSERT INTO job_errors (job_id, attempt, error, worker, failed_at)
+			                        SELECT id, attempts, ?, locked_by, ? FROM jobs WHERE id = ?`,
+				leaseExpiredError, at, id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+// Succeed marks the job succeeded and drops its payload
+func (r *SQLJobRepository) Succeed(ctx context.Context, jobID int64, workerID string, finishedAt time.Time) error {
+	query := `UPDATE jobs SET status = 'succeeded', payload = '{}', locked_until = NULL, finished_at = ?
+	          WHERE id = ? AND status = 'running' AND locked_by = ?`
+	return r.changeJob(ctx, query, storedTimestamp(finishedAt), jobID, workerID)
+}
+
+// Retry makes the job pending again from runAt and records the error
+func (r *SQLJobRepository) Retry(ctx context.Context, jobID int64, workerID string, errMsg string, failedAt time.Time, runAt time.Time) error {
+	query := `UPDATE jobs SET status = 'pending', last_error = ?, run_at = ?, locked_until = NULL
+	          WHERE id = ? AND status = 'running' AND locked_by = ?`
+	return r.failClaimed(ctx, jobID, workerID, errMsg, failedAt, query, errMsg, storedTimestamp(runAt), jobID, workerID)
+}
+
+// Fail marks the job failed (dead-letter queue) and records the error
+func (r *SQLJobRepository) Fail(ctx context.Context, jobID int64, workerID string, errMsg string, finishedAt time.Time) error {
+	query := `UPDATE jobs SET status = 'failed', last_error = ?, locked_until = NULL, finished_at = ?
+	          WHERE id = ? AND status = 'running' AND locked_by = ?`
+	return r.failClaimed(ctx, jobID, workerID, errMsg, finishedAt, query, errMsg, storedTimestamp(finishedAt), jobID, workerID)
+}
+
+// failClaimed runs the UPDATE of a failed attempt and adds the error to job_errors
+// in one transaction; ErrNotFound (and no history row) if the claim is gone
+func (r *SQLJobRepository) failClaimed(ctx context.Context, jobID int64, workerID string, errMsg string, failedAt time.Time, query string, args ...any) error {
+	return inTransaction(ctx, r.conn, func(tx *Tx) error {
+		result, err := tx.exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		// attempts was counted by Claim, so it is the number of the attempt that just failed
+		_, err = tx.exec(ctx, `INSERT INTO job_errors (job_id, attempt, error, worker, failed_at)
+		                       SELECT id, attempts, ?, ?, ? FROM jobs WHERE id = ?`,
+			errMsg, workerID, storedTimestamp(failedAt), jobID)
+		return err
+	})
+}
+
+// changeJob runs an UPDATE or DELETE of one job; ErrNotFound if its WHERE no
+// longer matches (the claim is gone, the job isn't failed anymore)
+func (r *SQLJobRepository) changeJob(ctx context.Context, query string, args ...any) error {
+	result, err := r.conn.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Counts returns the number of jobs per status
+func (r *SQLJobRepository) Counts(ctx context.Context) (map[string]int, error) {
+	rows, err := r.conn.query(ctx, `SELECT status, COUNT(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{models.JobPending: 0, models.JobRunning: 0, models.JobSucceeded: 0, models.JobFailed: 0}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// ListFailed returns one page of the dead-letter queue, most recently failed first
+func (r *SQLJobRepository) ListFailed(ctx context.Context, page int, limit int) ([]models.Job, int, error) {
+	var total int
+	if err := r.conn.queryRow(ctx, `SELECT COUNT(*) FROM jobs WHERE status = 'failed'`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + jobColumns + `
+	          FROM jobs
+	          WHERE status = 'failed'
+	          ORDER BY finished_at DESC, id DESC
+	          LIMIT ? OFFSET ?`
+	rows, err := r.conn.query(ctx, query, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, total, rows.Err()
+}
+
+// Get returns a job with its error history
+func (r *SQLJobRepository) Get(ctx context.Context, jobID int64) (models.Job, error) {
+	job, err := scanJob(r.conn.queryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, jobID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Job{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	query := `SELECT attempt, error, worker, failed_at FROM job_errors WHERE job_id = ? ORDER BY id`
+	rows, err := r.conn.query(ctx, query, jobID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.JobError
+		if err := rows.Scan(&e.Attempt, &e.Error, &e.Worker, &e.FailedAt); err != nil {
+			return models.Job{}, err
+		}
+		job.Errors = append(job.Errors, e)
+	}
+	return job, rows.Err()
+}
+
+// Replay puts a failed job back into the queue with a fresh set of attempts.
+// last_error and job_errors stay: if it fails again the history shows both rounds.
+func (r *SQLJobRepository) Replay(ctx context.Context, jobID int64, runAt time.Time) error {
+	query := `UPDATE jobs SET status = 'pending', attempts = 0, run_at = ?, locked_by = '', finished_at = NULL
+	          WHERE id = ? AND status = 'failed'`
+	return r.changeJob(ctx, query, storedTimestamp(runAt), jobID)
+}
+
+// Discard deletes a failed job; its job_errors go with it (trigger / ON DELETE CASCADE)
+func (r *SQLJobRepository) Discard(ctx context.Context, jobID int64) error {
+	return r.changeJob(ctx, `DELETE FROM jobs WHERE id = ? AND status = 'failed'`, jobID)
+}
+
+// PurgeSucceeded deletes old succeeded jobs (job_errors go with them, see Discard);
+// failed ones stay until an admin replays or discards them
+func (r *SQLJobRepository) PurgeSucceeded(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	query := `DELETE FROM jobs WHERE status = 'succeeded' AND finished_at < ?`
+	result, err := r.conn.exec(ctx, query, storedTimestamp(finishedBefore))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// jobColumns is the column list scanJob expects, in order
+const jobColumns = `id, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, finished_at`
+
+// scanJob reads one jobs row (sql.ErrNoRows is passed through)
+func scanJob(row rowScanner) (models.Job, error) {
+	var job models.Job
+	var payload []byte
+	var finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Type, &payload, &job.Status, &job.Attempts, &job.MaxAttempts,
+		&job.LastError, &job.RunAt, &job.CreatedAt, &finishedAt)
+	if err != nil {
+		return models.Job{}, err
+	}
+	job.Payload = payload
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"personal-analytics-backend/internal/models"
+	"testing"
+	"time"
+)
+
+// jobRepos runs the same test against SQLite and the in-memory queue
+var jobRepos = []struct {
+	name string
+	new  func(t *testing.T) JobRepository
+}{
+	{"sqlite", func(t *testing.T) JobRepository { return NewSQLJobRepository(newMigratedConn(t)) }},
+	{"memory", func(t *testing.T) JobRepository { return NewMemoryJobRepository() }},
+}
+
+// jobTestBase is "now" at the start of every job test; leases last jobTestLease
+var jobTestBase = time.Date(2026, 1, 12, 10, 0, 0, 0, time.UTC)
+
+const jobTestLease = time.Minute
+
+func TestClaim(t *testing.T) {
+	type job struct {
+		jobType     string
+		runAt       time.Duration // after jobTestBase
+		maxAttempts int
+	}
+	type claim struct {
+		at       time.Duration // after jobTestBase; the lease ends jobTestLease later
+		skip     []string
+		want     int64 // job id, 0 = ErrNotFound
+		attempts int
+	}
+
+	tests := []struct {
+		name   string
+		jobs   []job
+		claims []claim
+	}{
+		{
+			name: "oldest run_at first, then lowest id",
+			jobs: []job{{"a", 10 * time.Second, 3}, {"a", 0, 3}, {"a", 0, 3}},
+			claims: []claim{
+				{at: 10 * time.Second, want: 2, attempts: 1},
+				{at: 10 * time.Second, want: 3, attempts: 1},
+				{at: 10 * time.Second, want: 1, attempts: 1},
+				{at: 10 * time.Second, want: 0},
+			},
+		},
+		{
+			name: "a job isn't claimed before run_at",
+			jobs: []job{{"a", time.Hour, 3}},
+			claims: []claim{
+				{at: time.Hour - time.Second, want: 0},
+				{at: time.Hour, want: 1, attempts: 1},
+			},
+		},
+		{
+			name: "skipped types stay pending",
+			jobs: []job{{"slow", 0, 3}, {"fast", 0, 3}},
+			claims: []claim{
+				{skip: []string{"slow"}, want: 2, attempts: 1},
+				{skip: []string{"slow", "other"}, want: 0},
+				{want: 1, attempts: 1},
+			},
+		},
+		{
+			name: "an expired lease is claimed again while attempts are left",
+			jobs: []job{{"a", 0, 2}},
+			claims: []claim{
+				{want: 1, attempts: 1},
+				{at: jobTestLease, want: 0}, // locked_until not passed yet
+				{at: jobTestLease + time.Second, want: 1, attempts: 2},
+				{at: 5 * jobTestLease, want: 0}, // last attempt: left to FailExpired
+			},
+		},
+	}
+	for _, r := range jobRepos {
+		t.Run(r.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					repo := r.new(t)
+					ctx := context.Background()
+					for _, j := range tt.jobs {
+						if _, err := repo.Enqueue(ctx, j.jobType, []byte(`{}`), jobTestBase.Add(j.runAt), j.maxAttempts); err != nil {
+							t.Fatal(err)
+						}
+					}
+
+					for i, c := range tt.claims {
+						now := jobTestBase.Add(c.at)
+						got, err := repo.Claim(ctx, "worker-1", now, now.Add(jobTestLease), c.skip)
+						if c.want == 0 {
+							if !errors.Is(err, ErrNotFound) {
+								t.Fatalf("claim %d: job %d (%v), want ErrNotFound", i+1, got.ID, err)
+							}
+							continue
+						}
+						if err != nil {
+							t.Fatalf("claim %d: %v", i+1, err)
+						}
+						if got.ID != c.want || got.Attempts != c.attempts || got.Status != models.JobRunning {
+							t.Errorf("claim %d: job %d attempt %d %s, want job %d attempt %d running",
+								i+1, got.ID, got.Attempts, got.Status, c.want, c.attempts)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestFailExpired(t *testing.T) {
+	for _, r := range jobRepos {
+		t.Run(r.name, func(t *testing.T) {
+			repo := r.new(t)
+			ctx := context.Background()
+			at := func(d time.Duration) time.Time { return jobTestBase.Add(d) }
+
+			// 1: one attempt, 2: two attempts, 3: one attempt but it succeeds
+			for _, maxAttempts := range []int{1, 2, 1} {
+				if _, err := repo.Enqueue(ctx, "a", []byte(`{}`), jobTestBase, maxAttempts); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for range 3 {
+				if _, err := repo.Claim(ctx, "worker-1", at(0), at(jobTestLease), nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := repo.Succeed(ctx, 3, "worker-1", at(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+
+			steps := []struct {
+				name string
+				at   time.Duration
+				want int64
+			}{
+				{"leases still running", jobTestLease, 0},
+				{"only the job without attempts left", 2 * jobTestLease, 1},
+				{"a second call finds nothing", 2 * jobTestLease, 0},
+			}
+			for _, s := range steps {
+				if n, err := repo.FailExpired(ctx, at(s.at)); err != nil || n != s.want {
+					t.Fatalf("%s: %d failed (%v), want %d", s.name, n, err, s.want)
+				}
+			}
+			checkLeaseExpired(t, repo, 1, models.JobError{Attempt: 1, Worker: "worker-1", FailedAt: at(2 * jobTestLease)})
+
+			// The crashed worker comes back: its answer must not touch the failed job
+			if err := repo.Fail(ctx, 1, "worker-1", "late", at(3*jobTestLease)); !errors.Is(err, ErrNotFound) {
+				t.Errorf("late Fail: %v, want ErrNotFound", err)
+			}
+			if err := repo.Succeed(ctx, 1, "worker-1", at(3*jobTestLease)); !errors.Is(err, ErrNotFound) {
+				t.Errorf("late Succeed: %v, want ErrNotFound", err)
+			}
+			checkLeaseExpired(t, repo, 1, models.JobError{Attempt: 1, Worker: "worker-1", FailedAt: at(2 * jobTestLease)})
+
+			// Job 2 had an attempt left: another worker takes it, and its lease runs out too
+			job, err := repo.Claim(ctx, "worker-2", at(2*jobTestLease), at(3*jobTestLease), nil)
+			if err != nil || job.ID != 2 || job.Attempts != 2 {
+				t.Fatalf("re-claim: job %d attempt %d (%v), want job 2 attempt 2", job.ID, job.Attempts, err)
+			}
+			if err := repo.Succeed(ctx, 2, "worker-1", at(3*jobTestLease)); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Succeed by the first worker: %v, want ErrNotFound", err)
+			}
+			if n, err := repo.FailExpired(ctx, at(4*jobTestLease)); err != nil || n != 1 {
+				t.Fatalf("second lease: %d failed (%v), want 1", n, err)
+			}
+			checkLeaseExpired(t, repo, 2, models.JobError{Attempt: 2, Worker: "worker-2", FailedAt: at(4 * jobTestLease)})
+		})
+	}
+}
+
+// checkLeaseExpired checks that the job is failed with a single "lease expired" error like want
+func checkLeaseExpired(t *testing.T, repo JobRepository, jobID int64, want models.JobError) {
+	t.Helper()
+	job, err := repo.Get(context.Background(), jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != models.JobFailed || job.LastError != leaseExpiredError {
+		t.Errorf("job %d: %s %q, want failed with the lease error", jobID, job.Status, job.LastError)
+	}
+	if len(job.Errors) != 1 {
+		t.Fatalf("job %d: %d errors, want 1", jobID, len(job.Errors))
+	}
+	got := job.Errors[0]
+	if got.Attempt != want.Attempt || got.Worker != want.Worker || got.Error != leaseExpiredError || !got.FailedAt.Equal(want.FailedAt) {
+		t.Errorf("job %d: error %+v, want %+v", jobID, got, want)
+	}
+}
@@ -0,0 +1,1349 @@
+package db
+
+/*
+=== IN-MEMORY REPOSITORIES (FAKES) ===
+
+MemoryEntryRepository, MemoryUserRepository, MemoryRefreshTokenRepository,
+MemoryUserTokenRepository, MemoryAPIKeyRepository, MemoryJobRepository and
+MemoryScheduleRepository implement the same interfaces as the SQL repositories, but keep everything in
+maps guarded by a mutex. Handlers can be exercised with httptest without a
+database file:
+
+	entries := db.NewMemoryEntryRepository()
+	users := db.NewMemoryUserRepository()
+	tokens := db.NewMemoryRefreshTokenRepository()
+	userTokens := db.NewMemoryUserTokenRepository()
+	h := handlers.New(entries, users, tokens, userTokens, db.NewMemoryAPIKeyRepository(), nil)
+	worker.Store = db.NewMemoryJobRepository() // the default if never set
+
+They follow the SQL behaviour closely (user scoping, ErrNotFound, filters,
+ordering, cursors, tag rules) but are NOT a full database:
+  - search matches word prefixes case-insensitively, scoring is a simple
+    "number of matching words" instead of bm25
+  - created_at and deleted_at are the wall clock truncated to seconds, like CURRENT_TIMESTAMP
+  - a MemoryScheduleRepository only coordinates the schedulers sharing it, which
+    are in one process; the real lease is between servers
+  - nothing is persisted, everything is gone when the process exits
+*/
+
+import (
+	"context"
+	"maps"
+	"personal-analytics-backend/internal/models"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryEntryRepository is an EntryRepository that lives in memory
+type MemoryEntryRepository struct {
+	mu        sync.RWMutex
+	entries   map[int64]models.Entry
+	nextID    int64
+	revisions []models.EntryRevision // oldest first, like the ids in entry_revisions
+	lastRevID int64
+}
+
+var _ EntryRepository = (*MemoryEntryRepository)(nil)
+
+// NewMemoryEntryRepository creates an empty in-memory entry repository
+func NewMemoryEntryRepository() *MemoryEntryRepository {
+	return &MemoryEntryRepository{
+		entries: make(map[int64]models.Entry),
+		nextID:  1,
+	}
+}
+
+// Create stores a new entry
+func (m *MemoryEntryRepository) Create(ctx context.Context, userID int64, input EntryInput) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+
+	m.entries[id] = models.Entry{
+		ID:        id,
+		UserID:    userID,
+		Text:      input.Text,
+		Mood:      input.Mood,
+		Category:  input.Category,
+		Tags:      sortedTags(input.Tags),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	return id, nil
+}
+
+// GetByID returns one entry of the user, or ErrNotFound
+func (m *MemoryEntryRepository) GetByID(ctx context.Context, userID int64, entryID int64) (models.Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.entries[entryID]
+	if !ok || e.UserID != userID || e.DeletedAt != nil {
+		return models.Entry{}, ErrNotFound
+	}
+	return copyEntry(e), nil
+}
+
+// List returns one page of matching entries, newest first, and the total
+func (m *MemoryEntryRepository) List(ctx context.Context, userID int64, filter EntryFilter, page int, limit int) ([]models.Entry, int, error) {
+	matching := m.matching(userID, filter)
+
+	start := (page - 1) * limit
+	if start > len(matching) {
+		start = len(matching)
+	}
+	end := start + limit
+	if end > len(matching) {
+		end = len(matching)
+	}
+	return matching[start:end], len(matching), nil
+}
+
+// ListAfter returns the page of matching entries after cursor (see cursor.go)
+func (m *MemoryEntryRepository) ListAfter(ctx context.Context, userID int64, filter EntryFilter, cursor string, limit int) ([]models.Entry, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries := []models.Entry{}
+	var nextCursor string
+	for _, e := range m.matching(userID, filter) {
+		pos := entryCursor{CreatedAt: e.CreatedAt.Format(storedTimeLayout), ID: e.ID}
+		if after != nil && !cursorBefore(pos, *after) {
+			continue
+		}
+		if len(entries) == limit {
+			last := entries[len(entries)-1]
+			nextCursor = encodeCursor(entryCursor{CreatedAt: last.CreatedAt.Format(storedTimeLayout), ID: last.ID})
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries, nextCursor, nil
+}
+
+// Update changes an entry of the user, or returns ErrNotFound
+func (m *MemoryEntryRepository) Update(ctx context.Context, userID int64, entryID int64, input EntryInput) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[entryID]
+	if !ok || e.UserID != userID || e.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	m.saveRevision(ctx, e, RevisionUpdate)
+	e.Text = input.Text
+	e.Mood = input.Mood
+	e.Category = input.Category
+	if input.Tags != nil {
+		e.Tags = sortedTags(input.Tags)
+	}
+	m.entries[entryID] = e
+	return nil
+}
+
+// Delete moves an entry of the user to the trash, or returns ErrNotFound
+func (m *MemoryEntryRepository) Delete(ctx context.Context, userID int64, entryID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[entryID]
+	if !ok || e.UserID != userID || e.DeletedAt != nil {
+		return ErrNotFound
+	}
+	m.saveRevision(ctx, e, RevisionDelete)
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	e.DeletedAt = &deletedAt
+	m.entries[entryID] = e
+	return nil
+}
+
+// ListTrash returns one page of the user's trashed entries, most recently deleted first
+func (m *MemoryEntryRepository) ListTrash(ctx context.Context, userID int64, page int, limit int) ([]models.Entry, int, error) {
+	m.mu.RLock()
+	trashed := []models.Entry{}
+	for _, e := range m.entries {
+		if e.UserID == userID && e.DeletedAt != nil {
+			trashed = append(trashed, copyEntry(e))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(trashed, func(i, j int) bool {
+		if !trashed[i].DeletedAt.Equal(*trashed[j].DeletedAt) {
+			return trashed[i].DeletedAt.After(*trashed[j].DeletedAt)
+		}
+		return trashed[i].ID > trashed[j].ID
+	})
+
+	start := min((page-1)*limit, len(trashed))
+	end := min(start+limit, len(trashed))
+	return trashed[start:end], len(trashed), nil
+}
+
+// Restore takes an entry of the user out of the trash, or returns ErrNotFound
+func (m *MemoryEntryRepository) Restore(ctx context.Context, userID int64, entryID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[entryID]
+	if !ok || e.UserID != userID || e.DeletedAt == nil {
+		return ErrNotFound
+	}
+	e.DeletedAt = nil
+	m.entries[entryID] = e
+	return nil
+}
+
+// PurgeTrash permanently deletes entries (of all users) trashed before deletedBefore
+func (m *MemoryEntryRepository) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, e := range m.entries {
+		if e.DeletedAt != nil && e.DeletedAt.Before(deletedBefore) {
+			delete(m.entries, id)
+			purged++
+		}
+	}
+
+	// Their history goes with them, like the trigger / ON DELETE CASCADE
+	kept := m.revisions[:0]
+	for _, rev := range m.revisions {
+		if _, ok := m.entries[rev.EntryID]; ok {
+			kept = append(kept, rev)
+		}
+	}
+	m.revisions = kept
+	return purged, nil
+}
+
+// saveRevision stores e as the version replaced by action (caller holds the write lock)
+func (m *MemoryEntryRepository) saveRevision(ctx context.Context, e models.Entry, action string) {
+	m.lastRevID++
+	m.revisions = append(m.revisions, models.EntryRevision{
+		ID:        m.lastRevID,
+		EntryID:   e.ID,
+		Text:      e.Text,
+		Mood:      e.Mood,
+		Category:  e.Category,
+		Tags:      append([]string{}, e.Tags...),
+		Action:    action,
+		RequestID: requestIDFromContext(ctx),
+		ChangedAt: time.Now().UTC().Truncate(time.Second),
+	})
+}
+
+// History returns the saved versions of an entry of the user, newest first
+func (m *MemoryEntryRepository) History(ctx context.Context, userID int64, entryID int64) ([]models.EntryRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.entries[entryID]
+	if !ok || e.UserID != userID {
+		return nil, ErrNotFound
+	}
+
+	revisions := []models.EntryRevision{}
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		if rev := m.revisions[i]; rev.EntryID == entryID {
+			rev.Tags = append([]string{}, rev.Tags...)
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions, nil
+}
+
+// Search returns entries containing every word of q (prefix match), most matching words first
+func (m *MemoryEntryRepository) Search(ctx context.Context, userID int64, q string, limit int) ([]models.SearchResult, error) {
+	words := searchWords(q)
+
+	results := []models.SearchResult{}
+	for _, e := range m.matching(userID, EntryFilter{Query: q}) {
+		snippet, hits := highlightWords(e.Text, words)
+		results = append(results, models.SearchResult{Entry: e, Snippet: snippet, Score: float64(hits)})
+	}
+
+	// Stable: equal scores keep the newest-first order from matching()
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Tags counts how many of the user's entries use each tag
+func (m *MemoryEntryRepository) Tags(ctx context.Context, userID int64) ([]models.TagCount, error) {
+	counts := map[string]int{}
+	for _, e := range m.matching(userID, EntryFilter{}) {
+		for _, t := range e.Tags {
+			counts[t]++
+		}
+	}
+
+	tags := []models.TagCount{}
+	for name, count := range counts {
+		tags = append(tags, models.TagCount{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// MoodTrend groups the user's mood by day, week or month of cal over [from, to)
+func (m *MemoryEntryRepository) MoodTrend(ctx context.Context, userID int64, from time.Time, to time.Time, bucket string, cal Calendar) ([]models.MoodBucket, error) {
+	if !IsValidBucket(bucket) {
+		return nil, ErrInvalidBucket
+	}
+
+	trend := moodTrend{}
+	for _, e := range m.matching(userID, EntryFilter{From: from, To: to}) {
+		trend.add(cal, bucket, e.CreatedAt, e.Mood)
+	}
+	return trend.buckets(), nil
+}
+
+// MoodSummary is count/avg/min/max mood over [from, to)
+func (m *MemoryEntryRepository) MoodSummary(ctx context.Context, userID int64, from time.Time, to time.Time) (models.MoodSummary, error) {
+	var s moodStats
+	for _, e := range m.matching(userID, EntryFilter{From: from, To: to}) {
+		s.add(e.Mood)
+	}
+
+	if s.count == 0 {
+		return models.MoodSummary{}, nil
+	}
+	return models.MoodSummary{
+		Count:   s.count,
+		AvgMood: round2(float64(s.sum) / float64(s.count)),
+		MinMood: s.min,
+		MaxMood: s.max,
+	}, nil
+}
+
+// CategoryBreakdown computes the same per-category report as the SQL version
+func (m *MemoryEntryRepository) CategoryBreakdown(ctx context.Context, userID int64, from time.Time, to time.Time) (models.CategoryReport, error) {
+	type categorySums struct {
+		count      int
+		sum, sumSq int64
+	}
+
+	byCategory := map[string]*categorySums{}
+	var total categorySums
+	for _, e := range m.matching(userID, EntryFilter{From: from, To: to}) {
+		c := byCategory[e.Category]
+		if c == nil {
+			c = &categorySums{}
+			byCategory[e.Category] = c
+		}
+		mood := int64(e.Mood)
+		for _, s := range []*categorySums{c, &total} {
+			s.count++
+			s.sum += mood
+			s.sumSq += mood * mood
+		}
+	}
+
+	report := models.CategoryReport{
+		Count:      total.count,
+		Categories: []models.CategoryStats{},
+	}
+	if total.count == 0 {
+		return report, nil
+	}
+
+	overallAvg, overallStdDev := meanAndStdDev(total.count, total.sum, total.sumSq)
+	report.AvgMood = round2(overallAvg)
+	report.StdDevMood = round2(overallStdDev)
+
+	for category, c := range byCategory {
+		avg, stdDev := meanAndStdDev(c.count, c.sum, c.sumSq)
+		report.Categories = append(report.Categories, models.CategoryStats{
+			Category:        category,
+			Count:           c.count,
+			AvgMood:         round2(avg),
+			StdDevMood:      round2(stdDev),
+			DiffFromOverall: round2(avg - overallAvg),
+		})
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		a, b := report.Categories[i], report.Categories[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Category < b.Category
+	})
+	return report, nil
+}
+
+// Stats counts the entries of all users
+func (m *MemoryEntryRepository) Stats(ctx context.Context) (models.EntryStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var s models.EntryStats
+	since := time.Now().Add(-24 * time.Hour)
+	for _, e := range m.entries {
+		switch {
+		case e.DeletedAt != nil:
+			s.InTrash++
+		case !e.CreatedAt.Before(since):
+			s.Total++
+			s.CreatedLast24h++
+		default:
+			s.Total++
+		}
+	}
+	return s, nil
+}
+
+// Export returns copies of all the user's entries, trash included, oldest first
+func (m *MemoryEntryRepository) Export(ctx context.Context, userID int64) ([]models.Entry, error) {
+	m.mu.RLock()
+	entries := []models.Entry{}
+	for _, e := range m.entries {
+		if e.UserID == userID {
+			entries = append(entries, copyEntry(e))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// PurgeUser permanently deletes all the user's entries and their history
+func (m *MemoryEntryRepository) PurgeUser(ctx context.Context, userID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := map[int64]bool{}
+	for id, e := range m.entries {
+		if e.UserID == userID {
+			delete(m.entries, id)
+			purged[id] = true
+		}
+	}
+
+	kept := m.revisions[:0]
+	for _, rev := range m.revisions {
+		if !purged[rev.EntryID] {
+			kept = append(kept, rev)
+		}
+	}
+	m.revisions = kept
+	return int64(len(purged)), nil
+}
+
+// matching returns copies of the user's entries that pass filter (trash excluded),
+// ordered like the SQL queries: created_at DESC, id DESC
+func (m *MemoryEntryRepository) matching(userID int64, filter EntryFilter) []models.Entry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []models.Entry{}
+	for _, e := range m.entries {
+		if e.UserID == userID && e.DeletedAt == nil && matchesFilter(e, filter) {
+			entries = append(entries, copyEntry(e))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.After(entries[j].CreatedAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	return entries
+}
+
+// matchesFilter is EntryFilter.whereClause evaluated in Go
+func matchesFilter(e models.Entry, f EntryFilter) bool {
+	createdAt := e.CreatedAt.Format(storedTimeLayout)
+
+	switch {
+	case f.Category != "" && e.Category != f.Category:
+		return false
+	case f.MoodMin > 0 && e.Mood < f.MoodMin:
+		return false
+	case f.MoodMax > 0 && e.Mood > f.MoodMax:
+		return false
+	// Plain string comparison, same as SQLite comparing the stored TEXT
+	case !f.From.IsZero() && createdAt < rangeBound(f.From):
+		return false
+	case !f.To.IsZero() && createdAt >= rangeBound(f.To):
+		return false
+	}
+
+	if f.Query != "" {
+		// Every query word must match at least one word of the text (FTS implicit AND)
+		for _, w := range searchWords(f.Query) {
+			if _, hits := highlightWords(e.Text, []string{w}); hits == 0 {
+				return false
+			}
+		}
+	}
+
+	for _, want := range f.Tags {
+		found := false
+		for _, t := range e.Tags {
+			if t == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// highlightWords wraps every word of text that starts with one of words in <mark></mark>
+// (case-insensitive) and returns the result and how many words were wrapped
+func highlightWords(text string, words []string) (string, int) {
+	hits := 0
+	var sb strings.Builder
+	for i, field := range strings.Fields(text) {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if startsWithAny(field, words) {
+			sb.WriteString("<mark>" + field + "</mark>")
+			hits++
+		} else {
+			sb.WriteString(field)
+		}
+	}
+	return sb.String(), hits
+}
+
+// startsWithAny reports whether one of the words of field starts with one of prefixes
+func startsWithAny(field string, prefixes []string) bool {
+	for _, w := range searchWords(field) {
+		w = strings.ToLower(w)
+		for _, p := range prefixes {
+			if strings.HasPrefix(w, strings.ToLower(p)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cursorBefore reports whether a comes after b in created_at DESC, id DESC order,
+// i.e. (a.CreatedAt, a.ID) < (b.CreatedAt, b.ID)
+func cursorBefore(a, b entryCursor) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt < b.CreatedAt
+	}
+	return a.ID < b.ID
+}
+
+// sortedTags copies tags sorted by name, like loadEntryTags returns them
+func sortedTags(tags []string) []string {
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// copyEntry returns e with its own Tags slice and DeletedAt, so callers can't modify the stored entry
+func copyEntry(e models.Entry) models.Entry {
+	e.Tags = append([]string{}, e.Tags...)
+	if e.DeletedAt != nil {
+		deletedAt := *e.DeletedAt
+		e.DeletedAt = &deletedAt
+	}
+	return e
+}
+
+// MemoryUserRepository is a UserRepository that lives in memory
+type MemoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[int64]models.User
+	nextID int64
+}
+
+var _ UserRepository = (*MemoryUserRepository)(nil)
+
+// NewMemoryUserRepository creates an empty in-memory user repository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:  make(map[int64]models.User),
+		nextID: 1,
+	}
+}
+
+// Create stores a new user, or returns ErrEmailTaken
+func (m *MemoryUserRepository) Create(ctx context.Context, email string, passwordHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return 0, ErrEmailTaken
+		}
+	}
+
+	id := m.nextID
+	m.nextID++
+	m.users[id] = models.User{
+		ID:           id,
+		Email:        email,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+		// the column defaults
+		Role:       models.RoleUser,
+		Timezone:   "UTC",
+		WeekStart:  "monday",
+		MoodLabels: map[int]string{},
+	}
+	return id, nil
+}
+
+// GetByEmail returns the user with this email, or ErrNotFound
+func (m *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return copyUser(u), nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// GetByID returns the user with this id, or ErrNotFound
+func (m *MemoryUserRepository) GetByID(ctx context.Context, userID int64) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[userID]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return copyUser(u), nil
+}
+
+// SetPassword replaces the user's password hash, or ErrNotFound
+func (m *MemoryUserRepository) SetPassword(ctx context.Context, userID int64, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	u.PasswordHash = passwordHash
+	m.users[userID] = u
+	return nil
+}
+
+// UpdateProfile replaces the user's profile fields, or ErrNotFound
+func (m *MemoryUserRepository) UpdateProfile(ctx context.Context, userID int64, profile ProfileInput) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	u.DisplayName = profile.DisplayName
+	u.Timezone = profile.Timezone
+	u.WeekStart = profile.WeekStart
+	u.MoodLabels = maps.Clone(profile.MoodLabels)
+	if u.MoodLabels == nil {
+		u.MoodLabels = map[int]string{} // scanUser does the same
+	}
+	m.users[userID] = u
+	return nil
+}
+
+// MarkEmailVerified sets EmailVerifiedAt once, or ErrNotFound
+func (m *MemoryUserRepository) MarkEmailVerified(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if u.EmailVerifiedAt == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		u.EmailVerifiedAt = &now
+		m.users[userID] = u
+	}
+	return nil
+}
+
+// List returns one page of all users, oldest first
+func (m *MemoryUserRepository) List(ctx context.Context, page int, limit int) ([]models.User, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	all := make([]models.User, 0, len(m.users))
+	for _, u := range m.users {
+		all = append(all, copyUser(u))
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+
+	start := min((page-1)*limit, len(all))
+	end := min(start+limit, len(all))
+	return append([]models.User{}, all[start:end]...), len(all), nil
+}
+
+// SetRole changes the user's role, or ErrNotFound
+func (m *MemoryUserRepository) SetRole(ctx context.Context, userID int64, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Role = role
+	m.users[userID] = u
+	return nil
+}
+
+// SetDisabled sets DisabledAt once, or clears it, or ErrNotFound
+func (m *MemoryUserRepository) SetDisabled(ctx context.Context, userID int64, disabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	switch {
+	case !disabled:
+		u.DisabledAt = nil
+	case u.DisabledAt == nil:
+		now := time.Now().UTC().Truncate(time.Second)
+		u.DisabledAt = &now
+	}
+	m.users[userID] = u
+	return nil
+}
+
+// Stats counts all accounts
+func (m *MemoryUserRepository) Stats(ctx context.Context) (models.UserStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var s models.UserStats
+	for _, u := range m.users {
+		s.Total++
+		if u.Role == models.RoleAdmin {
+			s.Admins++
+		}
+		if u.DisabledAt != nil {
+			s.Disabled++
+		}
+		if u.EmailVerifiedAt != nil {
+			s.Verified++
+		}
+	}
+	return s, nil
+}
+
+// Delete removes the account, or ErrNotFound.
+// Its tokens and API keys live in their own memory repositories: revoke them there.
+func (m *MemoryUserRepository) Delete(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.users, userID)
+	return nil
+}
+
+// copyUser returns u with its own MoodLabels, so callers can't modify the stored user
+func copyUser(u models.User) models.User {
+	u.MoodLabels = maps.Clone(u.MoodLabels)
+	return u
+}
+
+// MemoryRefreshTokenRepository is a RefreshTokenRepository that lives in memory
+type MemoryRefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*memoryRefreshToken // by token hash
+}
+
+type memoryRefreshToken struct {
+	userID    int64
+	familyID  string
+	expiresAt time.Time
+	used      bool
+	revoked   bool
+}
+
+var _ RefreshTokenRepository = (*MemoryRefreshTokenRepository)(nil)
+
+// NewMemoryRefreshTokenRepository creates an empty in-memory refresh token repository
+func NewMemoryRefreshTokenRepository() *MemoryRefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{tokens: make(map[string]*memoryRefreshToken)}
+}
+
+// Create stores the first token of a new family
+func (m *MemoryRefreshTokenRepository) Create(ctx context.Context, userID int64, familyID string, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[tokenHash] = &memoryRefreshToken{userID: userID, familyID: familyID, expiresAt: expiresAt}
+	return nil
+}
+
+// Rotate follows the same rules as SQLRefreshTokenRepository.Rotate
+func (m *MemoryRefreshTokenRepository) Rotate(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[oldHash]
+	switch {
+	case !ok || t.revoked:
+		return 0, ErrTokenInvalid
+	case t.used:
+		m.revokeFamily(t.familyID)
+		return 0, ErrTokenReused
+	case !t.expiresAt.After(time.Now()):
+		return 0, ErrTokenInvalid
+	}
+
+	t.used = true
+	m.tokens[newHash] = &memoryRefreshToken{userID: t.userID, familyID: t.familyID, expiresAt: expiresAt}
+	return t.userID, nil
+}
+
+// RevokeFamily revokes every token in the family of tokenHash and returns its user
+func (m *MemoryRefreshTokenRepository) RevokeFamily(ctx context.Context, tokenHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[tokenHash]
+	if !ok {
+		return 0, ErrTokenInvalid
+	}
+	m.revokeFamily(t.familyID)
+	return t.userID, nil
+}
+
+// RevokeUser revokes every token of the user
+func (m *MemoryRefreshTokenRepository) RevokeUser(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tokens {
+		if t.userID == userID {
+			t.revoked = true
+		}
+	}
+	return nil
+}
+
+// revokeFamily marks every token of the family revoked (caller holds the lock)
+func (m *MemoryRefreshTokenRepository) revokeFamily(familyID string) {
+	for _, t := range m.tokens {
+		if t.familyID == familyID {
+			t.revoked = true
+		}
+	}
+}
+
+// MemoryUserTokenRepository is a UserTokenRepository that lives in memory
+type MemoryUserTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*memoryUserToken // by token hash
+}
+
+type memoryUserToken struct {
+	userID    int64
+	purpose   string
+	expiresAt time.Time
+	used      bool
+}
+
+var _ UserTokenRepository = (*MemoryUserTokenRepository)(nil)
+
+// NewMemoryUserTokenRepository creates an empty in-memory user token repository
+func NewMemoryUserTokenRepository() *MemoryUserTokenRepository {
+	return &MemoryUserTokenRepository{tokens: make(map[string]*memoryUserToken)}
+}
+
+// Create retires the user's unused tokens of this purpose and stores the new one
+func (m *MemoryUserTokenRepository) Create(ctx context.Context, userID int64, purpose string, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tokens {
+		if t.userID == userID && t.purpose == purpose {
+			t.used = true
+		}
+	}
+	m.tokens[tokenHash] = &memoryUserToken{userID: userID, purpose: purpose, expiresAt: expiresAt}
+	return nil
+}
+
+// Consume follows the same rules as SQLUserTokenRepository.Consume
+func (m *MemoryUserTokenRepository) Consume(ctx context.Context, purpose string, tokenHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[tokenHash]
+	if !ok || t.used || t.purpose != purpose || !t.expiresAt.After(time.Now()) {
+		return 0, ErrTokenInvalid
+	}
+	t.used = true
+	return t.userID, nil
+}
+
+// MemoryAPIKeyRepository is an APIKeyRepository that lives in memory
+type MemoryAPIKeyRepository struct {
+	mu     sync.Mutex
+	keys   map[int64]*memoryAPIKey
+	nextID int64
+}
+
+type memoryAPIKey struct {
+	key  models.APIKey
+	hash string
+}
+
+var _ APIKeyRepository = (*MemoryAPIKeyRepository)(nil)
+
+// NewMemoryAPIKeyRepository creates an empty in-memory API key repository
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{keys: make(map[int64]*memoryAPIKey), nextID: 1}
+}
+
+// Create stores a new key
+func (m *MemoryAPIKeyRepository) Create(ctx context.Context, userID int64, name string, prefix string, keyHash string, scopes []string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := models.APIKey{
+		ID:        m.nextID,
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    append([]string{}, scopes...),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	m.nextID++
+	m.keys[key.ID] = &memoryAPIKey{key: key, hash: keyHash}
+	return key, nil
+}
+
+// List returns the user's keys, newest first
+func (m *MemoryAPIKeyRepository) List(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := []models.APIKey{}
+	for _, k := range m.keys {
+		if k.key.UserID == userID {
+			keys = append(keys, k.key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+// Revoke sets RevokedAt on one of the user's active keys, or ErrNotFound
+func (m *MemoryAPIKeyRepository) Revoke(ctx context.Context, userID int64, keyID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.keys[keyID]
+	if !ok || k.key.UserID != userID || k.key.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	k.key.RevokedAt = &now
+	return nil
+}
+
+// RevokeUser sets RevokedAt on all of the user's active keys
+func (m *MemoryAPIKeyRepository) RevokeUser(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, k := range m.keys {
+		if k.key.UserID == userID && k.key.RevokedAt == nil {
+			k.key.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// Authenticate returns the active key with this hash, or ErrTokenInvalid
+func (m *MemoryAPIKeyRepository) Authenticate(ctx context.Context, keyHash string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range m.keys {
+		if k.hash == keyHash && k.key.RevokedAt == nil {
+			return k.key, nil
+		}
+	}
+	return models.APIKey{}, ErrTokenInvalid
+}
+
+// Touch sets LastUsedAt
+func (m *MemoryAPIKeyRepository) Touch(ctx context.Context, keyID int64, usedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if k, ok := m.keys[keyID]; ok {
+		t := usedAt.UTC().Truncate(time.Second)
+		k.key.LastUsedAt = &t
+	}
+	return nil
+}
+
+// MemoryJobRepository is a JobRepository that lives in memory: the queue
+// semantics (claims, visibility timeout, retries) without the durability
+type MemoryJobRepository struct {
+	mu     sync.Mutex
+	jobs   map[int64]*memoryJob
+	nextID int64
+}
+
+type memoryJob struct {
+	job         models.Job
+	lockedBy    string
+	lockedUntil time.Time
+	errors      []models.JobError // job_errors
+}
+
+var _ JobRepository = (*MemoryJobRepository)(nil)
+
+// NewMemoryJobRepository creates an empty in-memory job queue
+func NewMemoryJobRepository() *MemoryJobRepository {
+	return &MemoryJobRepository{jobs: make(map[int64]*memoryJob), nextID: 1}
+}
+
+// Enqueue adds a pending job
+func (m *MemoryJobRepository) Enqueue(ctx context.Context, jobType string, payload []byte, runAt time.Time, maxAttempts int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+	m.jobs[id] = &memoryJob{job: models.Job{
+		ID:          id,
+		Type:        jobType,
+		Payload:     append([]byte{}, payload...),
+		Status:      models.JobPending,
+		MaxAttempts: maxAttempts,
+		RunAt:       runAt.UTC().Truncate(time.Second),
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}}
+	return id, nil
+}
+
+// Claim takes the oldest due job (by run_at, then id) for workerID that isn't one of skipTypes
+func (m *MemoryJobRepository) Claim(ctx context.Context, workerID string, now time.Time, lockedUntil time.Time, skipTypes []string) (models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Compared at the same second resolution as the stored timestamps
+	now = now.UTC().Truncate(time.Second)
+	var next *memoryJob
+	for _, j := range m.jobs {
+		// An expired last attempt waits for FailExpired
+		expired := m.leaseExpired(j, now) && j.job.Attempts < j.job.MaxAttempts
+		due := (j.job.Status == models.JobPending && !j.job.RunAt.After(now)) || expired
+		if !due || slices.Contains(skipTypes, j.job.Type) {
+			continue
+		}
+		if next == nil || j.job.RunAt.Before(next.job.RunAt) ||
+			(j.job.RunAt.Equal(next.job.RunAt) && j.job.ID < next.job.ID) {
+			next = j
+		}
+	}
+	if next == nil {
+		return models.Job{}, ErrNotFound
+	}
+
+	next.job.Status = models.JobRunning
+	next.job.Attempts++
+	next.lockedBy = workerID
+	next.lockedUntil = lockedUntil.UTC().Truncate(time.Second)
+	return copyJob(next.job), nil
+}
+
+// FailExpired fails the running jobs whose last attempt never reported back
+func (m *MemoryJobRepository) FailExpired(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now = now.UTC().Truncate(time.Second)
+	var failed int64
+	for _, j := range m.jobs {
+		if !m.leaseExpired(j, now) || j.job.Attempts < j.job.MaxAttempts {
+			continue
+		}
+		j.job.Status = models.JobFailed
+		j.job.LastError = leaseExpiredError
+		finished := now
+		j.job.FinishedAt = &finished
+		j.errors = append(j.errors, models.JobError{
+			Attempt:  j.job.Attempts,
+			Error:    leaseExpiredError,
+			Worker:   j.lockedBy,
+			FailedAt: now,
+		})
+		j.lockedUntil = time.Time{}
+		failed++
+	}
+	return failed, nil
+}
+
+// leaseExpired reports whether j is running on a lease that ended before now (caller holds the lock)
+func (m *MemoryJobRepository) leaseExpired(j *memoryJob, now time.Time) bool {
+	return j.job.Status == models.JobRunning && j.lockedUntil.Before(now)
+}
+
+// Succeed marks the job succeeded and drops its payload
+func (m *MemoryJobRepository) Succeed(ctx context.Context, jobID int64, workerID string, finishedAt time.Time) error {
+	return m.finish(jobID, workerID, func(j *memoryJob) {
+		j.job.Status = models.JobSucceeded
+		j.job.Payload = []byte(`{}`)
+		finished := finishedAt.UTC().Truncate(time.Second)
+		j.job.FinishedAt = &finished
+	})
+}
+
+// Retry makes the job pending again from runAt and records the error
+func (m *MemoryJobRepository) Retry(ctx context.Context, jobID int64, workerID string, errMsg string, failedAt time.Time, runAt time.Time) error {
+	return m.fail(jobID, workerID, errMsg, failedAt, func(j *models.Job) {
+		j.Status = models.JobPending
+		j.RunAt = runAt.UTC().Truncate(time.Second)
+	})
+}
+
+// Fail marks the job failed (dead-letter queue) and records the error
+func (m *MemoryJobRepository) Fail(ctx context.Context, jobID int64, workerID string, errMsg string, finishedAt time.Time) error {
+	return m.fail(jobID, workerID, errMsg, finishedAt, func(j *models.Job) {
+		j.Status = models.JobFailed
+		finished := finishedAt.UTC().Truncate(time.Second)
+		j.FinishedAt = &finished
+	})
+}
+
+// fail is finish for a failed attempt: sets last_error and adds it to the history
+func (m *MemoryJobRepository) fail(jobID int64, workerID string, errMsg string, failedAt time.Time, update func(*models.Job)) error {
+	return m.finish(jobID, workerID, func(j *memoryJob) {
+		update(&j.job)
+		j.job.LastError = errMsg
+		j.errors = append(j.errors, models.JobError{
+			Attempt:  j.job.Attempts,
+			Error:    errMsg,
+			Worker:   workerID,
+			FailedAt: failedAt.UTC().Truncate(time.Second),
+		})
+	})
+}
+
+// finish applies update to a job workerID is running, or returns ErrNotFound
+func (m *MemoryJobRepository) finish(jobID int64, workerID string, update func(*memoryJob)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[jobID]
+	if !ok || j.job.Status != models.JobRunning || j.lockedBy != workerID {
+		return ErrNotFound
+	}
+	update(j)
+	j.lockedUntil = time.Time{}
+	return nil
+}
+
+// Counts returns the number of jobs per status
+func (m *MemoryJobRepository) Counts(ctx context.Context) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := map[string]int{models.JobPending: 0, models.JobRunning: 0, models.JobSucceeded: 0, models.JobFailed: 0}
+	for _, j := range m.jobs {
+		counts[j.job.Status]++
+	}
+	return counts, nil
+}
+
+// ListFailed returns one page of the dead-letter queue, most recently failed first
+func (m *MemoryJobRepository) ListFailed(ctx context.Context, page int, limit int) ([]models.Job, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	failed := []models.Job{}
+	for _, j := range m.jobs {
+		if j.job.Status == models.JobFailed {
+			failed = append(failed, copyJob(j.job))
+		}
+	}
+	sort.Slice(failed, func(a, b int) bool {
+		if !failed[a].FinishedAt.Equal(*failed[b].FinishedAt) {
+			return failed[a].FinishedAt.After(*failed[b].FinishedAt)
+		}
+		return failed[a].ID > failed[b].ID
+	})
+
+	total := len(failed)
+	start := min((page-1)*limit, total)
+	end := min(start+limit, total)
+	return failed[start:end], total, nil
+}
+
+// Get returns a job with its error history
+func (m *MemoryJobRepository) Get(ctx context.Context, jobID int64) (models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[jobID]
+	if !ok {
+		return models.Job{}, ErrNotFound
+	}
+	job := copyJob(j.job)
+	if len(j.errors) > 0 {
+		job.Errors = append([]models.JobError{}, j.errors...)
+	}
+	return job, nil
+}
+
+// Replay puts a failed job back into the queue with a fresh set of attempts
+func (m *MemoryJobRepository) Replay(ctx context.Context, jobID int64, runAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[jobID]
+	if !ok || j.job.Status != models.JobFailed {
+		return ErrNotFound
+	}
+	j.job.Status = models.JobPending
+	j.job.Attempts = 0
+	j.job.RunAt = runAt.UTC().Truncate(time.Second)
+	j.job.FinishedAt = nil
+	j.lockedBy = ""
+	return nil
+}
+
+// Discard deletes a failed job and its error history
+func (m *MemoryJobRepository) Discard(ctx context.Context, jobID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[jobID]
+	if !ok || j.job.Status != models.JobFailed {
+		return ErrNotFound
+	}
+	delete(m.jobs, jobID)
+	return nil
+}
+
+// PurgeSucceeded deletes jobs that succeeded before finishedBefore
+func (m *MemoryJobRepository) PurgeSucceeded(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, j := range m.jobs {
+		if j.job.Status == models.JobSucceeded && j.job.FinishedAt.Before(finishedBefore) {
+			delete(m.jobs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// copyJob returns a job that doesn't share its payload with the stored one
+func copyJob(j models.Job) models.Job {
+	j.Payload = append([]byte{}, j.Payload...)
+	return j
+}
+
+// MemoryScheduleRepository is a ScheduleRepository that lives in memory
+type MemoryScheduleRepository struct {
+	mu        sync.Mutex
+	schedules map[string]time.Time
+	leases    map[string]memoryLease
+}
+
+type memoryLease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+var _ ScheduleRepository = (*MemoryScheduleRepository)(nil)
+
+// NewMemoryScheduleRepository creates an empty in-memory schedule store
+func NewMemoryScheduleRepository() *MemoryScheduleRepository {
+	return &MemoryScheduleRepository{schedules: make(map[string]time.Time), leases: make(map[string]memoryLease)}
+}
+
+// AcquireLease takes or renews the lease
+func (m *MemoryScheduleRepository) AcquireLease(ctx context.Context, name string, holder string, now time.Time, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lease, ok := m.leases[name]
+	if ok && lease.holder != holder && !lease.expiresAt.Before(now.Truncate(time.Second)) {
+		return false, nil
+	}
+	m.leases[name] = memoryLease{holder: holder, expiresAt: until.Truncate(time.Second)}
+	return true, nil
+}
+
+// ReleaseLease deletes the lease if holder still has it
+func (m *MemoryScheduleRepository) ReleaseLease(ctx context.Context, name string, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.leases[name].holder == holder {
+		delete(m.leases, name)
+	}
+	return nil
+}
+
+// NextRun returns the stored next run of a schedule
+func (m *MemoryScheduleRepository) NextRun(ctx context.Context, name string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next, ok := m.schedules[name]
+	if !ok {
+		return time.Time{}, ErrNotFound
+	}
+	return next, nil
+}
+
+// SetNextRun moves the next run from prev to next (compare-and-set)
+func (m *MemoryScheduleRepository) SetNextRun(ctx context.Context, name string, prev time.Time, next time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.schedules[name]
+	if ok && !stored.Equal(prev.Truncate(time.Second)) {
+		return false, nil
+	}
+	m.schedules[name] = next.Truncate(time.Second)
+	return true, nil
+}
@@ -0,0 +1,333 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+/*
+=== VERSIONED SCHEMA MIGRATIONS ===
+
+Problem: CREATE TABLE IF NOT EXISTS only helps the FIRST time.
+Once a table exists, adding a column / index / trigger to that statement does
+nothing on databases that already have the table. There was no way to change
+the schema of an existing data.db.
+
+Solution: numbered migration files, applied in order, each exactly once.
+
+  migrations/sqlite/0001_create_users_and_entries.up.sql    ← how to apply
+  migrations/sqlite/0001_create_users_and_entries.down.sql  ← how to undo
+
+Each dialect has its own folder (migrations/sqlite, migrations/postgres) because
+the DDL differs (AUTOINCREMENT vs BIGSERIAL, FTS5 vs tsvector...). The version
+numbers are kept in step: 0003 is "create tags" in both.
+
+The schema_migrations table remembers which versions ran:
+
+  version | name                        | checksum | applied_at
+  1       | create_users_and_entries    | 3fa1…    | 2026-02-01 10:00:00
+
+=== WHY A CHECKSUM? ===
+
+An applied migration must never be edited - databases that already ran the old
+version will never see the change, so two databases end up with different
+schemas from "the same" migration. We store sha256(up.sql) when applying and
+refuse to start if the file on disk no longer matches. Fix = write a NEW migration.
+
+=== WHY embed.FS? ===
+
+//go:embed compiles the .sql files INTO the binary. No "migrations folder not
+found" when the server runs from another directory or in a container.
+*/
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches "0001_create_users.up.sql" → version, name, direction
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up, hex encoded
+}
+
+// MigrationStatus describes one migration for the "migrate status" command
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string
+	Modified  bool // applied, but the up.sql on disk no longer matches its checksum
+	Missing   bool // applied, but there is no file for it (database is newer than this binary)
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt string
+}
+
+// loadMigrations reads and parses every embedded migration of a dialect, sorted by version
+func loadMigrations(d *dialect) ([]Migration, error) {
+	migrationsDir := path.Join("migrations", d.name)
+	files, err := fs.ReadDir(migrationFiles, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, f := range files {
+		match := migrationFileName.FindStringSubmatch(f.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: file name must look like 0001_name.up.sql", f.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1]) // regex guarantees digits
+		name, direction := match[2], match[3]
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(migrationsDir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d: up and down files have different names (%s, %s)", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d (%s): both .up.sql and .down.sql are required", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates schema_migrations if it doesn't exist yet
+func ensureMigrationsTable(conn *Conn) error {
+	_, err := conn.Exec(conn.dialect.migrationsTable)
+	return err
+}
+
+// loadApplied returns the rows of schema_migrations keyed by version
+func loadApplied(conn *Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.Query(`SELECT version, name, checksum, CAST(applied_at AS TEXT) FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verifyApplied makes sure every applied migration still exists and is unchanged
+func verifyApplied(migrations []Migration, applied map[int]appliedMigration) error {
+	known := map[int]Migration{}
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	for version, a := range applied {
+		m, ok := known[version]
+		if !ok {
+			return fmt.Errorf("migration %d (%s) is applied but missing from this build - database is newer than the code", version, a.name)
+		}
+		if m.Checksum != a.checksum {
+			return fmt.Errorf("migration %d (%s) was modified after it was applied - add a new migration instead of editing it", version, m.Name)
+		}
+	}
+	return nil
+}
+
+// MigrateUp applies every pending migration in version order.
+// Refuses to run (returns an error) if an applied migration was edited or is missing.
+// Returns how many migrations were applied.
+func MigrateUp(conn *Conn) (int, error) {
+	migrations, applied, err := prepareMigrations(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	count := 0
+	for _, m := range migrations {
+		if _, done := applied[m.Version]; done {
+			continue
+		}
+
+		err := inTransaction(ctx, conn, func(tx *Tx) error {
+			// Not rebound: migrations are plain DDL without placeholders
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.exec(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`,
+				m.Version, m.Name, m.Checksum)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+
+		slog.Info("Migration applied", "version", m.Version, "name", m.Name)
+		count++
+	}
+
+	return count, nil
+}
+
+// MigrateDown rolls back the last `steps` applied migrations, newest first.
+// Returns how many migrations were rolled back.
+func MigrateDown(conn *Conn, steps int) (int, error) {
+	migrations, applied, err := prepareMigrations(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	count := 0
+	// Walk backwards: undo the newest migration first
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, done := applied[m.Version]; !done {
+			continue
+		}
+
+		err := inTransaction(ctx, conn, func(tx *Tx) error {
+			if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+			_, err := tx.exec(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("rollback of migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+
+		slog.Info("Migration rolled back", "version", m.Version, "name", m.Name)
+		count++
+	}
+
+	return count, nil
+}
+
+// GetMigrationStatus lists every known migration and whether it has been applied.
+// Unlike MigrateUp it doesn't fail on modified migrations - it reports them.
+func GetMigrationStatus(conn *Conn) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(conn.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(conn); err != nil {
+		return nil, err
+	}
+	applied, err := loadApplied(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.appliedAt
+			s.Modified = a.checksum != m.Checksum
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, s)
+	}
+
+	// Whatever is left in applied has no file in this build
+	for version, a := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version:   version,
+			Name:      a.name,
+			Applied:   true,
+			AppliedAt: a.appliedAt,
+			Missing:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// prepareMigrations loads the migration files and the applied versions, and
+// verifies the applied ones - the common first step of MigrateUp/MigrateDown
+func prepareMigrations(conn *Conn) ([]Migration, map[int]appliedMigration, error) {
+	migrations, err := loadMigrations(conn.dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ensureMigrationsTable(conn); err != nil {
+		return nil, nil, err
+	}
+	applied, err := loadApplied(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifyApplied(migrations, applied); err != nil {
+		return nil, nil, err
+	}
+	return migrations, applied, nil
+}
+
+// inTransaction runs fn inside a transaction: commit if fn returns nil, rollback otherwise.
+// SQLite and Postgres DDL is transactional, so a failing migration leaves no half-created tables.
+func inTransaction(ctx context.Context, conn *Conn, fn func(tx *Tx) error) error {
+	sqlTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{Tx: sqlTx, dialect: conn.dialect}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestConn opens an empty SQLite database in a temp dir, without migrating
+func newTestConn(t *testing.T) *Conn {
+	t.Helper()
+	conn, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// newMigratedConn is newTestConn with every migration applied
+func newMigratedConn(t *testing.T) *Conn {
+	t.Helper()
+	conn := newTestConn(t)
+	if _, err := MigrateUp(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestNormalizeUserEmailsMigration(t *testing.T) {
+	conn := newMigratedConn(t)
+	ctx := context.Background()
+
+	// Run 0014 again over accounts from before Register normalized
+	if _, err := MigrateDown(conn, 1); err != nil {
+		t.Fatal(err)
+	}
+	fixture := []struct {
+		id    int64
+		email string
+		want  string
+	}{
+		{1, "Solo@Example.com ", "solo@example.com"}, // no collision: normalized
+		{2, "A@b.c ", "A@b.c "},                      // 2 and 3 collide with each other only
+		{3, "a@B.c", "a@B.c"},
+		{4, "x@y.z", "x@y.z"}, // already normalized...
+		{5, "X@Y.z", "X@Y.z"}, // ...so this one can't take its address
+		{6, "ok@fine.io", "ok@fine.io"},
+	}
+	for _, f := range fixture {
+		if _, err := conn.exec(ctx, `INSERT INTO users (id, email, password_hash) VALUES (?, ?, 'x')`, f.id, f.email); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := MigrateUp(conn); err != nil {
+		t.Fatalf("migration over colliding emails: %v", err)
+	}
+	for _, f := range fixture {
+		var email string
+		if err := conn.queryRow(ctx, `SELECT email FROM users WHERE id = ?`, f.id).Scan(&email); err != nil {
+			t.Fatal(err)
+		}
+		if email != f.want {
+			t.Errorf("user %d: email %q, want %q", f.id, email, f.want)
+		}
+	}
+
+	ids, err := UnnormalizedEmailUserIDs(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{2, 3, 5}; !slices.Equal(ids, want) {
+		t.Errorf("skipped ids %v, want %v", ids, want)
+	}
+}
+
+func TestMigrateUpDownStatus(t *testing.T) {
+	conn := newTestConn(t)
+	migrations, err := loadMigrations(sqliteDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(migrations)
+
+	// applied lists the versions GetMigrationStatus reports as applied
+	applied := func() []int {
+		t.Helper()
+		statuses, err := GetMigrationStatus(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) != total {
+			t.Fatalf("%d statuses, want %d", len(statuses), total)
+		}
+		var versions []int
+		for _, s := range statuses {
+			if s.Applied {
+				versions = append(versions, s.Version)
+			}
+		}
+		return versions
+	}
+	versions := func(ms []Migration) []int {
+		var v []int
+		for _, m := range ms {
+			v = append(v, m.Version)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name  string
+		run   func() (int, error)
+		count int
+		want  []int // applied afterwards
+	}{
+		{"up on an empty database", func() (int, error) { return MigrateUp(conn) }, total, versions(migrations)},
+		{"up again does nothing", func() (int, error) { return MigrateUp(conn) }, 0, versions(migrations)},
+		{"down 2 undoes the newest two", func() (int, error) { return MigrateDown(conn, 2) }, 2, versions(migrations[:total-2])},
+		{"up applies them again", func() (int, error) { return MigrateUp(conn) }, 2, versions(migrations)},
+		{"down more than applied stops at the first", func() (int, error) { return MigrateDown(conn, total+5) }, total, nil},
+		{"up from scratch after a full down", func() (int, error) { return MigrateUp(conn) }, total, versions(migrations)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := tt.run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.count {
+				t.Errorf("count %d, want %d", count, tt.count)
+			}
+			if got := applied(); !slices.Equal(got, tt.want) {
+				t.Errorf("applied %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Both dialects must ship the same migrations: a version that exists in one
+// folder only would leave the two schemas apart
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	sqliteMigrations, err := loadMigrations(sqliteDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgresMigrations, err := loadMigrations(postgresDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(ms []Migration) []string {
+		var n []string
+		for _, m := range ms {
+			n = append(n, strconv.Itoa(m.Version)+"_"+m.Name)
+		}
+		return n
+	}
+	if s, p := names(sqliteMigrations), names(postgresMigrations); !slices.Equal(s, p) {
+		t.Errorf("sqlite migrations %v, postgres %v", s, p)
+	}
+}
+
+func TestMigrationChecksums(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  string // run against schema_migrations after a full MigrateUp
+		wantErr string
+		check   func(s MigrationStatus) bool
+	}{
+		{
+			name:    "edited migration",
+			tamper:  `UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`,
+			wantErr: "was modified after it was applied",
+			check:   func(s MigrationStatus) bool { return s.Version == 1 && s.Applied && s.Modified && !s.Missing },
+		},
+		{
+			name:    "applied by a newer build",
+			tamper:  `INSERT INTO schema_migrations (version, name, checksum) VALUES (999, 'from_the_future', 'x')`,
+			wantErr: "is applied but missing from this build",
+			check: func(s MigrationStatus) bool {
+				return s.Version == 999 && s.Applied && s.Missing && s.Name == "from_the_future"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newMigratedConn(t)
+			if _, err := conn.Exec(tt.tamper); err != nil {
+				t.Fatal(err)
+			}
+
+			// Both directions refuse to run...
+			if _, err := MigrateUp(conn); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("MigrateUp: %v, want %q", err, tt.wantErr)
+			}
+			if _, err := MigrateDown(conn, 1); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("MigrateDown: %v, want %q", err, tt.wantErr)
+			}
+
+			// ...status reports the problem instead
+			statuses, err := GetMigrationStatus(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.ContainsFunc(statuses, tt.check) {
+				t.Errorf("status doesn't report it: %+v", statuses)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+-- Users table - stores user accounts
+-- Timestamps are UTC without time zone, the same values SQLite's CURRENT_TIMESTAMP stores
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	email TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+-- Entries table - stores mood/activity data
+CREATE TABLE IF NOT EXISTS entries (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	text TEXT,
+	mood INTEGER,
+	category TEXT,
+	created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+-- Index for "this user's entries in a date range" - used by pagination and analytics
+CREATE INDEX IF NOT EXISTS idx_entries_user_created ON entries (user_id, created_at);
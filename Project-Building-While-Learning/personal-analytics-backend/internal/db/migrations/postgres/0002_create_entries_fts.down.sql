@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_entries_text_search;
+ALTER TABLE entries DROP COLUMN IF EXISTS text_search;
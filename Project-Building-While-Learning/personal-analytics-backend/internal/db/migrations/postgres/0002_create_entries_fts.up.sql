@@ -0,0 +1,9 @@
+-- Full-text index over entries.text (see search.go for how it is queried)
+-- Postgres has no FTS5: a generated tsvector column + GIN index does the same job.
+-- GENERATED ... STORED is recomputed by Postgres on every insert/update of text,
+-- so no triggers are needed, and existing rows are filled in when the column is added.
+-- 'simple' = lowercase only, no stemming or stop words (like FTS5's default tokenizer)
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS text_search tsvector
+	GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(text, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_entries_text_search ON entries USING GIN (text_search);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_entry_tags_tag;
+DROP TABLE IF EXISTS entry_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,20 @@
+-- Tags belong to a user: "gym" for user 1 and "gym" for user 2 are different rows
+CREATE TABLE IF NOT EXISTS tags (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'utc'),
+	UNIQUE (user_id, name)
+);
+
+-- Join table: one row per (entry, tag) pair - an entry has many tags, a tag has many entries
+-- ON DELETE CASCADE removes the links when the entry is deleted
+-- (the SQLite schema uses a trigger for this instead)
+CREATE TABLE IF NOT EXISTS entry_tags (
+	entry_id BIGINT NOT NULL REFERENCES entries (id) ON DELETE CASCADE,
+	tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (entry_id, tag_id)
+);
+
+-- PRIMARY KEY covers lookups by entry_id, this one covers "entries with tag X"
+CREATE INDEX IF NOT EXISTS idx_entry_tags_tag ON entry_tags (tag_id);
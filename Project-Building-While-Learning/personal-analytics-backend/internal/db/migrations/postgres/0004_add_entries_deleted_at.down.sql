@@ -0,0 +1,4 @@
+-- Without the column a trashed entry would come back as a live one, so the trash is emptied first
+DELETE FROM entries WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_entries_deleted_at;
+ALTER TABLE entries DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,8 @@
+-- Soft delete: DELETE /entries sets deleted_at instead of removing the row.
+-- NULL = live entry, a timestamp = in the trash since then (UTC, like created_at).
+-- The trash purge hard-deletes rows whose deleted_at is older than the retention.
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Partial index: only trashed rows are indexed, so it stays small
+-- and covers both GET /entries/trash and the purge
+CREATE INDEX IF NOT EXISTS idx_entries_deleted_at ON entries (deleted_at) WHERE deleted_at IS NOT NULL;
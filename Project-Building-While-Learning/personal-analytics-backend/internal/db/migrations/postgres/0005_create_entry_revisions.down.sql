@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_entry_revisions_entry;
+DROP TABLE IF EXISTS entry_revisions;
@@ -0,0 +1,19 @@
+-- Edit history: one row per replaced version of an entry.
+-- Written in the same transaction as the UPDATE / soft DELETE that replaced it,
+-- so a change is never saved without its previous version (see revisions.go).
+-- ON DELETE CASCADE: purging an entry removes its history (the SQLite schema uses a trigger)
+CREATE TABLE IF NOT EXISTS entry_revisions (
+	id BIGSERIAL PRIMARY KEY,
+	entry_id BIGINT NOT NULL REFERENCES entries (id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL,
+	text TEXT,
+	mood INTEGER,
+	category TEXT,
+	tags TEXT NOT NULL DEFAULT '',        -- tag names joined with "," (tags can't contain commas)
+	action TEXT NOT NULL,                 -- what replaced this version: 'update' or 'delete'
+	request_id TEXT NOT NULL DEFAULT '',  -- id from RequestIDMiddleware
+	changed_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+-- "History of entry X, newest first"
+CREATE INDEX IF NOT EXISTS idx_entry_revisions_entry ON entry_revisions (entry_id, id);
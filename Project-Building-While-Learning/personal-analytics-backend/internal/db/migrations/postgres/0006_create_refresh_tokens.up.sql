@@ -0,0 +1,18 @@
+-- Refresh tokens (see refresh_tokens.go). Only the SHA-256 hash is stored:
+-- a leaked database doesn't hand out working tokens.
+-- Every login starts a new family; each refresh marks the old token used and
+-- adds the next one to the same family. Presenting a used token again = reuse,
+-- and the whole family is revoked.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	family_id TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,    -- set when the token was exchanged for a new one
+	revoked_at TIMESTAMP, -- set on logout or reuse, for every token of the family
+	created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+-- Revoking a family updates all its tokens
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id);
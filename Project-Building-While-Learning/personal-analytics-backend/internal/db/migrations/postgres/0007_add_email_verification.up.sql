@@ -0,0 +1,17 @@
+-- Email verification + password reset (see user_tokens.go).
+-- NULL = not verified yet; accounts created before this migration start unverified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Single-use tokens sent by email. Only the SHA-256 hash is stored, like refresh tokens.
+CREATE TABLE IF NOT EXISTS user_tokens (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	purpose TEXT NOT NULL,        -- 'verify_email' or 'reset_password'
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,            -- set when consumed, or when a newer token of the same purpose replaced it
+	created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+-- A new token invalidates the user's earlier ones of the same purpose
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user ON user_tokens (user_id, purpose);
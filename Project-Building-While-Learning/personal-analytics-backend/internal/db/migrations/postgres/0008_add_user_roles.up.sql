@@ -0,0 +1,6 @@
+-- Role-based access control (see handlers/roles.go).
+-- Every existing account becomes a normal user; promote admins with: server role <email> admin
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';   -- 'user' or 'admin'
+
+-- Set by an admin (POST /admin/users/{id}/disable): the account can't log in or refresh
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
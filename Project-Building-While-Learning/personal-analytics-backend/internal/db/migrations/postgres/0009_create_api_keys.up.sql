@@ -0,0 +1,16 @@
+-- Personal API keys (see api_keys.go) for scripts that can't do the /login flow.
+-- Like refresh tokens only the SHA-256 hash is stored; the key is shown once at creation.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,           -- chosen by the user: "phone shortcut", "backup cron"
+	prefix TEXT NOT NULL,         -- first characters of the key, to recognize it in the list
+	key_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,         -- comma-separated: 'entries:read,analytics:read'
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,         -- set by DELETE /apikeys/{id}; the key stops working
+	created_at TIMESTAMP DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+-- GET /apikeys lists the user's keys
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_job_errors_job;
+DROP TABLE IF EXISTS job_errors;
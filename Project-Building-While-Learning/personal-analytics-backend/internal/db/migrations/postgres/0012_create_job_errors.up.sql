@@ -0,0 +1,15 @@
+-- Error history of background jobs: one row per failed attempt.
+-- Written in the same transaction as the Retry / Fail that recorded the failure
+-- (see jobs.go), so a failed job in the dead-letter list shows every error, not just the last.
+-- ON DELETE CASCADE: deleting a job removes its errors (the SQLite schema uses a trigger)
+CREATE TABLE IF NOT EXISTS job_errors (
+	id BIGSERIAL PRIMARY KEY,
+	job_id BIGINT NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+	attempt INTEGER NOT NULL,             -- jobs.attempts when it failed (starts over after a replay)
+	error TEXT NOT NULL,
+	worker TEXT NOT NULL,                 -- jobs.locked_by: which server / worker ran it
+	failed_at TIMESTAMP NOT NULL
+);
+
+-- "Errors of job X, in order"
+CREATE INDEX IF NOT EXISTS idx_job_errors_job ON job_errors (job_id, id);
@@ -0,0 +1,3 @@
+-- Can't be undone: the original spelling of the emails is gone.
+-- Rolling back only forgets that 0014 ran; the emails stay normalized.
+SELECT 1;
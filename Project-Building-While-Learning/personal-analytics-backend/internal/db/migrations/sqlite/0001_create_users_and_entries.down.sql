@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_entries_user_created;
+DROP TABLE IF EXISTS entries;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,21 @@
+-- Users table - stores user accounts
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Entries table - stores mood/activity data
+CREATE TABLE IF NOT EXISTS entries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	text TEXT,
+	mood INTEGER,
+	category TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for "this user's entries in a date range" - used by pagination and analytics
+-- Without it every per-user query scans the whole entries table
+CREATE INDEX IF NOT EXISTS idx_entries_user_created ON entries (user_id, created_at);
@@ -0,0 +1,27 @@
+-- Full-text index over entries.text (see search.go for how it is queried)
+-- content='entries': the index reads text from entries instead of keeping a copy
+CREATE VIRTUAL TABLE IF NOT EXISTS entries_fts USING fts5(
+	text,
+	content='entries',
+	content_rowid='id'
+);
+
+-- New entry → add to index
+CREATE TRIGGER IF NOT EXISTS entries_fts_insert AFTER INSERT ON entries BEGIN
+	INSERT INTO entries_fts (rowid, text) VALUES (new.id, new.text);
+END;
+
+-- Deleted entry → remove from index
+-- (external content tables need the OLD text to know which words to remove)
+CREATE TRIGGER IF NOT EXISTS entries_fts_delete AFTER DELETE ON entries BEGIN
+	INSERT INTO entries_fts (entries_fts, rowid, text) VALUES ('delete', old.id, old.text);
+END;
+
+-- Edited text → remove old words, add new words
+CREATE TRIGGER IF NOT EXISTS entries_fts_update AFTER UPDATE OF text ON entries BEGIN
+	INSERT INTO entries_fts (entries_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	INSERT INTO entries_fts (rowid, text) VALUES (new.id, new.text);
+END;
+
+-- Index the entries written before search existed
+INSERT INTO entries_fts (entries_fts) VALUES ('rebuild');
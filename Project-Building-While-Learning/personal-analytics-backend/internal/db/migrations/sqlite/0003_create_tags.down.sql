@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS entry_tags_cleanup;
+DROP INDEX IF EXISTS idx_entry_tags_tag;
+DROP TABLE IF EXISTS entry_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,25 @@
+-- Tags belong to a user: "gym" for user 1 and "gym" for user 2 are different rows
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (user_id, name)
+);
+
+-- Join table: one row per (entry, tag) pair - an entry has many tags, a tag has many entries
+CREATE TABLE IF NOT EXISTS entry_tags (
+	entry_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (entry_id, tag_id)
+);
+
+-- PRIMARY KEY covers lookups by entry_id, this one covers "entries with tag X"
+CREATE INDEX IF NOT EXISTS idx_entry_tags_tag ON entry_tags (tag_id);
+
+-- SQLite doesn't enforce foreign keys unless asked to (PRAGMA foreign_keys, on every
+-- connection), so dependent rows are cleaned up by triggers like this one.
+-- Later migrations do the same; Postgres uses ON DELETE CASCADE instead.
+CREATE TRIGGER IF NOT EXISTS entry_tags_cleanup AFTER DELETE ON entries BEGIN
+	DELETE FROM entry_tags WHERE entry_id = old.id;
+END;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS entry_revisions_cleanup;
+DROP INDEX IF EXISTS idx_entry_revisions_entry;
+DROP TABLE IF EXISTS entry_revisions;
@@ -0,0 +1,23 @@
+-- Edit history: one row per replaced version of an entry.
+-- Written in the same transaction as the UPDATE / soft DELETE that replaced it,
+-- so a change is never saved without its previous version (see revisions.go).
+CREATE TABLE IF NOT EXISTS entry_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	entry_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	text TEXT,
+	mood INTEGER,
+	category TEXT,
+	tags TEXT NOT NULL DEFAULT '',        -- tag names joined with "," (tags can't contain commas)
+	action TEXT NOT NULL,                 -- what replaced this version: 'update' or 'delete'
+	request_id TEXT NOT NULL DEFAULT '',  -- id from RequestIDMiddleware
+	changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- "History of entry X, newest first"
+CREATE INDEX IF NOT EXISTS idx_entry_revisions_entry ON entry_revisions (entry_id, id);
+
+-- A trigger instead of a foreign key (see 0003_create_tags): purging an entry removes its history
+CREATE TRIGGER IF NOT EXISTS entry_revisions_cleanup AFTER DELETE ON entries BEGIN
+	DELETE FROM entry_revisions WHERE entry_id = old.id;
+END;
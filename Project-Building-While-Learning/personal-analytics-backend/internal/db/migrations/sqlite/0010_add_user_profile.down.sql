@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN mood_labels;
+ALTER TABLE users DROP COLUMN week_start;
+ALTER TABLE users DROP COLUMN timezone;
+ALTER TABLE users DROP COLUMN display_name;
@@ -0,0 +1,9 @@
+-- Profile and preferences (GET/PATCH /me, see handlers/profile.go).
+-- Existing accounts get UTC days and Monday weeks - what analytics used before.
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';         -- IANA name, e.g. 'Europe/Berlin'
+ALTER TABLE users ADD COLUMN week_start TEXT NOT NULL DEFAULT 'monday';    -- first day of a "week" bucket
+
+-- Labels for mood values as a JSON object: '{"1":"awful","10":"great"}'
+-- JSON, not comma-separated like scopes: a label may contain commas
+ALTER TABLE users ADD COLUMN mood_labels TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,20 @@
+-- Durable background job queue (see jobs.go and the worker package).
+-- AddJob inserts a row, workers claim due rows; a job survives restarts and
+-- the claim expires (locked_until) if the worker dies halfway through.
+CREATE TABLE IF NOT EXISTS jobs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	type TEXT NOT NULL,                       -- "send_email", "entry_created" ...
+	payload TEXT NOT NULL,                    -- JSON, emptied to '{}' once the job succeeded
+	status TEXT NOT NULL DEFAULT 'pending',   -- pending | running | succeeded | failed
+	attempts INTEGER NOT NULL DEFAULT 0,      -- claims so far, including the current one
+	max_attempts INTEGER NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	run_at DATETIME NOT NULL,                 -- not claimed before (retry backoff)
+	locked_by TEXT NOT NULL DEFAULT '',       -- worker that claimed it
+	locked_until DATETIME,                    -- visibility timeout of a running job
+	finished_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Claim looks for the oldest due job
+CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs (status, run_at);
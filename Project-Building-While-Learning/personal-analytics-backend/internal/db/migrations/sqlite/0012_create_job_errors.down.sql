@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS job_errors_cleanup;
+DROP INDEX IF EXISTS idx_job_errors_job;
+DROP TABLE IF EXISTS job_errors;
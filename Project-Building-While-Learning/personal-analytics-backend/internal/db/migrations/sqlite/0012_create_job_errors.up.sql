@@ -0,0 +1,19 @@
+-- Error history of background jobs: one row per failed attempt.
+-- Written in the same transaction as the Retry / Fail that recorded the failure
+-- (see jobs.go), so a failed job in the dead-letter list shows every error, not just the last.
+CREATE TABLE IF NOT EXISTS job_errors (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id INTEGER NOT NULL,
+	attempt INTEGER NOT NULL,             -- jobs.attempts when it failed (starts over after a replay)
+	error TEXT NOT NULL,
+	worker TEXT NOT NULL,                 -- jobs.locked_by: which server / worker ran it
+	failed_at DATETIME NOT NULL
+);
+
+-- "Errors of job X, in order"
+CREATE INDEX IF NOT EXISTS idx_job_errors_job ON job_errors (job_id, id);
+
+-- A trigger instead of a foreign key (see 0003_create_tags): deleting a job removes its errors
+CREATE TRIGGER IF NOT EXISTS job_errors_cleanup AFTER DELETE ON jobs BEGIN
+	DELETE FROM job_errors WHERE job_id = old.id;
+END;
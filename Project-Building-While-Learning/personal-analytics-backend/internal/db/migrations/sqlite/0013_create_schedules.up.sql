@@ -0,0 +1,15 @@
+-- Recurring jobs (see schedules.go and worker/scheduler.go).
+-- One row per schedule: when it fires next. Whoever fires it moves next_run_at
+-- forward with a compare-and-set, so a tick can't be run twice.
+CREATE TABLE IF NOT EXISTS schedules (
+	name TEXT PRIMARY KEY,                -- "purge_trash", "purge_jobs" ...
+	next_run_at DATETIME NOT NULL
+);
+
+-- Leases: one server at a time does a cluster-wide task (the scheduler).
+-- Held until expires_at and renewed by the holder; a crashed holder's lease simply runs out.
+CREATE TABLE IF NOT EXISTS leases (
+	name TEXT PRIMARY KEY,
+	holder TEXT NOT NULL,                 -- the scheduler holding it (hostname-pid-n)
+	expires_at DATETIME NOT NULL
+);
@@ -0,0 +1,13 @@
+-- Emails are stored trimmed and lowercase since Register normalizes them, and
+-- Login / forgot-password look them up the same way. Accounts created before
+-- that would not be found anymore: normalize them too.
+-- Skipped when another account normalizes to the same address ("A@b.c" next to
+-- "a@b.c", or "A@b.c " next to "a@B.c"): changing either would break the UNIQUE
+-- email. Those keep their stored email until an operator merges them - the
+-- server lists their ids at startup (db.UnnormalizedEmailUserIDs).
+UPDATE users
+SET email = LOWER(TRIM(email))
+WHERE email <> LOWER(TRIM(email))
+  AND NOT EXISTS (SELECT 1 FROM users other
+                  WHERE other.id <> users.id
+                    AND LOWER(TRIM(other.email)) = LOWER(TRIM(users.email)));
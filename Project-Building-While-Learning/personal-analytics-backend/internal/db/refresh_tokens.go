@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+/*
+=== REFRESH TOKEN ROTATION ===
+
+Access token (JWT): short-lived (minutes), checked without touching the database.
+Refresh token: long-lived random string, stored (hashed) here, exchanged for a
+new access token AND a new refresh token at POST /token/refresh.
+
+  login            → token A (family F)
+  refresh with A   → A marked used, token B (family F)
+  refresh with B   → B marked used, token C (family F)
+  refresh with A   → A was already used = someone copied it!
+                     revoke all of family F (A, B, C) → attacker AND user must log in again
+
+Why revoke the whole family? We can't tell which of the two holders of A is
+the real user - killing the family is the only safe answer (OAuth 2.0 Security BCP).
+
+Logout revokes the family of the presented token: that login session is over,
+other devices (other families) stay logged in. RevokeUser ends all of them.
+*/
+
+// SQLRefreshTokenRepository is the RefreshTokenRepository backed by SQLite or Postgres
+type SQLRefreshTokenRepository struct {
+	conn *Conn
+}
+
+var _ RefreshTokenRepository = (*SQLRefreshTokenRepository)(nil)
+
+// NewSQLRefreshTokenRepository creates a refresh token repository on an open connection (see InitDB)
+func NewSQLRefreshTokenRepository(conn *Conn) *SQLRefreshTokenRepository {
+	return &SQLRefreshTokenRepository{conn: conn}
+}
+
+// Create saves the first token of a new family
+func (r *SQLRefreshTokenRepository) Create(ctx context.Context, userID int64, familyID string, tokenHash string, expiresAt time.Time) error {
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)`
+	_, err := r.conn.exec(ctx, query, userID, familyID, tokenHash, storedTimestamp(expiresAt))
+	return err
+}
+
+// Rotate marks the old token used and stores its successor, in one transaction
+func (r *SQLRefreshTokenRepository) Rotate(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (int64, error) {
+	var userID int64
+	var reusedFamily string
+
+	err := inTransaction(ctx, r.conn, func(tx *Tx) error {
+		query := `SELECT user_id, family_id, expires_at, used_at, revoked_at
+		          FROM refresh_tokens
+		          WHERE token_hash = ?` + tx.dialect.forUpdate
+
+		var familyID string
+		var tokenExpiresAt time.Time
+		var usedAt, revokedAt sql.NullTime
+		err := tx.queryRow(ctx, query, oldHash).Scan(&userID, &familyID, &tokenExpiresAt, &usedAt, &revokedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case revokedAt.Valid:
+			return ErrTokenInvalid
+		case usedAt.Valid:
+			// Reuse: revoke the family and COMMIT that (returning an error would roll it back)
+			reusedFamily = familyID
+			return revokeFamily(ctx, tx, familyID, now)
+		case !tokenExpiresAt.After(now):
+			return ErrTokenInvalid
+		}
+
+		// AND used_at IS NULL: if another request exchanged the token in the meantime,
+		// 0 rows are updated and this one counts as the reuse
+		result, err := tx.exec(ctx, `UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL`,
+			storedTimestamp(now), oldHash)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			reusedFamily = familyID
+			return revokeFamily(ctx, tx, familyID, now)
+		}
+
+		_, err = tx.exec(ctx, `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)`,
+			userID, familyID, newHash, storedTimestamp(expiresAt))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if reusedFamily != "" {
+		slog.Warn("Refresh token reuse detected, family revoked", "user_id", userID, "family_id", reusedFamily)
+		return 0, ErrTokenReused
+	}
+	return userID, nil
+}
+
+// RevokeFamily revokes every token in the family of tokenHash and returns its user
+func (r *SQLRefreshTokenRepository) RevokeFamily(ctx context.Context, tokenHash string) (int64, error) {
+	var familyID string
+	var userID int64
+	err := r.conn.queryRow(ctx, `SELECT family_id, user_id FROM refresh_tokens WHERE token_hash = ?`, tokenHash).
+		Scan(&familyID, &userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = inTransaction(ctx, r.conn, func(tx *Tx) error {
+		return revokeFamily(ctx, tx, familyID, time.Now())
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// RevokeUser revokes every not yet revoked token of the user, in all families
+func (r *SQLRefreshTokenRepository) RevokeUser(ctx context.Context, userID int64) error {
+	query := `UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`
+	_, err := r.conn.exec(ctx, query, storedTimestamp(time.Now()), userID)
+	return err
+}
+
+// revokeFamily sets revoked_at on every not yet revoked token of the family
+func revokeFamily(ctx context.Context, tx *Tx, familyID string, now time.Time) error {
+	_, err := tx.exec(ctx, `UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`,
+		storedTimestamp(now), familyID)
+	return err
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"personal-analytics-backend/internal/models"
+	"time"
+)
+
+/*
+=== REPOSITORY PATTERN ===
+
+Before: handlers called package-level functions that used a global *sql.DB.
+  handlers → db.InsertEntry(...) → global DB → data.db
+Every handler test needed a real SQLite file, and swapping the storage meant
+touching every handler.
+
+After: handlers depend on an INTERFACE describing what they need.
+  handlers → EntryRepository (interface)
+                 ├── SQLEntryRepository     (SQLite or Postgres, entries.go)
+                 └── MemoryEntryRepository  (fake for tests, memory.go)
+
+Same idea as cache.go vs cache_in_memory.go: the caller doesn't care where
+the data lives. main.go builds the real implementation and injects it
+(handlers.New) - nothing reaches for a global.
+
+Repositories return models.Entry / models.User instead of map[string]interface{}:
+the compiler now checks field names and types ("mood" vs "Mood" typos are gone).
+
+=== WHY context.Context ON EVERY METHOD? ===
+
+TimeoutMiddleware cancels r.Context() after RequestTimeout. Passing that ctx down
+to QueryContext lets the database stop working on a request nobody waits for.
+*/
+
+// Errors shared by every repository implementation.
+// Handlers check them with errors.Is, never by matching driver error strings.
+var (
+	// ErrNotFound: no row with that id, or it belongs to another user
+	// (we deliberately don't tell those two cases apart)
+	ErrNotFound = errors.New("not found")
+
+	// ErrEmailTaken: a user with this email already exists
+	ErrEmailTaken = errors.New("email already registered")
+
+	// ErrTokenInvalid: refresh token unknown, expired or revoked
+	ErrTokenInvalid = errors.New("refresh token invalid")
+
+	// ErrTokenReused: refresh token was already exchanged once - its family is now revoked
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+// EntryInput is the user-editable part of an entry (create and update)
+type EntryInput struct {
+	Text     string
+	Mood     int
+	Category string
+	Tags     []string // normalized; on Update nil = leave tags unchanged, empty = remove all
+}
+
+// ProfileInput is the user-editable part of a user (PATCH /me); the handler validates it
+type ProfileInput struct {
+	DisplayName string
+	Timezone    string // IANA time zone name
+	WeekStart   string // "monday" … "sunday"
+	MoodLabels  map[int]string
+}
+
+// EntryRepository stores mood/activity entries.
+// Every method is scoped to one user: an entry of another user behaves as if
+// it doesn't exist (ErrNotFound). The same goes for entries in the trash,
+// except in ListTrash and Restore (see trash.go).
+type EntryRepository interface {
+	// Create saves a new entry with its tags and returns its id
+	Create(ctx context.Context, userID int64, input EntryInput) (int64, error)
+
+	// GetByID returns one entry, or ErrNotFound
+	GetByID(ctx context.Context, userID int64, entryID int64) (models.Entry, error)
+
+	// List returns one page (1-based) of entries matching filter, newest first,
+	// and the total number of matching entries
+	List(ctx context.Context, userID int64, filter EntryFilter, page int, limit int) ([]models.Entry, int, error)
+
+	// ListAfter is keyset pagination (see cursor.go): cursor is "" for the first
+	// page, nextCursor is "" when there are no more entries. Bad cursor = ErrInvalidCursor.
+	ListAfter(ctx context.Context, userID int64, filter EntryFilter, cursor string, limit int) (entries []models.Entry, nextCursor string, err error)
+
+	// Update replaces text, mood, category (and tags unless input.Tags is nil), or ErrNotFound
+	Update(ctx context.Context, userID int64, entryID int64, input EntryInput) error
+
+	// Delete moves an entry to the trash (soft delete), or ErrNotFound
+	Delete(ctx context.Context, userID int64, entryID int64) error
+
+	// ListTrash returns one page (1-based) of trashed entries, most recently
+	// deleted first, and the total number of trashed entries
+	ListTrash(ctx context.Context, userID int64, page int, limit int) ([]models.Entry, int, error)
+
+	// Restore takes an entry out of the trash, or ErrNotFound if it isn't there
+	Restore(ctx context.Context, userID int64, entryID int64) error
+
+	// History returns the earlier versions of an entry (in the trash or not),
+	// newest first, or ErrNotFound (see revisions.go)
+	History(ctx context.Context, userID int64, entryID int64) ([]models.EntryRevision, error)
+
+	// PurgeTrash permanently deletes entries of ALL users that were trashed
+	// before deletedBefore, and returns how many were removed
+	PurgeTrash(ctx context.Context, deletedBefore time.Time) (int64, error)
+
+	// Search is full-text search, best matches first (see search.go)
+	Search(ctx context.Context, userID int64, q string, limit int) ([]models.SearchResult, error)
+
+	// Tags lists the user's tags with usage counts, most used first
+	Tags(ctx context.Context, userID int64) ([]models.TagCount, error)
+
+	// MoodTrend groups mood stats by "day", "week" or "month" of the user's calendar over [from, to)
+	MoodTrend(ctx context.Context, userID int64, from time.Time, to time.Time, bucket string, cal Calendar) ([]models.MoodBucket, error)
+
+	// MoodSummary is count/avg/min/max mood over [from, to)
+	MoodSummary(ctx context.Context, userID int64, from time.Time, to time.Time) (models.MoodSummary, error)
+
+	// CategoryBreakdown is per-category mood stats over [from, to), zero time = no bound
+	CategoryBreakdown(ctx context.Context, userID int64, from time.Time, to time.Time) (models.CategoryReport, error)
+
+	// Stats counts the entries of ALL users (GET /admin/stats, see admin.go)
+	Stats(ctx context.Context) (models.EntryStats, error)
+
+	// Export returns every entry of the user, trash included, oldest first (see account.go)
+	Export(ctx context.Context, userID int64) ([]models.Entry, error)
+
+	// PurgeUser permanently deletes every entry, revision and tag of the user
+	// (account deletion) and returns how many entries were removed
+	PurgeUser(ctx context.Context, userID int64) (int64, error)
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	// Create saves a new user and returns its id, or ErrEmailTaken
+	Create(ctx context.Context, email string, passwordHash string) (int64, error)
+
+	// GetByEmail returns the user with this email, or ErrNotFound
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+
+	// GetByID returns the user with this id, or ErrNotFound
+	GetByID(ctx context.Context, userID int64) (models.User, error)
+
+	// SetPassword replaces the user's password hash (password reset or change), or ErrNotFound
+	SetPassword(ctx context.Context, userID int64, passwordHash string) error
+
+	// UpdateProfile replaces the user's profile and preferences, or ErrNotFound
+	UpdateProfile(ctx context.Context, userID int64, profile ProfileInput) error
+
+	// MarkEmailVerified records that the user confirmed their email, or ErrNotFound.
+	// Verifying twice keeps the first time.
+	MarkEmailVerified(ctx context.Context, userID int64) error
+
+	// List returns one page (1-based) of all users, oldest first, and the total number of users
+	List(ctx context.Context, page int, limit int) ([]models.User, int, error)
+
+	// SetRole changes the user's role (models.RoleUser / RoleAdmin), or ErrNotFound
+	SetRole(ctx context.Context, userID int64, role string) error
+
+	// SetDisabled disables (disabled_at = now) or re-enables an account, or ErrNotFound.
+	// Disabling an already disabled account keeps the first time.
+	SetDisabled(ctx context.Context, userID int64, disabled bool) error
+
+	// Stats counts accounts (GET /admin/stats, see admin.go)
+	Stats(ctx context.Context) (models.UserStats, error)
+
+	// Delete removes the account with its tokens and API keys, or ErrNotFound.
+	// Entries stay: EntryRepository.PurgeUser them first (see account.go).
+	Delete(ctx context.Context, userID int64) error
+}
+
+// UserTokenRepository stores single-use tokens sent by email (verification, password reset)
+// by their SHA-256 hash; see user_tokens.go.
+type UserTokenRepository interface {
+	// Create saves a new token for purpose; the user's earlier unused tokens of that purpose stop working
+	Create(ctx context.Context, userID int64, purpose string, tokenHash string, expiresAt time.Time) error
+
+	// Consume uses up a token and returns its user.
+	// Unknown, already used, expired or other-purpose tokens return ErrTokenInvalid.
+	Consume(ctx context.Context, purpose string, tokenHash string) (int64, error)
+}
+
+// APIKeyRepository stores personal API keys by their SHA-256 hash; see api_keys.go.
+// Every method except Authenticate and Touch is scoped to one user.
+type APIKeyRepository interface {
+	// Create saves a new key and returns it (with id and created_at)
+	Create(ctx context.Context, userID int64, name string, prefix string, keyHash string, scopes []string) (models.APIKey, error)
+
+	// List returns the user's keys, revoked ones included, newest first
+	List(ctx context.Context, userID int64) ([]models.APIKey, error)
+
+	// Revoke makes a key stop working, or ErrNotFound (unknown, another user's, already revoked)
+	Revoke(ctx context.Context, userID int64, keyID int64) error
+
+	// RevokeUser revokes every key of the user (account disabled)
+	RevokeUser(ctx context.Context, userID int64) error
+
+	// Authenticate returns the key with this hash, or ErrTokenInvalid if it is unknown or revoked
+	Authenticate(ctx context.Context, keyHash string) (models.APIKey, error)
+
+	// Touch records that the key was used at usedAt
+	Touch(ctx context.Context, keyID int64, usedAt time.Time) error
+}
+
+// JobRepository is the durable background job queue; see jobs.go.
+// Delivery is at-least-once: a job whose worker never reports back is claimed again.
+type JobRepository interface {
+	// Enqueue adds a pending job that may run from runAt on and returns its id
+	Enqueue(ctx context.Context, jobType string, payload []byte, runAt time.Time, maxAttempts int) (int64, error)
+
+	// Claim marks the oldest due job running for workerID until lockedUntil and counts
+	// the attempt, or returns ErrNotFound. Due: pending with run_at <= now, or running
+	// with locked_until < now (the worker that had it crashed or hung) and attempts
+	// left; with none left such a job waits for FailExpired.
+	// Jobs of skipTypes are left alone (types at their concurrency limit).
+	Claim(ctx context.Context, workerID string, now time.Time, lockedUntil time.Time, skipTypes []string) (models.Job, error)
+
+	// FailExpired fails ("lease expired") the running jobs whose lease expired
+	// before now on their last attempt, and returns how many
+	FailExpired(ctx context.Context, now time.Time) (int64, error)
+
+	// Succeed marks a job workerID is running as succeeded and empties its payload.
+	// Succeed, Retry and Fail return ErrNotFound if the job is no longer workerID's.
+	Succeed(ctx context.Context, jobID int64, workerID string, finishedAt time.Time) error
+
+	// Retry puts a job workerID is running back to pending, to run again from runAt.
+	// Retry and Fail also add errMsg to the job's error history.
+	Retry(ctx context.Context, jobID int64, workerID string, errMsg string, failedAt time.Time, runAt time.Time) error
+
+	// Fail marks a job workerID is running as failed for good: it moves to the
+	// dead-letter queue, where nothing claims it until it is replayed
+	Fail(ctx context.Context, jobID int64, workerID string, errMsg string, finishedAt time.Time) error
+
+	// ListFailed returns one page of failed jobs, most recently failed first,
+	// without their error history, and the total number of failed jobs
+	ListFailed(ctx context.Context, page, limit int) ([]models.Job, int, error)
+
+	// Get returns a job with its error history, or ErrNotFound
+	Get(ctx context.Context, jobID int64) (models.Job, error)
+
+	// Replay makes a failed job pending again from runAt with a fresh set of
+	// attempts (its error history stays), or returns ErrNotFound if it isn't failed
+	Replay(ctx context.Context, jobID int64, runAt time.Time) error
+
+	// Discard deletes a failed job and its error history, or returns ErrNotFound if it isn't failed
+	Discard(ctx context.Context, jobID int64) error
+
+	// Counts returns how many jobs are in each state (every state is in the map)
+	Counts(ctx context.Context) (map[string]int, error)
+
+	// PurgeSucceeded deletes jobs that succeeded before finishedBefore (with their error history)
+	PurgeSucceeded(ctx context.Context, finishedBefore time.Time) (int64, error)
+}
+
+// ScheduleRepository keeps the state of recurring jobs and the lease that lets
+// one server at a time fire them; see schedules.go
+type ScheduleRepository interface {
+	// AcquireLease makes holder the owner of lease name until `until` if the lease
+	// is free, expired at now, or already holder's (renewal); false if someone else has it
+	AcquireLease(ctx context.Context, name string, holder string, now time.Time, until time.Time) (bool, error)
+
+	// ReleaseLease gives up holder's lease so another server can take over at once
+	ReleaseLease(ctx context.Context, name string, holder string) error
+
+	// NextRun returns when schedule name fires next, or ErrNotFound if it never ran
+	NextRun(ctx context.Context, name string) (time.Time, error)
+
+	// SetNextRun moves schedule name from prev to next, or creates it when prev is
+	// zero. False if the stored time isn't prev anymore (someone else fired it).
+	SetNextRun(ctx context.Context, name string, prev time.Time, next time.Time) (bool, error)
+}
+
+// RefreshTokenRepository stores refresh tokens by their SHA-256 hash, never the token itself.
+// Tokens belong to a family (one per login); see refresh_tokens.go.
+type RefreshTokenRepository interface {
+	// Create saves the first token of a new family (login)
+	Create(ctx context.Context, userID int64, familyID string, tokenHash string, expiresAt time.Time) error
+
+	// Rotate exchanges a valid token for newHash in the same family and returns its user.
+	// A token that was already exchanged revokes the whole family and returns ErrTokenReused,
+	// an unknown, expired or revoked one returns ErrTokenInvalid.
+	Rotate(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (int64, error)
+
+	// RevokeFamily revokes every token of the family tokenHash belongs to (logout) and
+	// returns its user, or ErrTokenInvalid
+	RevokeFamily(ctx context.Context, tokenHash string) (int64, error)
+
+	// RevokeUser revokes every token of every family of the user (log out everywhere)
+	RevokeUser(ctx context.Context, userID int64) error
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"personal-analytics-backend/internal/models"
+	"strings"
+)
+
+/*
+=== EDIT HISTORY (AUDIT TRAIL) ===
+
+Update and Delete used to overwrite the row and keep nothing. Now, before
+changing an entry, they copy the current version into entry_revisions:
+
+  BEGIN
+    SELECT entry (FOR UPDATE on Postgres)    ← the version about to be replaced
+    INSERT INTO entry_revisions (...)        ← saved with action + request_id
+    UPDATE entries ...                       ← the actual change
+  COMMIT
+
+Same transaction = all or nothing: there is never a change without its
+revision, or a revision for a change that was rolled back.
+
+request_id comes from the context (RequestIDMiddleware puts it there), so the
+history can be matched with the server logs of the request that made the change.
+*/
+
+// Revision actions: what replaced the saved version
+const (
+	RevisionUpdate = "update"
+	RevisionDelete = "delete"
+)
+
+// requestIDFromContext returns the id RequestIDMiddleware stored in ctx ("" outside a request)
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value("request_id").(string)
+	return requestID
+}
+
+// saveRevision copies the current version of a live entry into entry_revisions
+// inside tx, or returns ErrNotFound (not the user's entry, or in the trash)
+func saveRevision(ctx context.Context, tx *Tx, userID int64, entryID int64, action string) error {
+	query := `SELECT COALESCE(text, ''), COALESCE(mood, 0), COALESCE(category, '')
+	          FROM entries
+	          WHERE id = ? AND user_id = ? AND deleted_at IS NULL` + tx.dialect.forUpdate
+
+	var rev models.EntryRevision
+	err := tx.queryRow(ctx, query, entryID, userID).Scan(&rev.Text, &rev.Mood, &rev.Category)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	tags, err := txEntryTags(ctx, tx, entryID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.exec(ctx, `INSERT INTO entry_revisions (entry_id, user_id, text, mood, category, tags, action, request_id)
+	                       VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entryID, userID, rev.Text, rev.Mood, rev.Category, strings.Join(tags, ","), action, requestIDFromContext(ctx))
+	return err
+}
+
+// txEntryTags is loadEntryTags for one entry, read inside tx
+// (the tags must be the ones of this transaction, not of another connection)
+func txEntryTags(ctx context.Context, tx *Tx, entryID int64) ([]string, error) {
+	rows, err := tx.query(ctx, `SELECT t.name
+	                            FROM entry_tags et
+	                            JOIN tags t ON t.id = et.tag_id
+	                            WHERE et.entry_id = ?
+	                            ORDER BY t.name`, entryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+// splitRevisionTags turns the stored "a,b" back into a list ("" = no tags)
+func splitRevisionTags(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// History returns the saved versions of an entry of the user, newest first.
+// Works for entries in the trash too; an entry without edits has an empty history.
+func (r *SQLEntryRepository) History(ctx context.Context, userID int64, entryID int64) ([]models.EntryRevision, error) {
+	// The entry itself must exist (live or trashed) and belong to the user
+	var exists int
+	err := r.conn.queryRow(ctx, `SELECT 1 FROM entries WHERE id = ? AND user_id = ?`, entryID, userID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, entry_id, COALESCE(text, ''), COALESCE(mood, 0), COALESCE(category, ''),
+	                 tags, action, request_id, changed_at
+	          FROM entry_revisions
+	          WHERE entry_id = ? AND user_id = ?
+	          ORDER BY id DESC`
+
+	rows, err := r.conn.query(ctx, query, entryID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.EntryRevision{}
+	for rows.Next() {
+		var rev models.EntryRevision
+		var tags string
+		err := rows.Scan(&rev.ID, &rev.EntryID, &rev.Text, &rev.Mood, &rev.Category,
+			&tags, &rev.Action, &rev.RequestID, &rev.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		rev.Tags = splitRevisionTags(tags)
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
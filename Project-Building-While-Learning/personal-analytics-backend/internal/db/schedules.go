@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+/*
+=== SCHEDULES AND LEASES ===
+
+Every server runs the scheduler (worker/scheduler.go), but a recurring job
+must run ONCE per tick, not once per server. Two locks, one inside the other:
+
+  LEASE          "scheduler" row in leases: only its holder looks at schedules.
+                 Renewed every tick, so if the holder dies another server takes
+                 over when expires_at has passed.
+  COMPARE-AND-SET  the holder fires a schedule by moving next_run_at from the
+                 value it read to the following one. A second server that read
+                 the same value (two holders for a moment - a paused process,
+                 clocks apart) updates 0 rows and doesn't fire.
+
+The lease keeps the other servers idle; the compare-and-set is what guarantees
+a tick is fired once.
+
+Why the database and not Redis: see worker/worker.go.
+
+Both are plain upserts (INSERT ... ON CONFLICT ... DO UPDATE ... WHERE), which
+SQLite and Postgres write the same way: 0 rows affected means the WHERE said no.
+*/
+
+// SQLScheduleRepository is the ScheduleRepository backed by SQLite or Postgres
+type SQLScheduleRepository struct {
+	conn *Conn
+}
+
+var _ ScheduleRepository = (*SQLScheduleRepository)(nil)
+
+// NewSQLScheduleRepository creates a schedule repository on an open connection (see InitDB)
+func NewSQLScheduleRepository(conn *Conn) *SQLScheduleRepository {
+	return &SQLScheduleRepository{conn: conn}
+}
+
+// AcquireLease takes or renews the lease
+func (r *SQLScheduleRepository) AcquireLease(ctx context.Context, name string, holder string, now time.Time, until time.Time) (bool, error) {
+	query := `INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+	          ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+	          WHERE leases.holder = excluded.holder OR leases.expires_at < ?`
+	return r.upsert(ctx, query, name, holder, storedTimestamp(until), storedTimestamp(now))
+}
+
+// ReleaseLease deletes the lease if holder still has it
+func (r *SQLScheduleRepository) ReleaseLease(ctx context.Context, name string, holder string) error {
+	_, err := r.conn.exec(ctx, `DELETE FROM leases WHERE name = ? AND holder = ?`, name, holder)
+	return err
+}
+
+// NextRun returns the stored next run of a schedule
+func (r *SQLScheduleRepository) NextRun(ctx context.Context, name string) (time.Time, error) {
+	var next time.Time
+	err := r.conn.queryRow(ctx, `SELECT next_run_at FROM schedules WHERE name = ?`, name).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrNotFound
+	}
+	return next, err
+}
+
+// SetNextRun moves next_run_at from prev to next (compare-and-set)
+func (r *SQLScheduleRepository) SetNextRun(ctx context.Context, name string, prev time.Time, next time.Time) (bool, error) {
+	// prev zero: the INSERT is meant to happen; if the row exists after all, the
+	// WHERE never matches (no schedule is stored at year 1) and nothing changes
+	query := `INSERT INTO schedules (name, next_run_at) VALUES (?, ?)
+	          ON CONFLICT (name) DO UPDATE SET next_run_at = excluded.next_run_at
+	          WHERE schedules.next_run_at = ?`
+	return r.upsert(ctx, query, name, storedTimestamp(next), storedTimestamp(prev))
+}
+
+// upsert runs an INSERT ... ON CONFLICT DO UPDATE ... WHERE; false if it changed nothing
+func (r *SQLScheduleRepository) upsert(ctx context.Context, query string, args ...any) (bool, error) {
+	result, err := r.conn.exec(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// scheduleRepos runs the same test against SQLite and the in-memory store
+var scheduleRepos = []struct {
+	name string
+	new  func(t *testing.T) ScheduleRepository
+}{
+	{"sqlite", func(t *testing.T) ScheduleRepository { return NewSQLScheduleRepository(newMigratedConn(t)) }},
+	{"memory", func(t *testing.T) ScheduleRepository { return NewMemoryScheduleRepository() }},
+}
+
+func TestAcquireLease(t *testing.T) {
+	base := time.Date(2026, 1, 12, 10, 0, 0, 0, time.UTC)
+	const ttl = 30 * time.Second
+
+	// One sequence: each step depends on the lease the previous ones left
+	steps := []struct {
+		name    string
+		holder  string
+		at      time.Duration // after base; the lease lasts ttl from there
+		release bool          // ReleaseLease instead of AcquireLease
+		want    bool
+	}{
+		{name: "free lease", holder: "a", want: true},
+		{name: "held by another server", holder: "b", at: 10 * time.Second, want: false},
+		{name: "renewed by its holder", holder: "a", at: 20 * time.Second, want: true},
+		{name: "still held at the renewed expiry", holder: "b", at: 20*time.Second + ttl, want: false},
+		{name: "taken over once expired", holder: "b", at: 21*time.Second + ttl, want: true},
+		{name: "the old holder lost it", holder: "a", at: 22*time.Second + ttl, want: false},
+		{name: "release by a non-holder is ignored", holder: "a", release: true},
+		{name: "so it is still held", holder: "a", at: 23*time.Second + ttl, want: false},
+		{name: "release by the holder", holder: "b", release: true},
+		{name: "free again at once", holder: "a", at: 24*time.Second + ttl, want: true},
+	}
+	for _, r := range scheduleRepos {
+		t.Run(r.name, func(t *testing.T) {
+			repo := r.new(t)
+			ctx := context.Background()
+			for _, s := range steps {
+				if s.release {
+					if err := repo.ReleaseLease(ctx, "scheduler", s.holder); err != nil {
+						t.Fatalf("%s: %v", s.name, err)
+					}
+					continue
+				}
+				now := base.Add(s.at)
+				got, err := repo.AcquireLease(ctx, "scheduler", s.holder, now, now.Add(ttl))
+				if err != nil {
+					t.Fatalf("%s: %v", s.name, err)
+				}
+				if got != s.want {
+					t.Errorf("%s: %s got the lease %v, want %v", s.name, s.holder, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetNextRun(t *testing.T) {
+	first := time.Date(2026, 1, 12, 3, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+	third := second.AddDate(0, 0, 1)
+
+	steps := []struct {
+		name       string
+		prev, next time.Time
+		want       bool
+		stored     time.Time // NextRun afterwards
+	}{
+		{"first run creates the row", time.Time{}, first, true, first},
+		{"a second creation changes nothing", time.Time{}, third, false, first},
+		{"moves on from the value read", first, second, true, second},
+		{"a server that read the old value loses", first, second, false, second},
+		{"stale prev can't skip ahead", first, third, false, second},
+		{"the current value wins", second, third, true, third},
+	}
+	for _, r := range scheduleRepos {
+		t.Run(r.name, func(t *testing.T) {
+			repo := r.new(t)
+			ctx := context.Background()
+			if _, err := repo.NextRun(ctx, "daily"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("NextRun of a schedule that never ran: %v, want ErrNotFound", err)
+			}
+
+			for _, s := range steps {
+				got, err := repo.SetNextRun(ctx, "daily", s.prev, s.next)
+				if err != nil {
+					t.Fatalf("%s: %v", s.name, err)
+				}
+				if got != s.want {
+					t.Errorf("%s: set %v, want %v", s.name, got, s.want)
+				}
+				stored, err := repo.NextRun(ctx, "daily")
+				if err != nil {
+					t.Fatalf("%s: %v", s.name, err)
+				}
+				if !stored.Equal(s.stored) {
+					t.Errorf("%s: next run %v, want %v", s.name, stored, s.stored)
+				}
+			}
+
+			// Schedules are independent rows
+			if ok, err := repo.SetNextRun(ctx, "hourly", time.Time{}, first); err != nil || !ok {
+				t.Errorf("another schedule: %v (%v), want created", ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"personal-analytics-backend/internal/models"
+	"strings"
+	"unicode"
+)
+
+/*
+=== FULL-TEXT SEARCH WITH SQLITE FTS5 ===
+
+Problem: text LIKE '%gym%' cannot use an index. SQLite reads EVERY row of the
+user's entries and scans every character. Fine for 100 entries, slow for 100k.
+
+Solution: FTS5 virtual table = an inverted index (word → list of row ids).
+Like the index at the back of a book: look up "gym", get the pages directly.
+
+=== EXTERNAL CONTENT TABLE ===
+
+content='entries' means the FTS table does NOT store its own copy of the text.
+It only stores the index and reads the text from entries when it needs it
+(e.g. for snippet()). content_rowid='id' links FTS rows to entries.id.
+
+The catch: FTS5 doesn't watch entries for changes - we keep it in sync
+ourselves with triggers (insert / delete / update of text).
+Table and triggers are created by migrations/0002_create_entries_fts.up.sql.
+
+=== RANKING ===
+
+bm25() scores how well a row matches (term frequency vs how common the word is).
+FTS5 returns it as a NEGATIVE number where lower = better, so we flip the sign
+and expose "score" where higher = better.
+
+=== POSTGRES ===
+
+Same idea, different names (see postgresDialect in dialect.go):
+  FTS5 table        → tsvector column entries.text_search + GIN index
+  MATCH '"gym"*'    → text_search @@ to_tsquery('simple', 'gym:*')
+  snippet() / bm25  → ts_headline() / ts_rank()
+Scores are not comparable between the two databases, only the order matters.
+*/
+
+// searchEntryColumns is entryColumns for the search queries, where entries is aliased e
+const searchEntryColumns = `e.id, e.user_id, COALESCE(e.text, ''), COALESCE(e.mood, 0), COALESCE(e.category, ''), e.created_at`
+
+// ftsMatchQuery turns user input into a safe FTS5 MATCH expression.
+//
+// FTS5 has its own query syntax (AND, OR, NEAR, quotes, *, column filters...)
+// so raw user input can be a syntax error or do something unexpected.
+// We split the input into words ourselves and quote each one:
+//
+//	`gym felt` → `"gym"* "felt"*`
+//
+// Quoted = taken literally. Trailing * = prefix match ("gym" matches "gymnastics").
+// Words separated by space = all must match (implicit AND).
+// Returns "" if the input contains no letters or digits.
+func ftsMatchQuery(input string) string {
+	words := searchWords(input)
+
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, `"`+w+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// tsQuery is ftsMatchQuery for Postgres: `gym felt` → `gym:* & felt:*`
+// Words only contain letters and digits, so they can't break the tsquery syntax.
+func tsQuery(input string) string {
+	words := searchWords(input)
+
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, w+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// searchWords splits input into words: runs of letters and digits, everything else separates
+func searchWords(input string) []string {
+	return strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// HasSearchTerms reports whether q contains anything full-text search can match
+func HasSearchTerms(q string) bool {
+	return len(searchWords(q)) > 0
+}
+
+// Search runs a full-text search over the user's entries.
+// Results are ordered by relevance and include a snippet with the hits
+// wrapped in <mark></mark>.
+func (r *SQLEntryRepository) Search(ctx context.Context, userID int64, q string, limit int) ([]models.SearchResult, error) {
+	// The query itself is per dialect (FTS5 vs tsvector), see dialect.go
+	d := r.conn.dialect
+	rows, err := r.conn.query(ctx, d.searchQuery, d.matchQuery(q), userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var res models.SearchResult
+		err := rows.Scan(&res.ID, &res.UserID, &res.Text, &res.Mood, &res.Category, &res.CreatedAt, &res.Snippet, &res.Score)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(results))
+	for i, res := range results {
+		ids[i] = res.ID
+	}
+	tagsByEntry, err := r.loadEntryTags(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = tagsByEntry[results[i].ID]
+		if results[i].Tags == nil {
+			results[i].Tags = []string{}
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"personal-analytics-backend/internal/models"
+	"strings"
+)
+
+/*
+=== TAGS: MANY-TO-MANY ===
+
+One entry has many tags, one tag is used by many entries.
+A "tags" TEXT column ("gym,happy") can't be indexed or counted properly,
+so we use a join table (see migrations/0003_create_tags.up.sql):
+
+  entries            entry_tags             tags
+  id  text           entry_id  tag_id       id  user_id  name
+  7   "leg day"  ←→  7         1       ←→   1   3        "gym"
+                     7         2       ←→   2   3        "happy"
+
+Tag names are normalized before they reach the DB (NormalizeTags), so
+"Gym", " gym " and "GYM" are all the same tag.
+*/
+
+// NormalizeTags trims, lowercases and de-duplicates tag names and drops empty ones.
+// Order of first appearance is kept: ["Gym", "happy", "gym "] → ["gym", "happy"]
+// Returns nil for a nil input, so callers can still tell "not sent" from "sent empty".
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, t := range tags {
+		name := strings.ToLower(strings.TrimSpace(t))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	return normalized
+}
+
+// setEntryTags replaces the tags of an entry inside an existing transaction.
+// Tags that don't exist yet for this user are created.
+func setEntryTags(ctx context.Context, tx *Tx, userID int64, entryID int64, tags []string) error {
+	_, err := tx.exec(ctx, `DELETE FROM entry_tags WHERE entry_id = ?`, entryID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		// ON CONFLICT DO NOTHING: the tag may already exist (UNIQUE user_id, name)
+		_, err := tx.exec(ctx, `INSERT INTO tags (user_id, name) VALUES (?, ?) ON CONFLICT (user_id, name) DO NOTHING`, userID, name)
+		if err != nil {
+			return err
+		}
+
+		// VALUES with a subquery (not INSERT ... SELECT ?, id) so Postgres can
+		// infer the type of the entry_id placeholder from the column
+		_, err = tx.exec(ctx, `INSERT INTO entry_tags (entry_id, tag_id)
+		                  VALUES (?, (SELECT id FROM tags WHERE user_id = ? AND name = ?))`, entryID, userID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadEntryTags returns the tag names of each given entry, sorted by name.
+// One query for the whole page instead of one query per entry (N+1 problem).
+func (r *SQLEntryRepository) loadEntryTags(ctx context.Context, entryIDs []int64) (map[int64][]string, error) {
+	tagsByEntry := map[int64][]string{}
+	if len(entryIDs) == 0 {
+		return tagsByEntry, nil
+	}
+
+	// Build "?, ?, ?" - one placeholder per id
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(entryIDs)), ", ")
+	args := make([]interface{}, len(entryIDs))
+	for i, id := range entryIDs {
+		args[i] = id
+	}
+
+	query := `SELECT et.entry_id, t.name
+	          FROM entry_tags et
+	          JOIN tags t ON t.id = et.tag_id
+	          WHERE et.entry_id IN (` + placeholders + `)
+	          ORDER BY t.name`
+
+	rows, err := r.conn.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entryID int64
+		var name string
+		if err := rows.Scan(&entryID, &name); err != nil {
+			return nil, err
+		}
+		tagsByEntry[entryID] = append(tagsByEntry[entryID], name)
+	}
+	return tagsByEntry, rows.Err()
+}
+
+// attachTags fills in the Tags of every entry (empty list if it has none)
+func (r *SQLEntryRepository) attachTags(ctx context.Context, entries []models.Entry) error {
+	ids := make([]int64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+
+	tagsByEntry, err := r.loadEntryTags(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		entries[i].Tags = tagsByEntry[entries[i].ID]
+		if entries[i].Tags == nil {
+			entries[i].Tags = []string{} // JSON [] instead of null
+		}
+	}
+	return nil
+}
+
+// Tags lists every tag the user has on at least one entry,
+// with how many entries use it - most used first
+func (r *SQLEntryRepository) Tags(ctx context.Context, userID int64) ([]models.TagCount, error) {
+	// The JOIN on entries leaves out tags that are only used by trashed entries
+	query := `SELECT t.name, COUNT(et.entry_id) AS usage_count
+	          FROM tags t
+	          JOIN entry_tags et ON et.tag_id = t.id
+	          JOIN entries e ON e.id = et.entry_id AND e.deleted_at IS NULL
+	          WHERE t.user_id = ?
+	          GROUP BY t.id, t.name
+	          ORDER BY usage_count DESC, t.name`
+
+	rows, err := r.conn.query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var t models.TagCount
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// tagFilterClause matches entries that have ALL of the given tags:
+// count how many of the wanted tags each entry has, keep entries where that equals len(tags)
+func tagFilterClause(tags []string) (string, []interface{}) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+	args := make([]interface{}, 0, len(tags)+1)
+	for _, t := range tags {
+		args = append(args, t)
+	}
+	args = append(args, len(tags))
+
+	clause := ` AND id IN (SELECT et.entry_id FROM entry_tags et
+	            JOIN tags t ON t.id = et.tag_id
+	            WHERE t.name IN (` + placeholders + `)
+	            GROUP BY et.entry_id HAVING COUNT(*) = ?)`
+	return clause, args
+}
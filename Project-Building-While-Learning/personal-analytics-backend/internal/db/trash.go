@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"personal-analytics-backend/internal/models"
+	"time"
+)
+
+/*
+=== SOFT DELETE (TRASH) ===
+
+Hard delete: DELETE FROM entries → a mis-tap loses the entry forever.
+Soft delete: UPDATE entries SET deleted_at = now → the row stays, it is just hidden.
+
+  DELETE /entries?id=5          deleted_at = now     (moved to the trash)
+  POST /entries/restore?id=5    deleted_at = NULL    (back to normal)
+  purge_trash job, every hour   DELETE rows trashed longer than the retention
+
+The catch: EVERY read must now say "AND deleted_at IS NULL", or trashed
+entries show up in lists, counts, search, tags and analytics. That is why the
+condition sits right next to user_id = ? in every query - the two always go together.
+
+Tags and the full-text index keep their rows while an entry is in the trash,
+so Restore brings the entry back exactly as it was. They are cleaned up by
+the same triggers / ON DELETE CASCADE as before once PurgeTrash really deletes it.
+*/
+
+// ListTrash returns one page of the user's trashed entries, most recently deleted first
+func (r *SQLEntryRepository) ListTrash(ctx context.Context, userID int64, page int, limit int) ([]models.Entry, int, error) {
+	// Not cached like count(): the trash is rarely looked at
+	var total int
+	query := `SELECT COUNT(*) FROM entries WHERE user_id = ? AND deleted_at IS NOT NULL`
+	if err := r.conn.queryRow(ctx, query, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query = `SELECT ` + entryColumns + `, deleted_at
+	         FROM entries
+	         WHERE user_id = ? AND deleted_at IS NOT NULL
+	         ORDER BY deleted_at DESC, id DESC
+	         LIMIT ? OFFSET ?`
+
+	rows, err := r.conn.query(ctx, query, userID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []models.Entry{}
+	for rows.Next() {
+		var deletedAt sql.NullTime
+		entry, err := scanEntry(rows, &deletedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		entry.DeletedAt = &deletedAt.Time
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.attachTags(ctx, entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// Restore takes an entry of the user out of the trash
+func (r *SQLEntryRepository) Restore(ctx context.Context, userID int64, entryID int64) error {
+	query := `UPDATE entries SET deleted_at = NULL WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+
+	result, err := r.conn.exec(ctx, query, entryID, userID)
+	if err != nil {
+		return err
+	}
+
+	// 0 rows = not found, not the user's, or not in the trash (already restored / purged)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	invalidateCounts(userID)
+	return nil
+}
+
+// PurgeTrash permanently deletes every entry trashed before deletedBefore.
+// Runs for all users at once (it is called by the purge_trash job, not by a request).
+// Counts don't need invalidating: trashed entries were already left out of them.
+func (r *SQLEntryRepository) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `DELETE FROM entries WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+
+	result, err := r.conn.exec(ctx, query, storedTimestamp(deletedBefore))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"personal-analytics-backend/internal/models"
+	"time"
+)
+
+// SQLUserRepository is the UserRepository backed by SQLite or Postgres
+type SQLUserRepository struct {
+	conn *Conn
+}
+
+var _ UserRepository = (*SQLUserRepository)(nil)
+
+// NewSQLUserRepository creates a user repository on an open connection (see InitDB)
+func NewSQLUserRepository(conn *Conn) *SQLUserRepository {
+	return &SQLUserRepository{conn: conn}
+}
+
+// Create inserts a new user
+// Takes email and hashed password (NOT plain password!)
+func (r *SQLUserRepository) Create(ctx context.Context, email string, passwordHash string) (int64, error) {
+	query := `INSERT INTO users (email, password_hash) VALUES (?, ?) RETURNING id`
+
+	var id int64
+	err := r.conn.queryRow(ctx, query, email, passwordHash).Scan(&id)
+	// Each database reports the UNIQUE(email) violation its own way - the
+	// dialect translates it so handlers only ever see ErrEmailTaken
+	if r.conn.dialect.isUniqueViolation(err) {
+		return 0, ErrEmailTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	slog.Debug("User created", "user_id", id)
+	return id, nil
+}
+
+// GetByEmail retrieves a user by their email (login)
+func (r *SQLUserRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = ?`
+	return scanUser(r.conn.queryRow(ctx, query, email))
+}
+
+// GetByID retrieves a user by id
+func (r *SQLUserRepository) GetByID(ctx context.Context, userID int64) (models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	return scanUser(r.conn.queryRow(ctx, query, userID))
+}
+
+// SetPassword replaces the password hash (the caller hashes, like Create)
+func (r *SQLUserRepository) SetPassword(ctx context.Context, userID int64, passwordHash string) error {
+	query := `UPDATE users SET password_hash = ? WHERE id = ?`
+	return r.updateOne(ctx, query, passwordHash, userID)
+}
+
+// MarkEmailVerified sets email_verified_at unless it is already set
+func (r *SQLUserRepository) MarkEmailVerified(ctx context.Context, userID int64) error {
+	// COALESCE keeps the first verification time when the link is used again
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?`
+	return r.updateOne(ctx, query, storedTimestamp(time.Now()), userID)
+}
+
+// List returns one page of all users, oldest first (admin user list)
+func (r *SQLUserRepository) List(ctx context.Context, page int, limit int) ([]models.User, int, error) {
+	var total int
+	if err := r.conn.queryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY id LIMIT ? OFFSET ?`
+	rows, err := r.conn.query(ctx, query, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	return users, total, rows.Err()
+}
+
+// SetRole changes the user's role
+func (r *SQLUserRepository) SetRole(ctx context.Context, userID int64, role string) error {
+	query := `UPDATE users SET role = ? WHERE id = ?`
+	return r.updateOne(ctx, query, role, userID)
+}
+
+// SetDisabled sets or clears disabled_at
+func (r *SQLUserRepository) SetDisabled(ctx context.Context, userID int64, disabled bool) error {
+	if !disabled {
+		return r.updateOne(ctx, `UPDATE users SET disabled_at = NULL WHERE id = ?`, userID)
+	}
+	// COALESCE keeps the first time, like MarkEmailVerified
+	query := `UPDATE users SET disabled_at = COALESCE(disabled_at, ?) WHERE id = ?`
+	return r.updateOne(ctx, query, storedTimestamp(time.Now()), userID)
+}
+
+// UpdateProfile replaces display name, time zone, week start and mood labels
+func (r *SQLUserRepository) UpdateProfile(ctx context.Context, userID int64, profile ProfileInput) error {
+	moodLabels, err := json.Marshal(profile.MoodLabels)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE users SET display_name = ?, timezone = ?, week_start = ?, mood_labels = ? WHERE id = ?`
+	return r.updateOne(ctx, query, profile.DisplayName, profile.Timezone, profile.WeekStart, string(moodLabels), userID)
+}
+
+// updateOne runs an UPDATE of one user, 0 rows affected = ErrNotFound
+func (r *SQLUserRepository) updateOne(ctx context.Context, query string, args ...any) error {
+	result, err := r.conn.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// userColumns is the column list scanUser expects, in order
+// UnnormalizedEmailUserIDs returns the accounts whose stored email isn't trimmed
+// and lowercase. Migration 0014 leaves them when two accounts normalize to the
+// same address; Login can't find them until an operator merges or renames them.
+func UnnormalizedEmailUserIDs(ctx context.Context, conn *Conn) ([]int64, error) {
+	rows, err := conn.query(ctx, `SELECT id FROM users WHERE email <> LOWER(TRIM(email)) ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+const userColumns = `id, email, password_hash, created_at, email_verified_at, role, disabled_at,
+	display_name, timezone, week_start, mood_labels`
+
+// scanUser reads one users row, sql.ErrNoRows becomes ErrNotFound
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	var verifiedAt, disabledAt sql.NullTime
+	var moodLabels string
+	err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.CreatedAt, &verifiedAt, &u.Role, &disabledAt,
+		&u.DisplayName, &u.Timezone, &u.WeekStart, &moodLabels)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := json.Unmarshal([]byte(moodLabels), &u.MoodLabels); err != nil {
+		return models.User{}, fmt.Errorf("mood_labels of user %d: %w", u.ID, err)
+	}
+	if u.MoodLabels == nil {
+		u.MoodLabels = map[int]string{} // JSON {} instead of null
+	}
+	if verifiedAt.Valid {
+		u.EmailVerifiedAt = &verifiedAt.Time
+	}
+	if disabledAt.Valid {
+		u.DisabledAt = &disabledAt.Time
+	}
+	return u, nil
+}
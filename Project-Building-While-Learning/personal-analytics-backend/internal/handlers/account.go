@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/mail"
+	"personal-analytics-backend/internal/worker"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+/*
+=== EMAIL VERIFICATION + PASSWORD RESET ===
+
+  POST /register          → account created + mail with GET /verify?token=...
+  GET  /verify?token=     → email_verified_at set
+
+  POST /password/forgot   → job "password_reset" → mail with a reset token (if the
+                            email exists - the response is the same either way,
+                            no account enumeration)
+  POST /password/reset    → new password, every session logged out
+
+Tokens: random, stored as SHA-256, single-use, expiring (db/user_tokens.go).
+Mails go through the worker pool (job "send_email") to mail.Default, so the
+response time doesn't depend on the mail provider. /password/forgot doesn't
+even look the email up before answering: the lookup is a job too, or a fast
+"unknown email" answer would give registered ones away.
+*/
+
+// PasswordResetJob is the payload of password_reset: the email to look up, and
+// the IP that asked, for the log
+type PasswordResetJob struct {
+	Email string `json:"email"`
+	IP    string `json:"ip"`
+}
+
+// Email link lifetimes and the server's public URL, overwritten by main.go from config
+var (
+	VerifyTokenTTL = 24 * time.Hour
+	ResetTokenTTL  = time.Hour
+	AppBaseURL     = "http://localhost:8080" // links in mails start with this
+)
+
+// ForgotPasswordRequest is the body of POST /password/forgot
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest is the body of POST /password/reset
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// sendVerificationEmail creates a verification token and queues the mail with the link
+func (h *Handler) sendVerificationEmail(ctx context.Context, userID int64, email string) error {
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = h.userTokens.Create(ctx, userID, db.TokenPurposeVerifyEmail, tokenHash, time.Now().Add(VerifyTokenTTL))
+	if err != nil {
+		return err
+	}
+
+	link := AppBaseURL + "/verify?token=" + url.QueryEscape(token)
+	return worker.AddJob(JobSendEmail, mail.Message{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: "Welcome to Personal Analytics!\n\n" +
+			"Open this link to confirm your email address:\n" + link + "\n\n" +
+			"The link expires in " + VerifyTokenTTL.String() + ". If you didn't sign up, ignore this mail.",
+	})
+}
+
+// sendPasswordResetEmail creates a reset token and queues the mail with it
+func (h *Handler) sendPasswordResetEmail(ctx context.Context, userID int64, email string) error {
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = h.userTokens.Create(ctx, userID, db.TokenPurposeResetPassword, tokenHash, time.Now().Add(ResetTokenTTL))
+	if err != nil {
+		return err
+	}
+
+	return worker.AddJob(JobSendEmail, mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: "Someone asked to reset the password of your Personal Analytics account.\n\n" +
+			"Reset token: " + token + "\n" +
+			"Send it with your new password to POST " + AppBaseURL + "/password/reset\n\n" +
+			"The token expires in " + ResetTokenTTL.String() + " and works once. " +
+			"If this wasn't you, ignore this mail - your password stays the same.",
+	})
+}
+
+// ForgotPassword handles POST /password/forgot
+// Always answers 200 with the same message, whether or not the email is registered,
+// and after the same work: the lookup runs in the password_reset job
+// (passwordResetJob), so the response time can't tell registered emails apart either.
+// Every request counts against the email's and IP's login lockout, or one
+// address could be flooded with reset mails.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponseAuth(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	email := normalizeEmail(req.Email) // as Register stored it
+	if email == "" {
+		errorResponseAuth(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	// Unknown emails are locked like real ones (lockout.go), so a 429 reveals nothing
+	ip := clientIP(r)
+	if wait := loginLockedFor(email, ip); wait > 0 {
+		loginAudit("password_reset_locked", email, ip, "retry_after_seconds", wait.Seconds())
+		respondLoginLocked(w, wait)
+		return
+	}
+	recordLoginFailure(email, ip)
+
+	if err := worker.AddJob(JobPasswordReset, PasswordResetJob{Email: email, IP: ip}); err != nil {
+		logger.Error("Error queueing password reset", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, RegisterResponse{
+		Success: true,
+		Message: "If the email is registered, a password reset token has been sent",
+	})
+}
+
+// passwordResetJob is the lookup behind POST /password/forgot: a reset mail
+// if the email is registered, only a log line if it isn't
+func (h *Handler) passwordResetJob(ctx context.Context, job worker.Job, payload PasswordResetJob) error {
+	user, err := h.users.GetByEmail(ctx, payload.Email)
+	if errors.Is(err, db.ErrNotFound) {
+		slog.Warn("Password reset requested for unknown email", "email", payload.Email, "ip", payload.IP)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// A retry after a failed AddJob creates a new token: the newest one is the one that works
+	if err := h.sendPasswordResetEmail(ctx, user.ID, user.Email); err != nil {
+		return err
+	}
+	slog.Info("Password reset requested", "user_id", user.ID, "job_id", job.ID)
+	return nil
+}
+
+// ResetPassword handles POST /password/reset
+// Sets the new password and logs the user out everywhere: whoever knew the old
+// password (maybe the reason for the reset) loses access too.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponseAuth(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		errorResponseAuth(w, http.StatusBadRequest, "token is required")
+		return
+	}
+	// Same password rules as Register
+	if len(req.Password) < 6 {
+		errorResponseAuth(w, http.StatusBadRequest, "password must be at least 6 characters")
+		return
+	}
+
+	// Hash BEFORE using up the token: if hashing fails, the link still works
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("Error hashing password", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	userID, err := h.userTokens.Consume(r.Context(), db.TokenPurposeResetPassword, hashOpaqueToken(req.Token))
+	if errors.Is(err, db.ErrTokenInvalid) {
+		logger.Warn("Invalid password reset token", "ip", clientIP(r))
+		errorResponseAuth(w, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	}
+	if err != nil {
+		logger.Error("Error consuming reset token", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	if err := h.users.SetPassword(r.Context(), userID, string(passwordHash)); err != nil {
+		logger.Error("Error saving new password", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	// Log out everywhere: refresh tokens in the database, access tokens on the revocation list
+	if err := h.tokens.RevokeUser(r.Context(), userID); err != nil {
+		logger.Error("Error revoking sessions after password reset", "error", err, "user_id", userID)
+	}
+	cache.RevokeUserTokens(userID, time.Now(), AccessTokenTTL)
+
+	// The reset mail reached the user, so the address works: verified, and any lockout is over
+	if user, err := h.users.GetByID(r.Context(), userID); err == nil {
+		clearLoginFailures(normalizeEmail(user.Email))
+	}
+	if err := h.users.MarkEmailVerified(r.Context(), userID); err != nil {
+		logger.Error("Error marking email verified", "error", err, "user_id", userID)
+	}
+
+	logger.Warn("Password reset", "event", "password_reset", "user_id", userID, "ip", clientIP(r))
+	respondJSON(w, http.StatusOK, RegisterResponse{
+		Success: true,
+		Message: "Password has been reset, please log in again",
+	})
+}
+
+// VerifyEmail handles GET /verify?token=...
+// A GET so the link in the mail works with one click.
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		errorResponseAuth(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	userID, err := h.userTokens.Consume(r.Context(), db.TokenPurposeVerifyEmail, hashOpaqueToken(token))
+	if errors.Is(err, db.ErrTokenInvalid) {
+		errorResponseAuth(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+	if err != nil {
+		logger.Error("Error consuming verification token", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	if err := h.users.MarkEmailVerified(r.Context(), userID); err != nil {
+		logger.Error("Error marking email verified", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	logger.Info("Email verified", "user_id", userID)
+	respondJSON(w, http.StatusOK, RegisterResponse{
+		Success: true,
+		Message: "Email verified",
+		UserID:  userID,
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
+	"personal-analytics-backend/internal/worker"
+	"testing"
+	"time"
+)
+
+func TestForgotPassword(t *testing.T) {
+	fake := useFakeRedis(t)
+	ctx := context.Background()
+
+	h, _ := newSQLTestHandler(t)
+	if _, err := h.users.Create(ctx, "known@example.com", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	registerTestJobs(h)
+	previousStore := worker.Store
+	defer func() { worker.Store = previousStore }()
+
+	tests := []struct {
+		email    string
+		wantMail bool
+	}{
+		{"known@example.com", true},
+		{"unknown@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			jobs := db.NewMemoryJobRepository()
+			worker.Store = jobs
+
+			// Same answer, and nothing looked up yet: only the job is queued
+			var resp RegisterResponse
+			r := newRequest(http.MethodPost, "/password/forgot", `{"email":" `+tt.email+`"}`, 0)
+			if code := serve(t, h.ForgotPassword, r, &resp); code != http.StatusOK || !resp.Success {
+				t.Fatalf("status %d (%s), want 200", code, resp.Message)
+			}
+			if !fake.sent("INCR loginfail:email:" + tt.email) {
+				t.Error("request not counted by the lockout")
+			}
+			job, err := jobs.Claim(ctx, "test", time.Now(), time.Now().Add(time.Minute), nil)
+			if err != nil || job.Type != JobPasswordReset {
+				t.Fatalf("queued job %q (%v), want %s", job.Type, err, JobPasswordReset)
+			}
+
+			// The job finds out whether there is anyone to mail
+			var payload PasswordResetJob
+			if err := json.Unmarshal(job.Payload, &payload); err != nil || payload.Email != tt.email {
+				t.Fatalf("payload %s (%v), want email %s", job.Payload, err, tt.email)
+			}
+			if err := h.passwordResetJob(ctx, worker.Job{ID: job.ID, Type: job.Type, Payload: job.Payload}, payload); err != nil {
+				t.Fatal(err)
+			}
+			mailJob, err := jobs.Claim(ctx, "test", time.Now(), time.Now().Add(time.Minute), nil)
+			switch {
+			case tt.wantMail && (err != nil || mailJob.Type != JobSendEmail):
+				t.Errorf("queued job %q (%v), want %s", mailJob.Type, err, JobSendEmail)
+			case !tt.wantMail && !errors.Is(err, db.ErrNotFound):
+				t.Errorf("queued job %q (%v), want none", mailJob.Type, err)
+			}
+		})
+	}
+
+	// Locked like a login: no job, 429 with Retry-After
+	lock := "loginlock:email:known@example.com"
+	cache.AppCache.Set(lock, time.Now().Add(time.Minute).Unix(), time.Minute)
+	defer cache.AppCache.Delete(lock)
+	jobs := db.NewMemoryJobRepository()
+	worker.Store = jobs
+	r := newRequest(http.MethodPost, "/password/forgot", `{"email":"known@example.com"}`, 0)
+	if code := serve(t, h.ForgotPassword, r, nil); code != http.StatusTooManyRequests {
+		t.Errorf("locked: status %d, want 429", code)
+	}
+	if counts, _ := jobs.Counts(ctx); counts[models.JobPending] != 0 {
+		t.Errorf("locked: %d jobs queued, want 0", counts[models.JobPending])
+	}
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/worker"
+)
+
+/*
+=== ADMIN API ===
+
+Every route here is wrapped in AuthMiddleware + RequireRole(models.RoleAdmin)
+in main.go - the handlers themselves don't check the role again.
+
+  GET  /admin/users                  all accounts, page by page
+  POST /admin/users/{id}/disable     account can't log in or refresh, sessions and API keys revoked
+  POST /admin/users/{id}/enable      undo
+  GET  /admin/stats                  users, entries, background jobs, and this server process
+
+  GET    /admin/jobs/failed              the dead-letter queue (worker/deadletter.go), page by page
+  GET    /admin/jobs/failed/{id}         one failed job with its payload and every error
+  POST   /admin/jobs/failed/{id}/replay  run it again, with a fresh set of attempts
+  DELETE /admin/jobs/failed/{id}         throw it away
+
+The list leaves payloads out: a send_email payload holds the whole mail, reset
+link included, and an admin browsing failures doesn't need to see it. The
+single job shows it, because that is what someone debugging the failure needs.
+*/
+
+// serverStartedAt is when this process started, for uptime in GET /admin/stats
+var serverStartedAt = time.Now()
+
+// AdminListUsers handles GET /admin/users?page=1&limit=20
+// Lists all accounts, oldest first (password hashes are never in the JSON, see models.User)
+func (h *Handler) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Same limits as GET /entries, bigger default page
+	page, limit := 1, 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	users, total, err := h.users.List(r.Context(), page, limit)
+	if err != nil {
+		logger.Error("Failed to list users", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to list users")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"users":      users,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+	})
+}
+
+// AdminDisableUser handles POST /admin/users/{id}/disable
+func (h *Handler) AdminDisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+// AdminEnableUser handles POST /admin/users/{id}/enable
+func (h *Handler) AdminEnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+// setUserDisabled is the shared body of disable / enable
+func (h *Handler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	targetID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	// An admin locking themselves out is always a mistake (and maybe the last admin)
+	adminID, _ := userIDFromContext(r)
+	if disabled && targetID == adminID {
+		errorResponse(w, http.StatusBadRequest, "You can't disable your own account")
+		return
+	}
+
+	err = h.users.SetDisabled(r.Context(), targetID, disabled)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to update user", "error", err, "user_id", targetID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	message, event := "User enabled", "user_enabled"
+	if disabled {
+		// Kick the user out NOW instead of when their access token expires:
+		// refresh tokens revoked in the database, access tokens on the revocation list,
+		// API keys revoked (they stay revoked after enabling - the user makes new ones)
+		if err := h.tokens.RevokeUser(r.Context(), targetID); err != nil {
+			logger.Error("Error revoking sessions of disabled user", "error", err, "user_id", targetID)
+		}
+		cache.RevokeUserTokens(targetID, time.Now(), AccessTokenTTL)
+		if err := h.apiKeys.RevokeUser(r.Context(), targetID); err != nil {
+			logger.Error("Error revoking API keys of disabled user", "error", err, "user_id", targetID)
+		}
+		message, event = "User disabled", "user_disabled"
+	}
+
+	// Audit trail: who did what to whom
+	logger.Warn("Admin action", "event", event, "user_id", targetID, "admin_id", adminID)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": message,
+		"user_id": targetID,
+	})
+}
+
+// AdminStats handles GET /admin/stats
+// Counts across ALL users plus a look at this server process
+// (request metrics stay at GET /metrics)
+func (h *Handler) AdminStats(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userStats, err := h.users.Stats(r.Context())
+	if err != nil {
+		logger.Error("Failed to count users", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load stats")
+		return
+	}
+	entryStats, err := h.entries.Stats(r.Context())
+	if err != nil {
+		logger.Error("Failed to count entries", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load stats")
+		return
+	}
+	// The job queue is shared by all servers, like users and entries
+	jobCounts, err := worker.QueueCounts(r.Context())
+	if err != nil {
+		logger.Error("Failed to count jobs", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load stats")
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"users":   userStats,
+		"entries": entryStats,
+		"jobs":    jobCounts,
+		"server": map[string]interface{}{
+			"uptime_seconds":   int64(time.Since(serverStartedAt).Seconds()),
+			"goroutines":       runtime.NumGoroutine(),
+			"heap_alloc_bytes": mem.HeapAlloc,
+		},
+	})
+}
+
+// AdminListFailedJobs handles GET /admin/jobs/failed?page=1&limit=20
+func (h *Handler) AdminListFailedJobs(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Same paging as GET /admin/users
+	page, limit := 1, 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	jobs, total, err := worker.DeadLetters(r.Context(), page, limit)
+	if err != nil {
+		logger.Error("Failed to list failed jobs", "error", err)
+		errorResponse(w, http.StatusInternalServerError, "Failed to list failed jobs")
+		return
+	}
+	for i := range jobs {
+		jobs[i].Payload = nil // see the comment at the top
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"jobs":       jobs,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+	})
+}
+
+// AdminGetFailedJob handles GET /admin/jobs/failed/{id}
+func (h *Handler) AdminGetFailedJob(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	job, err := worker.DeadLetter(r.Context(), jobID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "Failed job not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to load failed job", "error", err, "job_id", jobID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load job")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"job":     job,
+	})
+}
+
+// AdminReplayFailedJob handles POST /admin/jobs/failed/{id}/replay
+func (h *Handler) AdminReplayFailedJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.changeFailedJob(w, r, worker.Replay, "job_replayed", "Job queued again")
+}
+
+// AdminDiscardFailedJob handles DELETE /admin/jobs/failed/{id}
+func (h *Handler) AdminDiscardFailedJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.changeFailedJob(w, r, worker.Discard, "job_discarded", "Job discarded")
+}
+
+// changeFailedJob is the shared body of replay / discard
+func (h *Handler) changeFailedJob(w http.ResponseWriter, r *http.Request, change func(context.Context, int64) error, event, message string) {
+	logger := GetLoggerWithRequestID(r)
+
+	jobID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	// ErrNotFound also when the job exists but isn't failed (anymore): another admin was quicker
+	err = change(r.Context(), jobID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "Failed job not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to update failed job", "error", err, "job_id", jobID, "event", event)
+		errorResponse(w, http.StatusInternalServerError, "Failed to update job")
+		return
+	}
+
+	// Audit trail: a replay can send mails, a discard loses them
+	adminID, _ := userIDFromContext(r)
+	logger.Warn("Admin action", "event", event, "job_id", jobID, "admin_id", adminID)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": message,
+		"job_id":  jobID,
+	})
+}
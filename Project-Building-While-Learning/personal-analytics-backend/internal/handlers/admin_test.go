@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFailedJobMethods(t *testing.T) {
+	h := newTestHandler()
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		want    int
+	}{
+		{"get", h.AdminGetFailedJob, http.MethodGet, http.StatusBadRequest}, // past the guard, "x" isn't an id
+		{"get with DELETE", h.AdminGetFailedJob, http.MethodDelete, http.StatusMethodNotAllowed},
+		{"replay with GET", h.AdminReplayFailedJob, http.MethodGet, http.StatusMethodNotAllowed},
+		{"discard", h.AdminDiscardFailedJob, http.MethodDelete, http.StatusBadRequest},
+		{"discard with GET", h.AdminDiscardFailedJob, http.MethodGet, http.StatusMethodNotAllowed},
+		{"discard with POST", h.AdminDiscardFailedJob, http.MethodPost, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest(tt.method, "/admin/jobs/failed/x", "", 1)
+			r.SetPathValue("id", "x")
+			if code := serve(t, tt.handler, r, nil); code != tt.want {
+				t.Errorf("status %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"personal-analytics-backend/internal/db"
+	"time"
+)
+
+/*
+Dates in from/to and in the buckets are days in the USER's time zone
+(PATCH /me, profile.go): "2026-01-31" runs from midnight to midnight there,
+and weeks start on their week_start day. The handlers turn those days into
+UTC instants for the database with localMidnight.
+*/
+
+// dateLayout is the format used for from/to query params ("2026-01-31")
+const dateLayout = "2006-01-02"
+
+// defaultRanges is how far back we look when ?from= is not given
+// Picked so each bucket size returns a useful number of points
+var defaultRanges = map[string]time.Duration{
+	"day":   30 * 24 * time.Hour,     // last 30 days
+	"week":  12 * 7 * 24 * time.Hour, // last 12 weeks
+	"month": 365 * 24 * time.Hour,    // last 12 months
+}
+
+// GetMoodAnalytics handles GET /analytics/mood
+// Returns mood average/min/max and entry counts per bucket for the authenticated user
+// Query params:
+//   - bucket: day | week | month (default: day)
+//   - from, to: YYYY-MM-DD, both inclusive (default: range ending today)
+func (h *Handler) GetMoodAnalytics(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	cal, err := h.userCalendar(r.Context(), userID)
+	if err != nil {
+		logger.Error("Failed to load user calendar", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load mood analytics")
+		return
+	}
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	if !db.IsValidBucket(bucket) {
+		errorResponse(w, http.StatusBadRequest, "bucket must be one of: day, week, month")
+		return
+	}
+
+	from, to, err := parseDateRange(r, defaultRanges[bucket], cal.Location)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// DB range is [from, to) so the next midnight makes "to" inclusive for the client
+	fromAt := localMidnight(from, cal.Location)
+	toExclusive := localMidnight(to.AddDate(0, 0, 1), cal.Location)
+
+	buckets, err := h.entries.MoodTrend(r.Context(), userID, fromAt, toExclusive, bucket, cal)
+	if err != nil {
+		logger.Error("Failed to load mood trend", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load mood analytics")
+		return
+	}
+
+	summary, err := h.entries.MoodSummary(r.Context(), userID, fromAt, toExclusive)
+	if err != nil {
+		logger.Error("Failed to load mood summary", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load mood analytics")
+		return
+	}
+
+	logger.Info("Mood analytics returned", "user_id", userID, "bucket", bucket, "buckets", len(buckets))
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"bucket":   bucket,
+		"from":     from.Format(dateLayout),
+		"to":       to.Format(dateLayout),
+		"timezone": cal.Location.String(),
+		"summary":  summary,
+		"buckets":  buckets,
+	})
+}
+
+// GetCategoryAnalytics handles GET /analytics/categories
+// Returns per-category count, average mood, standard deviation and the
+// difference from the user's overall average ("exercise days average +1.8 mood")
+// Query params:
+//   - from, to: YYYY-MM-DD, both inclusive and optional (default: all time)
+func (h *Handler) GetCategoryAnalytics(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	cal, err := h.userCalendar(r.Context(), userID)
+	if err != nil {
+		logger.Error("Failed to load user calendar", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load category analytics")
+		return
+	}
+
+	// Unlike the mood trend, a missing bound here means "no bound" (all time): the zero time
+	var fromAt, toExclusive time.Time
+	if s := r.URL.Query().Get("from"); s != "" {
+		from, err := time.Parse(dateLayout, s)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "from must be a date in YYYY-MM-DD format")
+			return
+		}
+		fromAt = localMidnight(from, cal.Location)
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		to, err := time.Parse(dateLayout, s)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "to must be a date in YYYY-MM-DD format")
+			return
+		}
+		toExclusive = localMidnight(to.AddDate(0, 0, 1), cal.Location)
+	}
+	if !fromAt.IsZero() && !toExclusive.IsZero() && !fromAt.Before(toExclusive) {
+		errorResponse(w, http.StatusBadRequest, "from must be on or before to")
+		return
+	}
+
+	report, err := h.entries.CategoryBreakdown(r.Context(), userID, fromAt, toExclusive)
+	if err != nil {
+		logger.Error("Failed to load category breakdown", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load category analytics")
+		return
+	}
+
+	logger.Info("Category analytics returned", "user_id", userID, "categories", len(report.Categories))
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"timezone": cal.Location.String(),
+		"report":   report,
+	})
+}
+
+// userCalendar loads the user's time zone and week start (one extra lookup per analytics request)
+func (h *Handler) userCalendar(ctx context.Context, userID int64) (db.Calendar, error) {
+	user, err := h.users.GetByID(ctx, userID)
+	if err != nil {
+		return db.Calendar{}, err
+	}
+	return calendarFor(user), nil
+}
+
+// localMidnight is the instant day (a date from dateLayout) starts in loc
+func localMidnight(day time.Time, loc *time.Location) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+}
+
+// parseDateRange reads ?from= and ?to= (YYYY-MM-DD) as plain dates
+// Missing "to" means today in loc, missing "from" means "to" minus defaultRange
+func parseDateRange(r *http.Request, defaultRange time.Duration, loc *time.Location) (time.Time, time.Time, error) {
+	y, m, d := time.Now().In(loc).Date()
+	to := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.Parse(dateLayout, toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must be a date in YYYY-MM-DD format")
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultRange)
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.Parse(dateLayout, fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must be a date in YYYY-MM-DD format")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be on or before to")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
+)
+
+/*
+=== PERSONAL API KEYS ===
+
+For scripts and integrations that can't do the /login → refresh dance:
+
+  POST   /apikeys         {"name": "phone shortcut", "scopes": ["entries:write"]}
+                          → the key, ONCE: "pak_Xk9b2Qm1rT7vN0cE..."
+  GET    /apikeys         name, prefix, scopes, last used - never the key
+  DELETE /apikeys/{id}    revoke
+
+  curl -H "Authorization: ApiKey pak_Xk9b2Qm1rT7vN0cE..." /entries
+
+AuthMiddleware accepts "ApiKey <key>" next to "Bearer <jwt>". Both end up as
+user_id in the request context; a key ALSO puts its scopes there.
+
+=== SCOPES ===
+
+RequireScope("entries") sits behind AuthMiddleware on the routes a key may use:
+
+  GET / HEAD      → needs "entries:read"
+  anything else   → needs "entries:write"
+
+A JWT (the user logged in themselves) passes every RequireScope. On a route
+WITHOUT RequireScope a key works like a JWT, so routes that must never be
+reached with a key say so with SessionOnly (managing keys, logging out
+everywhere, exporting or deleting the account). Admin routes are closed
+anyway: a key never carries the admin role.
+
+The "pak_" prefix (personal API key) makes leaked keys easy to grep for in
+logs and repositories, the way GitHub's "ghp_" tokens are.
+*/
+
+// API key scopes a user can grant
+const (
+	ScopeEntriesRead   = "entries:read"
+	ScopeEntriesWrite  = "entries:write"
+	ScopeAnalyticsRead = "analytics:read"
+)
+
+// apiKeyScopes are all valid scopes, in the order they are listed in errors
+var apiKeyScopes = []string{ScopeEntriesRead, ScopeEntriesWrite, ScopeAnalyticsRead}
+
+const (
+	apiKeyPrefix        = "pak_"      // start of every key
+	apiKeyShownPrefix   = 12          // characters of the key kept as models.APIKey.Prefix
+	apiKeyNameMaxLength = 100         // keep names labels, not essays
+	apiKeyTouchInterval = time.Minute // last_used_at is written at most this often per key
+)
+
+// CreateAPIKeyRequest is the body of POST /apikeys
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// authenticateAPIKey checks a key from "Authorization: ApiKey <key>" and records its use
+func (h *Handler) authenticateAPIKey(r *http.Request, key string) (models.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return models.APIKey{}, db.ErrTokenInvalid
+	}
+
+	apiKey, err := h.apiKeys.Authenticate(r.Context(), hashOpaqueToken(key))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	// A key can make thousands of requests a minute; one write per minute is enough for "last used"
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		if err := h.apiKeys.Touch(r.Context(), apiKey.ID, now); err != nil {
+			// Not worth failing the request over
+			GetLoggerWithRequestID(r).Warn("Failed to record API key use", "error", err, "api_key_id", apiKey.ID)
+		}
+	}
+	return apiKey, nil
+}
+
+// RequireScope lets JWT requests through and API key requests only if the key has
+// resource:read (GET, HEAD) or resource:write (other methods)
+func RequireScope(resource string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			scopes, isAPIKey := apiKeyScopesFromContext(r)
+			if !isAPIKey {
+				next(w, r)
+				return
+			}
+
+			needed := resource + ":write"
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				needed = resource + ":read"
+			}
+			if !slices.Contains(scopes, needed) {
+				GetLoggerWithRequestID(r).Warn("API key scope missing", "needed", needed, "scopes", scopes, "path", r.URL.Path)
+				errorResponseAuth(w, http.StatusForbidden, "API key lacks scope "+needed)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// SessionOnly rejects API keys: only a user who logged in (JWT) may do this.
+// A leaked key must not be able to create more keys or end the user's sessions.
+func SessionOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, isAPIKey := apiKeyScopesFromContext(r); isAPIKey {
+			errorResponseAuth(w, http.StatusForbidden, "Not allowed with an API key, log in instead")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// apiKeyScopesFromContext returns the scopes of the API key that authenticated the
+// request; isAPIKey is false for JWT requests
+func apiKeyScopesFromContext(r *http.Request) (scopes []string, isAPIKey bool) {
+	scopes, isAPIKey = r.Context().Value("api_key_scopes").([]string)
+	return scopes, isAPIKey
+}
+
+// CreateAPIKey handles POST /apikeys
+// The key is in this response and nowhere else - we only keep its hash
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponseAuth(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponseAuth(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		errorResponseAuth(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if len(req.Name) > apiKeyNameMaxLength {
+		errorResponseAuth(w, http.StatusBadRequest, "name must be at most "+strconv.Itoa(apiKeyNameMaxLength)+" characters")
+		return
+	}
+
+	// At least one scope, only known ones, each once (sorted, so the list is stable)
+	if len(req.Scopes) == 0 {
+		errorResponseAuth(w, http.StatusBadRequest, "scopes is required, valid: "+strings.Join(apiKeyScopes, ", "))
+		return
+	}
+	for _, s := range req.Scopes {
+		if !slices.Contains(apiKeyScopes, s) {
+			errorResponseAuth(w, http.StatusBadRequest, "unknown scope "+strconv.Quote(s)+", valid: "+strings.Join(apiKeyScopes, ", "))
+			return
+		}
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+
+	// Same randomness and hashing as refresh tokens (tokens.go); the hash covers the
+	// whole key, prefix included
+	secret, _, err := newOpaqueToken()
+	if err != nil {
+		logger.Error("Error generating API key", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+	key := apiKeyPrefix + secret
+
+	apiKey, err := h.apiKeys.Create(r.Context(), userID, req.Name, key[:apiKeyShownPrefix], hashOpaqueToken(key), req.Scopes)
+	if err != nil {
+		logger.Error("Error saving API key", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	logger.Info("API key created", "user_id", userID, "api_key_id", apiKey.ID, "scopes", apiKey.Scopes)
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"message": "Store this key now, it won't be shown again",
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+// ListAPIKeys handles GET /apikeys
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponseAuth(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	keys, err := h.apiKeys.List(r.Context(), userID)
+	if err != nil {
+		logger.Error("Error listing API keys", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"api_keys": keys,
+	})
+}
+
+// RevokeAPIKey handles DELETE /apikeys/{id}
+// The key stops working at once; it stays in the list with revoked_at
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keyID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorResponseAuth(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponseAuth(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err = h.apiKeys.Revoke(r.Context(), userID, keyID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponseAuth(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Error revoking API key", "error", err, "api_key_id", keyID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	logger.Info("API key revoked", "user_id", userID, "api_key_id", keyID)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "API key revoked",
+	})
+}
@@ -2,15 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"os"
+	netmail "net/mail"
 	"strings"
-	"time"
+	"sync"
 
 	"personal-analytics-backend/internal/db"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -33,15 +33,17 @@ type RegisterResponse struct {
 	UserID  int64  `json:"user_id,omitempty"`
 }
 
-// LoginResponse represents the login response
+// LoginResponse represents the login (and token refresh) response
 type LoginResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Token   string `json:"token"`
+	Success      bool   `json:"success"`
+	Message      string `json:"message"`
+	Token        string `json:"token"`                   // access token (JWT) for Authorization: Bearer
+	RefreshToken string `json:"refresh_token,omitempty"` // exchange at POST /token/refresh
+	ExpiresIn    int    `json:"expires_in,omitempty"`    // access token lifetime in seconds
 }
 
 // Register handles POST /register
-func Register(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Request received", "method", "POST", "path", "/register")
 
 	// Only allow POST method
@@ -68,13 +70,16 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate email
-	if req.Email == "" {
+	// Stored normalized, so Login and ForgotPassword find it however it is typed
+	email := normalizeEmail(req.Email)
+	if email == "" {
 		errorResponseAuth(w, http.StatusBadRequest, "email is required")
 		return
 	}
 
-	// Basic email validation (contains @)
-	if !strings.Contains(req.Email, "@") {
+	// Email validation: must parse as ONE bare address (RFC 5322)
+	// "a@b" parses too, but "Name <a@b>", "a@b, c@d" or "not an email" don't match email
+	if addr, err := netmail.ParseAddress(email); err != nil || addr.Address != email {
 		errorResponseAuth(w, http.StatusBadRequest, "invalid email format")
 		return
 	}
@@ -101,10 +106,10 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save user to database
-	userID, err := db.CreateUser(req.Email, string(passwordHash))
+	userID, err := h.users.Create(r.Context(), email, string(passwordHash))
 	if err != nil {
-		// Check if email already exists (SQLite UNIQUE constraint violation)
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		// Email already exists (UNIQUE constraint violation, translated by the repository)
+		if errors.Is(err, db.ErrEmailTaken) {
 			errorResponseAuth(w, http.StatusConflict, "Email already registered")
 			return
 		}
@@ -114,6 +119,11 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Verification mail: if it fails the account still exists - log it (a password reset also verifies the email)
+	if err := h.sendVerificationEmail(r.Context(), userID, email); err != nil {
+		slog.Error("Error sending verification email", "error", err, "user_id", userID)
+	}
+
 	// Success response
 	slog.Info("User registered", "user_id", userID)
 	respondJSON(w, http.StatusCreated, RegisterResponse{
@@ -124,7 +134,7 @@ func Register(w http.ResponseWriter, r *http.Request) {
 }
 
 // Login handles POST /login
-func Login(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Request received", "method", "POST", "path", "/login")
 
 	// Only allow POST method
@@ -142,7 +152,9 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate email and password are not empty
-	if req.Email == "" {
+	// One normalized email for both the lockout counter and the lookup
+	email, ip := normalizeEmail(req.Email), clientIP(r)
+	if email == "" {
 		errorResponseAuth(w, http.StatusBadRequest, "email is required")
 		return
 	}
@@ -152,14 +164,29 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Brute-force protection (lockout.go): locked email or IP = 429, even with the right password
+	if wait := loginLockedFor(email, ip); wait > 0 {
+		loginAudit("login_blocked", email, ip, "retry_after_seconds", int(wait.Seconds()))
+		respondLoginLocked(w, wait)
+		return
+	}
+
 	// Get user from database
-	userID, passwordHash, err := db.GetUserByEmail(req.Email)
-	if err != nil {
+	user, err := h.users.GetByEmail(r.Context(), email)
+	if errors.Is(err, db.ErrNotFound) {
 		// Don't reveal if user exists or not (security best practice)
-		slog.Warn("Login attempt for non-existent user", "email", req.Email)
-		errorResponseAuth(w, http.StatusUnauthorized, "Invalid email or password")
+		// Compare against a dummy hash anyway: answering without bcrypt's ~50ms
+		// would tell the attacker by TIMING that the email doesn't exist
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(req.Password))
+		loginFailed(w, email, ip, "unknown_email")
+		return
+	}
+	if err != nil {
+		slog.Error("Error loading user", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to log in")
 		return
 	}
+	userID, passwordHash := user.ID, user.PasswordHash
 
 	// Compare password with stored hash
 	// bcrypt.CompareHashAndPassword: Checks if plain password matches the encrypted hash
@@ -169,59 +196,65 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password))
 	if err != nil {
 		// Password doesn't match
-		slog.Warn("Invalid password attempt", "user_id", userID)
-		errorResponseAuth(w, http.StatusUnauthorized, "Invalid email or password")
+		loginFailed(w, email, ip, "wrong_password", "user_id", userID)
 		return
 	}
 
-	// Generate JWT token
-	// JWT token = "ticket" proving user logged in (contains user_id)
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		slog.Error("JWT_SECRET not set in environment")
-		errorResponseAuth(w, http.StatusInternalServerError, "Server configuration error")
-		return
-	}
+	// Right password: the account's failed attempts start from zero again
+	clearLoginFailures(email)
 
-	// STEP 1: Create claims (data to put inside token)
-	// jwt.MapClaims: Just a map[string]interface{} - you already know this!
-	// "user_id": userID - So we know WHO this token belongs to
-	// "exp": Expiration time - Token becomes invalid after 24 hours
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(), // Unix() converts time to NUMBER
-		// Why Unix()? JWT needs simple numbers, not complex Go time objects
-		// Example: time.Now().Unix() = 1736359530 (just a number)
-		// Add 24 hours = add 86400 seconds (24 * 60 * 60 = 86400)
+	// Disabled by an admin: only said AFTER the password matched, so it reveals nothing to a guesser
+	if user.DisabledAt != nil {
+		slog.Warn("Login attempt on disabled account", "user_id", userID)
+		errorResponseAuth(w, http.StatusForbidden, "Account disabled")
+		return
 	}
 
-	// STEP 2: Create token object (NOT a string yet, just a Go object)
-	// jwt.SigningMethodHS256: Encryption method (standard, secure, don't worry about it)
-	// claims: The data map we just created above
-	// Think: Creating an unsigned document with your data inside
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// STEP 3: Sign token and convert to string (this makes it official!)
-	// This is like putting a wax seal on a letter - proves it's authentic
-	// []byte(secret): Your secret key (converts string to bytes - library requirement)
-	// Returns: "eyJhbGci...xyz" (the actual JWT string user receives)
-	// Format: header.payload.signature (3 parts separated by dots)
-	// "The secret key is a string, but encryption libraries need bytes (raw data format), so we convert it with []byte(secret). This lets the library encrypt and sign the token."
-
-	tokenString, err := token.SignedString([]byte(secret))
+	// Generate access token + refresh token (see tokens.go)
+	// Every login starts a new refresh token family = one session per device
+	session, err := h.newSession(r.Context(), user)
 	if err != nil {
-		slog.Error("Error generating JWT token", "error", err)
+		slog.Error("Error generating tokens", "error", err)
 		errorResponseAuth(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	// Success response with token
+	// Success response with tokens
 	slog.Info("User logged in", "user_id", userID)
-	respondJSON(w, http.StatusOK, LoginResponse{
-		Success: true,
-		Message: "Login successful",
-		Token:   tokenString,
+	session.Success = true
+	session.Message = "Login successful"
+	respondJSON(w, http.StatusOK, session)
+}
+
+// normalizeEmail is how emails are stored and looked up: "A@B.c " and "a@b.c" are one account
+// (and share one lockout counter)
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginFailed counts a failed login and sends the one 401 used for every kind of failure
+func loginFailed(w http.ResponseWriter, email string, ip string, reason string, args ...any) {
+	failures, lockedFor := recordLoginFailure(email, ip)
+	loginAudit("login_failed", email, ip, append([]any{"reason", reason, "failures", failures}, args...)...)
+	if lockedFor > 0 {
+		loginAudit("login_locked", email, ip, "failures", failures, "locked_seconds", int(lockedFor.Seconds()))
+	}
+
+	// Same status and message for unknown email and wrong password
+	errorResponseAuth(w, http.StatusUnauthorized, "Invalid email or password")
+}
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// dummyPasswordHash is a bcrypt hash of nothing in particular, computed on first use
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password for timing"), bcrypt.DefaultCost)
 	})
+	return dummyHash
 }
 
 // errorResponseAuth sends error response for auth endpoints
@@ -279,10 +312,12 @@ WHY JWT_SECRET IS CRITICAL:
 It's not related to passwords. It's the stamp that proves tokens came from your
 server. With the secret, an attacker forges valid tokens for ANY user_id with no
 password, no DB, no login needed. Server cannot distinguish forged from real.
-Store only in env var, never in code or logs. If leaked → rotate immediately.
+Store only in env var, never in code or logs. If leaked → rotate immediately
+(JWT_KEYS: new key first, old one retired - see internal/jwtkeys).
 
 TRADE-OFFS:
-- JWT can't be invalidated before expiry (logout doesn't truly invalidate token)
-- Production fix: token blocklist in Redis, or short expiry + refresh tokens
+- JWT can't be invalidated before expiry, so access tokens now live only 15 minutes
+- Logout revokes the refresh token family (tokens.go): no NEW access tokens,
+  but the current one works until it expires
 - Stateless = scales easily (no session DB needed), but revocation is hard
 */
@@ -2,20 +2,23 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"personal-analytics-backend/internal/cache"
 	"personal-analytics-backend/internal/db"
 	"personal-analytics-backend/internal/worker"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // CreateEntryRequest represents the incoming request body
 type CreateEntryRequest struct {
-	Text     string `json:"text"`
-	Mood     int    `json:"mood"`
-	Category string `json:"category"`
+	Text     string   `json:"text"`
+	Mood     int      `json:"mood"`
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"` // optional, normalized (trimmed, lowercased, de-duplicated)
 }
 
 // The json:"..." tags:
@@ -46,7 +49,7 @@ func errorResponse(w http.ResponseWriter, status int, message string) {
 }
 
 // CreateEntry handles POST /entries
-func CreateEntry(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateEntry(w http.ResponseWriter, r *http.Request) {
 	logger := GetLoggerWithRequestID(r)
 	logger.Info("Request received", "method", "POST", "path", "/entries")
 
@@ -105,22 +108,29 @@ func CreateEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert into database
-	id, err := db.InsertEntry(int(userID), req.Text, req.Mood, req.Category)
+	tags, err := validateTags(req.Tags)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, "Failed to save entry")
+		errorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// reason to delete as if existing data is there its now stale as we created newly this is called the cache invalidaton.
-	cache.Delete(fmt.Sprintf("count:user:%d", userID))
+	// Insert into database (the repository also invalidates the cached counts)
+	id, err := h.entries.Create(r.Context(), userID, db.EntryInput{
+		Text:     req.Text,
+		Mood:     req.Mood,
+		Category: req.Category,
+		Tags:     tags,
+	})
+	if err != nil {
+		logger.Error("Failed to save entry", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to save entry")
+		return
+	}
 
 	// Add background job to process this entry (async)
 	// This returns immediately - worker processes it in background
-	worker.AddJob("entry_created", map[string]interface{}{
-		"entry_id": id,
-		"user_id":  userID,
-	})
+	// (an error is logged by AddJob; the entry is saved either way)
+	worker.AddJob(JobEntryCreated, EntryCreatedJob{EntryID: id, UserID: userID})
 
 	// All above are checks if passed then only allow to save it
 	// Success response
@@ -135,7 +145,9 @@ func CreateEntry(w http.ResponseWriter, r *http.Request) {
 // GetEntries handles GET /entries
 // Returns entries for the authenticated user only
 // Supports pagination: ?page=1&limit=10
-func GetEntries(w http.ResponseWriter, r *http.Request) {
+// Supports filters: ?category=work&mood_min=5&mood_max=8&from=2026-01-01&to=2026-01-31&q=gym&tags=gym,happy
+// Supports cursor pagination: ?cursor=&limit=10, then ?cursor=<next_cursor>
+func (h *Handler) GetEntries(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Request received", "method", "GET", "path", "/entries")
 
 	// Only allow GET method
@@ -184,15 +196,41 @@ func GetEntries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Debug("Fetching entries", "user_id", userID, "page", page, "limit", limit)
+	// from/to are days of the user's calendar: only then is the time zone worth a lookup
+	loc := time.UTC
+	if r.URL.Query().Get("from") != "" || r.URL.Query().Get("to") != "" {
+		cal, err := h.userCalendar(r.Context(), userID)
+		if err != nil {
+			slog.Error("Failed to load user calendar", "error", err, "user_id", userID)
+			errorResponse(w, http.StatusInternalServerError, "Failed to fetch entries")
+			return
+		}
+		loc = cal.Location
+	}
 
-	// Get entries for this user only
-	// Note: int(userID) converts int64 to int to match function signature
-	entries, total := db.GetEntriesByUserPaginated(int(userID), page, limit)
+	// Parse optional filters (?category=&mood_min=&mood_max=&from=&to=&q=)
+	// Unlike page/limit, a bad filter is a 400: silently ignoring it would return the wrong entries
+	filter, err := parseEntryFilter(r, loc)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// Handle empty case - return empty array instead of null
-	if entries == nil {
-		entries = []map[string]interface{}{}
+	// Cursor mode: ?cursor= (empty on the first request) switches from page/limit
+	// to keyset pagination, which doesn't drift when entries are added between fetches
+	if r.URL.Query().Has("cursor") {
+		h.getEntriesByCursor(w, r, userID, r.URL.Query().Get("cursor"), limit, filter)
+		return
+	}
+
+	slog.Debug("Fetching entries", "user_id", userID, "page", page, "limit", limit, "filter", filter)
+
+	// Get entries for this user only
+	entries, total, err := h.entries.List(r.Context(), userID, filter, page, limit)
+	if err != nil {
+		slog.Error("Failed to fetch entries", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to fetch entries")
+		return
 	}
 
 	// Calculate total pages using integer ceiling division
@@ -212,6 +250,137 @@ func GetEntries(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// getEntriesByCursor writes one page of GET /entries in cursor mode
+// Response has next_cursor instead of page/total/totalPages (null = no more entries)
+func (h *Handler) getEntriesByCursor(w http.ResponseWriter, r *http.Request, userID int64, cursor string, limit int, filter db.EntryFilter) {
+	slog.Debug("Fetching entries by cursor", "user_id", userID, "limit", limit, "filter", filter)
+
+	entries, nextCursor, err := h.entries.ListAfter(r.Context(), userID, filter, cursor, limit)
+	if errors.Is(err, db.ErrInvalidCursor) {
+		errorResponse(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to fetch entries by cursor", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to fetch entries")
+		return
+	}
+
+	// nil encodes as JSON null - clients loop "while next_cursor != null"
+	var next interface{}
+	if nextCursor != "" {
+		next = nextCursor
+	}
+
+	slog.Info("Entries returned", "count", len(entries), "user_id", userID, "has_more", next != nil)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"entries":     entries,
+		"limit":       limit,
+		"next_cursor": next,
+	})
+}
+
+// maxQueryLength caps the free-text ?q= filter (and GET /entries/search)
+const maxQueryLength = 200
+
+// parseEntryFilter reads the optional GET /entries filters from the query string
+// Supported: category, mood_min, mood_max (1-10), from, to (YYYY-MM-DD, inclusive), q,
+// tags (comma-separated, entry must have all of them).
+// from/to are days in loc (the user's time zone, like the analytics ranges): "from"
+// starts at its local midnight, "to" ends at the next one - not at UTC midnight.
+func parseEntryFilter(r *http.Request, loc *time.Location) (db.EntryFilter, error) {
+	query := r.URL.Query()
+	var filter db.EntryFilter
+
+	filter.Category = strings.TrimSpace(query.Get("category"))
+
+	if s := query.Get("mood_min"); s != "" {
+		m, err := strconv.Atoi(s)
+		if err != nil || m < 1 || m > 10 {
+			return db.EntryFilter{}, fmt.Errorf("mood_min must be between 1 and 10")
+		}
+		filter.MoodMin = m
+	}
+
+	if s := query.Get("mood_max"); s != "" {
+		m, err := strconv.Atoi(s)
+		if err != nil || m < 1 || m > 10 {
+			return db.EntryFilter{}, fmt.Errorf("mood_max must be between 1 and 10")
+		}
+		filter.MoodMax = m
+	}
+
+	if filter.MoodMin > 0 && filter.MoodMax > 0 && filter.MoodMin > filter.MoodMax {
+		return db.EntryFilter{}, fmt.Errorf("mood_min must be less than or equal to mood_max")
+	}
+
+	if s := query.Get("from"); s != "" {
+		from, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return db.EntryFilter{}, fmt.Errorf("from must be a date in YYYY-MM-DD format")
+		}
+		filter.From = localMidnight(from, loc)
+	}
+
+	if s := query.Get("to"); s != "" {
+		to, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return db.EntryFilter{}, fmt.Errorf("to must be a date in YYYY-MM-DD format")
+		}
+		// "to" is inclusive for the client, the DB filter is exclusive
+		filter.To = localMidnight(to.AddDate(0, 0, 1), loc)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return db.EntryFilter{}, fmt.Errorf("from must be on or before to")
+	}
+
+	filter.Query = strings.TrimSpace(query.Get("q"))
+	if len(filter.Query) > maxQueryLength {
+		return db.EntryFilter{}, fmt.Errorf("q must be at most %d characters", maxQueryLength)
+	}
+	if filter.Query != "" && !db.HasSearchTerms(filter.Query) {
+		return db.EntryFilter{}, fmt.Errorf("q must contain at least one letter or digit")
+	}
+
+	if s := query.Get("tags"); s != "" {
+		tags, err := validateTags(strings.Split(s, ","))
+		if err != nil {
+			return db.EntryFilter{}, err
+		}
+		filter.Tags = tags
+	}
+
+	return filter, nil
+}
+
+// Tag limits - keep tags short labels, not sentences
+const (
+	maxTagsPerEntry = 10
+	maxTagLength    = 32
+)
+
+// validateTags normalizes tags (see db.NormalizeTags) and checks the limits
+// nil stays nil so UpdateEntry can tell "tags not sent" from "tags: []"
+func validateTags(raw []string) ([]string, error) {
+	tags := db.NormalizeTags(raw)
+
+	if len(tags) > maxTagsPerEntry {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxTagsPerEntry)
+	}
+	for _, t := range tags {
+		if len(t) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", t, maxTagLength)
+		}
+		// Commas separate tags in ?tags=a,b so they can't be part of a name
+		if strings.Contains(t, ",") {
+			return nil, fmt.Errorf("tag %q must not contain a comma", t)
+		}
+	}
+	return tags, nil
+}
+
 // Helper function to send JSON responses
 // "Sends back a JSON response with a specific status code."
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -223,7 +392,7 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-func UpdateEntry(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateEntry(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != http.MethodPatch {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -272,20 +441,31 @@ func UpdateEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Call database to update entry
-	rowsAffected, err := db.UpdateEntry(entryId, userID, req.Text, req.Mood, req.Category)
+	// Omitting "tags" keeps the current tags, "tags": [] removes them
+	tags, err := validateTags(req.Tags)
 	if err != nil {
-		slog.Error("Database error on update", "error", err, "entry_id", entryId)
-		errorResponse(w, http.StatusInternalServerError, "Failed to update entry")
+		errorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// If no rows affected, entry doesn't exist or doesn't belong to user
-	if rowsAffected == 0 {
+	// Call database to update entry
+	err = h.entries.Update(r.Context(), userID, int64(entryId), db.EntryInput{
+		Text:     req.Text,
+		Mood:     req.Mood,
+		Category: req.Category,
+		Tags:     tags,
+	})
+
+	// ErrNotFound: entry doesn't exist or doesn't belong to user
+	if errors.Is(err, db.ErrNotFound) {
 		errorResponse(w, http.StatusNotFound, "Entry not found or access denied")
 		return
 	}
-	cache.Delete(fmt.Sprintf("count:user:%d", userID))
+	if err != nil {
+		slog.Error("Database error on update", "error", err, "entry_id", entryId)
+		errorResponse(w, http.StatusInternalServerError, "Failed to update entry")
+		return
+	}
 
 	// Success response
 	slog.Info("Entry updated", "entry_id", entryId, "user_id", userID)
@@ -295,7 +475,7 @@ func UpdateEntry(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func DeleteEntry(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteEntry(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -323,24 +503,24 @@ func DeleteEntry(w http.ResponseWriter, r *http.Request) {
 
 	slog.Debug("Deleting entry", "entry_id", entryId, "user_id", userID)
 
-	rowsAffected, err := db.DeleteEntry(entryId, userID)
+	err = h.entries.Delete(r.Context(), userID, int64(entryId))
 
-	if err != nil {
-		slog.Error("Database error on delete", "error", err, "entry_id", entryId)
-		errorResponse(w, http.StatusInternalServerError, "Failed to delete entry")
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "Entry not found or access denied")
 		return
 	}
 
-	if rowsAffected == 0 {
-		errorResponse(w, http.StatusNotFound, "Entry not found or access denied")
+	if err != nil {
+		slog.Error("Database error on delete", "error", err, "entry_id", entryId)
+		errorResponse(w, http.StatusInternalServerError, "Failed to delete entry")
 		return
 	}
 
-	cache.Delete(fmt.Sprintf("count:user:%d", userID))
-	slog.Info("Entry deleted", "entry_id", entryId, "user_id", userID)
+	// Soft delete: the entry is in the trash and can be restored (POST /entries/restore?id=)
+	slog.Info("Entry moved to trash", "entry_id", entryId, "user_id", userID)
 	respondJSON(w, http.StatusOK, CreateEntryResponse{
 		Success: true,
-		Message: "Entry deleted successfully",
+		Message: "Entry moved to trash",
 	})
 
 }
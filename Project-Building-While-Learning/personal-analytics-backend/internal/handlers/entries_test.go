@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestHandler builds a Handler on the in-memory fakes: no database, no Redis
+func newTestHandler() *Handler {
+	return New(db.NewMemoryEntryRepository(), db.NewMemoryUserRepository(),
+		db.NewMemoryRefreshTokenRepository(), db.NewMemoryUserTokenRepository(),
+		db.NewMemoryAPIKeyRepository(), nil)
+}
+
+// newSQLTestHandler builds a Handler on the SQL repositories over a fresh SQLite
+// file, for what only they do (cascades, the count cache); conn is for checking rows
+func newSQLTestHandler(t *testing.T) (*Handler, *db.Conn) {
+	t.Helper()
+	conn, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.CloseDB(conn) })
+	return New(db.NewSQLEntryRepository(conn), db.NewSQLUserRepository(conn), db.NewSQLRefreshTokenRepository(conn),
+		db.NewSQLUserTokenRepository(conn), db.NewSQLAPIKeyRepository(conn), conn), conn
+}
+
+// registerTestJobs registers the job types once per test binary (worker.Register
+// panics on a second time), so AddJob accepts them. No pool runs them: tests that
+// care call the job function on their own handler.
+func registerTestJobs(h *Handler) {
+	registerJobsOnce.Do(h.RegisterJobs)
+}
+
+var registerJobsOnce sync.Once
+
+// newRequest builds a request the way AuthMiddleware hands it on: user_id in
+// the context (0 = not logged in)
+func newRequest(method string, target string, body string, userID int64) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if userID != 0 {
+		r = r.WithContext(context.WithValue(r.Context(), "user_id", userID))
+	}
+	return r
+}
+
+// serve runs handler on r and decodes the JSON response into out (if not nil)
+func serve(t *testing.T, handler http.HandlerFunc, r *http.Request, out interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: response is not JSON: %v (%s)", r.Method, r.URL, err, w.Body.String())
+		}
+	}
+	return w.Code
+}
+
+type listResponse struct {
+	Entries []models.Entry `json:"entries"`
+	Total   int            `json:"total"`
+}
+
+// createEntry creates an entry for userID through the handler and returns its id
+func createEntry(t *testing.T, h *Handler, userID int64, body string) int64 {
+	t.Helper()
+	var resp CreateEntryResponse
+	if code := serve(t, h.CreateEntry, newRequest(http.MethodPost, "/entries", body, userID), &resp); code != http.StatusCreated {
+		t.Fatalf("create: status %d, want 201 (%s)", code, resp.Message)
+	}
+	if resp.ID == 0 {
+		t.Fatal("create: no id in the response")
+	}
+	return resp.ID
+}
+
+// listEntries returns GET /entries of userID
+func listEntries(t *testing.T, h *Handler, userID int64) listResponse {
+	t.Helper()
+	var list listResponse
+	if code := serve(t, h.GetEntries, newRequest(http.MethodGet, "/entries", "", userID), &list); code != http.StatusOK {
+		t.Fatalf("list: status %d, want 200", code)
+	}
+	return list
+}
+
+func TestEntriesCRUD(t *testing.T) {
+	h := newTestHandler()
+	const user = 1
+
+	id := createEntry(t, h, user, `{"text":"gym","mood":7,"category":"health","tags":["Gym"]}`)
+	entryURL := "/entries?id=" + strconv.FormatInt(id, 10)
+
+	list := listEntries(t, h, user)
+	if list.Total != 1 || len(list.Entries) != 1 {
+		t.Fatalf("after create: total %d, %d entries, want 1", list.Total, len(list.Entries))
+	}
+	if e := list.Entries[0]; e.ID != id || e.Text != "gym" || e.Mood != 7 || e.Category != "health" {
+		t.Errorf("after create: got %+v", e)
+	}
+
+	body := `{"text":"long run","mood":9,"category":"health"}`
+	if code := serve(t, h.UpdateEntry, newRequest(http.MethodPatch, entryURL, body, user), nil); code != http.StatusOK {
+		t.Fatalf("update: status %d, want 200", code)
+	}
+	list = listEntries(t, h, user)
+	if e := list.Entries[0]; e.Text != "long run" || e.Mood != 9 {
+		t.Errorf("after update: got %+v", e)
+	}
+
+	if code := serve(t, h.DeleteEntry, newRequest(http.MethodDelete, entryURL, "", user), nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d, want 200", code)
+	}
+	if list = listEntries(t, h, user); list.Total != 0 {
+		t.Errorf("after delete: total %d, want 0", list.Total)
+	}
+
+	// Deleted = in the trash, and back with restore
+	var trash listResponse
+	serve(t, h.ListTrash, newRequest(http.MethodGet, "/entries/trash", "", user), &trash)
+	if trash.Total != 1 {
+		t.Errorf("trash: total %d, want 1", trash.Total)
+	}
+	restoreURL := "/entries/restore?id=" + strconv.FormatInt(id, 10)
+	if code := serve(t, h.RestoreEntry, newRequest(http.MethodPost, restoreURL, "", user), nil); code != http.StatusOK {
+		t.Fatalf("restore: status %d, want 200", code)
+	}
+	if list = listEntries(t, h, user); list.Total != 1 {
+		t.Errorf("after restore: total %d, want 1", list.Total)
+	}
+}
+
+func TestEntriesOwnership(t *testing.T) {
+	h := newTestHandler()
+	const owner, other = 1, 2
+
+	id := createEntry(t, h, owner, `{"text":"private","mood":3,"category":"diary"}`)
+	idStr := strconv.FormatInt(id, 10)
+
+	if list := listEntries(t, h, other); list.Total != 0 {
+		t.Errorf("other user lists %d entries, want 0", list.Total)
+	}
+
+	// Someone else's entry looks exactly like a missing one: 404, not 403
+	body := `{"text":"hacked","mood":1,"category":"diary"}`
+	if code := serve(t, h.UpdateEntry, newRequest(http.MethodPatch, "/entries?id="+idStr, body, other), nil); code != http.StatusNotFound {
+		t.Errorf("other user update: status %d, want 404", code)
+	}
+	if code := serve(t, h.DeleteEntry, newRequest(http.MethodDelete, "/entries?id="+idStr, "", other), nil); code != http.StatusNotFound {
+		t.Errorf("other user delete: status %d, want 404", code)
+	}
+	history := newRequest(http.MethodGet, "/entries/"+idStr+"/history", "", other)
+	history.SetPathValue("id", idStr)
+	if code := serve(t, h.GetEntryHistory, history, nil); code != http.StatusNotFound {
+		t.Errorf("other user history: status %d, want 404", code)
+	}
+
+	// Not even from the trash
+	serve(t, h.DeleteEntry, newRequest(http.MethodDelete, "/entries?id="+idStr, "", owner), nil)
+	if code := serve(t, h.RestoreEntry, newRequest(http.MethodPost, "/entries/restore?id="+idStr, "", other), nil); code != http.StatusNotFound {
+		t.Errorf("other user restore: status %d, want 404", code)
+	}
+	serve(t, h.RestoreEntry, newRequest(http.MethodPost, "/entries/restore?id="+idStr, "", owner), nil)
+
+	list := listEntries(t, h, owner)
+	if list.Total != 1 || list.Entries[0].Text != "private" || list.Entries[0].Mood != 3 {
+		t.Errorf("owner's entry changed: %+v", list.Entries)
+	}
+}
+
+func TestCreateEntryValidation(t *testing.T) {
+	h := newTestHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		userID int64
+		want   int
+	}{
+		{"not logged in", http.MethodPost, `{"text":"a","mood":5,"category":"c"}`, 0, http.StatusUnauthorized},
+		{"wrong method", http.MethodPut, `{"text":"a","mood":5,"category":"c"}`, 1, http.StatusMethodNotAllowed},
+		{"broken JSON", http.MethodPost, `{"text":`, 1, http.StatusBadRequest},
+		{"empty text", http.MethodPost, `{"text":"","mood":5,"category":"c"}`, 1, http.StatusBadRequest},
+		{"mood too low", http.MethodPost, `{"text":"a","mood":0,"category":"c"}`, 1, http.StatusBadRequest},
+		{"mood too high", http.MethodPost, `{"text":"a","mood":11,"category":"c"}`, 1, http.StatusBadRequest},
+		{"empty category", http.MethodPost, `{"text":"a","mood":5,"category":""}`, 1, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.CreateEntry(w, newRequest(tt.method, "/entries", tt.body, tt.userID))
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	if list := listEntries(t, h, 1); list.Total != 0 {
+		t.Errorf("rejected requests saved %d entries", list.Total)
+	}
+}
+
+func TestEntriesDateFilterInUserTimezone(t *testing.T) {
+	useFakeRedis(t)
+	ctx := context.Background()
+
+	// created_at (UTC) of three entries; New York is UTC-4 in March after the 8th
+	createdAt := []string{
+		"2026-03-10 03:30:00", // March 9, 23:30 in New York
+		"2026-03-10 12:00:00", // March 10 everywhere
+		"2026-03-11 03:30:00", // March 10, 23:30 in New York
+	}
+	tests := []struct {
+		name     string
+		timezone string
+		want     []int // indexes into createdAt
+	}{
+		{"no time zone set", "", []int{0, 1}}, // UTC days
+		{"New York", "America/New_York", []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, conn := newSQLTestHandler(t)
+			userID, err := h.users.Create(ctx, "tz@example.com", "hash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.users.UpdateProfile(ctx, userID, db.ProfileInput{Timezone: tt.timezone, WeekStart: "monday"}); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int64, len(createdAt))
+			for i, at := range createdAt {
+				ids[i] = createEntry(t, h, userID, `{"text":"walk","mood":6,"category":"health"}`)
+				if _, err := conn.Exec(`UPDATE entries SET created_at = ? WHERE id = ?`, at, ids[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var list listResponse
+			r := newRequest(http.MethodGet, "/entries?from=2026-03-10&to=2026-03-10", "", userID)
+			if code := serve(t, h.GetEntries, r, &list); code != http.StatusOK {
+				t.Fatalf("status %d, want 200", code)
+			}
+			var got []int64
+			for _, e := range list.Entries {
+				got = append(got, e.ID)
+			}
+			var want []int64
+			for _, i := range tt.want {
+				want = append(want, ids[i])
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, want) || list.Total != len(want) {
+				t.Errorf("entries %v (total %d), want %v", got, list.Total, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"personal-analytics-backend/internal/redis"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// fakeRedis records the commands the handlers send and answers like an empty
+// Redis: every read misses, every write succeeds. Only INCR counters are kept,
+// so the lockout sees its failures add up. Enough to check that a key was
+// deleted or a counter incremented without a real server.
+type fakeRedis struct {
+	mu       sync.Mutex
+	commands []string // "DEL count:user:1"
+	counters map[string]int64
+}
+
+// useFakeRedis points redis.Client at a new fakeRedis until the test ends
+func useFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{counters: map[string]int64{}}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+
+	previous := redis.Client
+	// RESP2 without CLIENT SETINFO: nothing but HELLO before the first real command
+	redis.Client = goredis.NewClient(&goredis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() {
+		redis.Client.Close()
+		redis.Client = previous
+		ln.Close()
+	})
+	return f
+}
+
+// sent reports whether command was sent, e.g. "DEL count:user:1"
+func (f *fakeRedis) sent(command string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	var queued [][]string // commands since MULTI, nil = not in a transaction
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		args[0] = strings.ToUpper(args[0])
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		f.mu.Unlock()
+
+		var reply string
+		switch {
+		case args[0] == "MULTI":
+			queued, reply = [][]string{}, "+OK\r\n"
+		case args[0] == "EXEC":
+			reply = "*" + strconv.Itoa(len(queued)) + "\r\n"
+			for _, q := range queued {
+				reply += f.reply(q)
+			}
+			queued = nil
+		case queued != nil:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			reply = f.reply(args)
+		}
+		if _, err := io.WriteString(c, reply); err != nil {
+			return
+		}
+	}
+}
+
+// reply runs one command outside MULTI, or inside it at EXEC
+func (f *fakeRedis) reply(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch args[0] {
+	case "HELLO":
+		return "-ERR unknown command 'HELLO'\r\n" // an old Redis: go-redis goes on with RESP2
+	case "GET", "HGET":
+		return "$-1\r\n"
+	case "MGET":
+		return "*" + strconv.Itoa(len(args)-1) + "\r\n" + strings.Repeat("$-1\r\n", len(args)-1)
+	case "INCR":
+		f.counters[args[1]]++
+		return ":" + strconv.FormatInt(f.counters[args[1]], 10) + "\r\n"
+	case "DEL":
+		for _, key := range args[1:] {
+			delete(f.counters, key)
+		}
+		return ":1\r\n"
+	case "EXPIRE", "HSET":
+		return ":1\r\n"
+	}
+	return "+OK\r\n"
+}
+
+// readCommand reads one RESP array of bulk strings: *2\r\n$3\r\nGET\r\n$1\r\nk\r\n
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("bad bulk header %q", header)
+		}
+		buf := make([]byte, size+2) // + \r\n
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
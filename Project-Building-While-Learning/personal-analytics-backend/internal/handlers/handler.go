@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"personal-analytics-backend/internal/db"
+)
+
+// Pinger is anything that can tell whether the database is reachable (*sql.DB is one)
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Handler holds what the HTTP handlers need to do their work.
+// Built once in main.go and its methods are registered as routes:
+//
+//	h := handlers.New(db.NewSQLEntryRepository(conn), db.NewSQLUserRepository(conn),
+//		db.NewSQLRefreshTokenRepository(conn), db.NewSQLUserTokenRepository(conn),
+//		db.NewSQLAPIKeyRepository(conn), conn)
+//	http.HandleFunc("/tags", ...(h.AuthMiddleware(h.GetTags)))
+//
+// Tests build it with the db.NewMemory...Repository() fakes instead -
+// the handlers only see the interfaces, so they can't tell the difference
+// (see entries_test.go).
+type Handler struct {
+	entries    db.EntryRepository
+	users      db.UserRepository
+	tokens     db.RefreshTokenRepository
+	userTokens db.UserTokenRepository // email verification + password reset links
+	apiKeys    db.APIKeyRepository    // personal API keys, also checked by AuthMiddleware
+	db         Pinger                 // nil when there is no database (in-memory repositories)
+}
+
+// New creates a Handler with its dependencies (constructor injection - no globals)
+func New(entries db.EntryRepository, users db.UserRepository, tokens db.RefreshTokenRepository, userTokens db.UserTokenRepository, apiKeys db.APIKeyRepository, database Pinger) *Handler {
+	return &Handler{
+		entries:    entries,
+		users:      users,
+		tokens:     tokens,
+		userTokens: userTokens,
+		apiKeys:    apiKeys,
+		db:         database,
+	}
+}
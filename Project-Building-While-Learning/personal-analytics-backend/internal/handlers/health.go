@@ -4,18 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"personal-analytics-backend/internal/db"
 	"personal-analytics-backend/internal/redis"
 )
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
 	Status   string `json:"status"`   // "healthy" or "unhealthy"
-	Database string `json:"database"` // "connected" or "disconnected"
+	Database string `json:"database"` // "connected", "disconnected" or "in-memory"
 	Redis    string `json:"redis"`    // "connected" or "disconnected"
 }
 
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	response := HealthResponse{
 		Status:   "healthy",
 		Database: "connected",
@@ -30,8 +29,10 @@ func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check Database: Ping the existing connection
-	err = db.DB.Ping()
-	if err != nil {
+	// (no connection at all when running on the in-memory repositories)
+	if h.db == nil {
+		response.Database = "in-memory"
+	} else if err := h.db.PingContext(r.Context()); err != nil {
 		response.Database = "disconnected"
 		response.Status = "unhealthy"
 	}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"personal-analytics-backend/internal/db"
+	"strconv"
+)
+
+// GetEntryHistory handles GET /entries/{id}/history
+// Returns the earlier versions of an entry, newest first: what it looked like
+// before each edit or delete, when that happened, and the request_id of the
+// request that did it (the same id as in the server logs)
+func (h *Handler) GetEntryHistory(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// {id} in the route pattern (Go 1.22+ ServeMux), e.g. /entries/5/history → "5"
+	entryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid entry ID")
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	revisions, err := h.entries.History(r.Context(), userID, entryID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "Entry not found or access denied")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to load entry history", "error", err, "entry_id", entryID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load entry history")
+		return
+	}
+
+	logger.Info("Entry history returned", "entry_id", entryID, "user_id", userID, "revisions", len(revisions))
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"entry_id":  entryID,
+		"revisions": revisions,
+	})
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"personal-analytics-backend/internal/circuitbreaker"
+	"personal-analytics-backend/internal/mail"
+	"personal-analytics-backend/internal/retry"
+	"personal-analytics-backend/internal/webhook"
+	"personal-analytics-backend/internal/worker"
+)
+
+/*
+=== BACKGROUND JOBS OF THE HTTP HANDLERS ===
+
+The jobs these handlers queue, registered with the worker package
+(see worker/registry.go) by RegisterJobs, which main.go calls before the
+worker pool starts:
+
+  send_email      mail.Message       verification and reset mails (account.go)
+  password_reset  PasswordResetJob   the lookup behind POST /password/forgot (account.go)
+  entry_created   EntryCreatedJob    webhook for a new entry (entries.go)
+  user_deleted    UserDeletedJob     tell the world outside our database (me.go)
+  purge_jobs      PurgeJob           delete old succeeded jobs (scheduled, hourly)
+  purge_trash     PurgeJob           delete entries trashed before the retention
+                                     (scheduled by ScheduleJobs: it needs the repository)
+
+Each entry says how the type may run: mails and webhooks wait on other
+people's servers, so only a couple of them run at once and every attempt has
+a timeout; the rest of the workers stay free for everything else.
+
+The purges used to be goroutines with their own tickers on every server. As
+scheduled jobs (worker/scheduler.go) they run once per interval however many
+servers there are, and a failed purge is retried like any job.
+*/
+
+// Job types queued by the handlers
+const (
+	JobSendEmail     = "send_email"
+	JobPasswordReset = "password_reset"
+	JobEntryCreated  = "entry_created"
+	JobUserDeleted   = "user_deleted"
+	JobPurgeJobs     = "purge_jobs"
+	JobPurgeTrash    = "purge_trash"
+)
+
+// EntryCreatedJob is the payload of entry_created
+type EntryCreatedJob struct {
+	EntryID int64 `json:"entry_id"`
+	UserID  int64 `json:"user_id"`
+}
+
+// UserDeletedJob is the payload of user_deleted
+type UserDeletedJob struct {
+	UserID         int64 `json:"user_id"`
+	EntriesDeleted int64 `json:"entries_deleted"`
+}
+
+// PurgeJob is the payload of the purges: nothing, they delete whatever is old
+// enough when they run
+type PurgeJob struct{}
+
+// entryWebhookURL receives entry_created (a test endpoint for now)
+const entryWebhookURL = "https://webhook.site/11eccba7-a84d-4b58-b86e-68d56a5d7021"
+
+// webhookBreaker stops calling the webhook for a while after 5 failures in a row
+var webhookBreaker = circuitbreaker.NewCircuitBreaker(5, 3*time.Second)
+
+// RegisterJobs registers the job types above with the worker package (password_reset
+// reads h's repositories). Call once, at startup.
+func (h *Handler) RegisterJobs() {
+	worker.Register(JobSendEmail, worker.Options{
+		Concurrency: 2,                // a mail provider rate-limits; the queue absorbs bursts
+		Timeout:     30 * time.Second, // 3 tries of 10s each
+	}, sendEmailJob)
+
+	worker.Register(JobPasswordReset, worker.Options{
+		Timeout: 10 * time.Second, // two queries and an INSERT
+	}, h.passwordResetJob)
+
+	worker.Register(JobEntryCreated, worker.Options{
+		Concurrency: 2,
+		Timeout:     20 * time.Second,
+	}, entryCreatedJob)
+
+	worker.Register(JobUserDeleted, worker.Options{
+		MaxAttempts: 10, // forgetting a user is a promise (GDPR): try for longer before giving up
+	}, userDeletedJob)
+
+	worker.Register(JobPurgeJobs, worker.Options{}, purgeJobsJob)
+}
+
+// ScheduleJobs registers purge_trash and adds the recurring jobs to the
+// scheduler. Call once, at startup, after RegisterJobs.
+func (h *Handler) ScheduleJobs(s *worker.Scheduler, trashRetention time.Duration, trashPurgeInterval time.Duration) error {
+	worker.Register(JobPurgeTrash, worker.Options{}, func(ctx context.Context, job worker.Job, _ PurgeJob) error {
+		purged, err := h.entries.PurgeTrash(ctx, time.Now().Add(-trashRetention))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			slog.Info("Trash purged", "entries", purged)
+		}
+		return nil
+	})
+
+	if err := s.Add(JobPurgeTrash, "@every "+trashPurgeInterval.String(), JobPurgeTrash, PurgeJob{}); err != nil {
+		return err
+	}
+	return s.Add(JobPurgeJobs, "@hourly", JobPurgeJobs, PurgeJob{})
+}
+
+// sendEmailJob sends a verification / password reset mail
+// Sent here so the HTTP response doesn't wait for the mail provider
+func sendEmailJob(ctx context.Context, job worker.Job, msg mail.Message) error {
+	err := retry.Do(3, 500*time.Millisecond, func() error {
+		sendCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		return mail.Default.Send(sendCtx, msg)
+	})
+	if err != nil {
+		// The recipient is logged, never the body: it contains the token
+		slog.Error("Sending email failed", "to", msg.To, "subject", msg.Subject, "job_id", job.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// entryCreatedJob notifies the webhook about a new entry
+// In real app: send email, update stats, notify webhooks, etc.
+func entryCreatedJob(ctx context.Context, job worker.Job, payload EntryCreatedJob) error {
+	slog.Debug("Processing entry creation", "entry_id", payload.EntryID, "user_id", payload.UserID)
+	return webhookBreaker.Execute(func() error {
+		return retry.Do(3, 500*time.Millisecond, func() error {
+			return webhook.Send(ctx, entryWebhookURL, job)
+		})
+	})
+}
+
+// userDeletedJob runs after DELETE /me, which already removed everything in our database.
+// Anything outside it that knows the user (mailing list, analytics, backups)
+// would be told to forget them here.
+func userDeletedJob(ctx context.Context, job worker.Job, payload UserDeletedJob) error {
+	slog.Info("Processing user deletion", "user_id", payload.UserID, "entries_deleted", payload.EntriesDeleted)
+	return nil
+}
+
+// purgeJobsJob deletes old succeeded jobs, which nobody looks at anymore
+func purgeJobsJob(ctx context.Context, job worker.Job, _ PurgeJob) error {
+	purged, err := worker.PurgeSucceeded(ctx)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		slog.Info("Succeeded jobs purged", "count", purged)
+	}
+	return nil
+}
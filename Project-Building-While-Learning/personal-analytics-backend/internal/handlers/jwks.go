@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// JWKSHandler handles GET /.well-known/jwks.json
+// Publishes the public keys (RS256 / EdDSA) that verify our access tokens, so other
+// services can check a token themselves: read its "kid" header, find that key here.
+// HS256 secrets are never published; with only HS256 keys the list is empty.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if SigningKeys == nil {
+		http.Error(w, "Signing keys not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Verifiers may cache it for a while - a NEW key should be listed (as retired or
+	// upcoming) some minutes before tokens signed with it show up
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, http.StatusOK, SigningKeys.JWKS())
+}
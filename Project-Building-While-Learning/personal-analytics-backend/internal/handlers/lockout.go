@@ -0,0 +1,234 @@
+package handlers
+
+/*
+=== LOGIN BRUTE-FORCE PROTECTION (ACCOUNT LOCKOUT) ===
+
+RateLimitMiddleware counts requests per IP. An attacker with 1000 IPs (botnet)
+gets 1000x the limit and can keep guessing ONE account's password.
+
+So failed logins are also counted per EMAIL and per IP:
+
+  loginfail:email:<email>   failed attempts for this account   (forgotten 24h after the last one)
+  loginfail:ip:<ip>         failed attempts from this address   (same)
+  loginlock:<kind>:<id>     "locked until" unix time             TTL = lockout duration
+
+  failures 1..4     nothing happens
+  failure  5        email locked 1 min    (LoginMaxFailures, LoginLockoutBase)
+  failure  6        locked 2 min
+  failure  7        locked 4 min   ... doubling up to LoginLockoutMax (1 hour)
+
+The IP limit is higher (LoginMaxFailuresPerIP): many honest users can share
+one IP behind an office NAT. A successful login clears the email's counter,
+not the IP's - otherwise an attacker could reset it with their own account.
+
+=== NO HINTS FOR THE ATTACKER ===
+
+- "No such user" and "wrong password" both count as a failure and get the SAME 401
+- Unknown emails are locked out exactly like real ones, so a lockout doesn't
+  reveal that an account exists either
+- While locked, even the CORRECT password gets 429 - otherwise the lockout is useless
+
+=== REDIS DOWN ===
+
+Counters live in Redis (shared by all servers). If Redis fails or RedisBreaker is
+open, the same counters are kept in AppCache instead: limits then apply per server,
+which is weaker but still bounded. Failing open (no limit at all while Redis is
+down) would be exactly what an attacker waits for; failing closed (nobody can
+log in) would turn a Redis outage into a full outage.
+*/
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/redis"
+	"strconv"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Lockout configuration, overwritten by main.go from config (same pattern as RateLimitRequests)
+var (
+	LoginMaxFailures      = 5                // failed attempts per email before it is locked
+	LoginMaxFailuresPerIP = 20               // failed attempts per IP before it is locked
+	LoginLockoutBase      = time.Minute      // first lockout, doubled on every further failure
+	LoginLockoutMax       = 60 * time.Minute // lockouts never get longer than this
+)
+
+// loginFailureWindow: a counter is forgotten this long after the last failure
+const loginFailureWindow = 24 * time.Hour
+
+// loginSubject is one thing failures are counted for: an email or an IP
+type loginSubject struct {
+	kind        string // "email" or "ip"
+	id          string
+	maxFailures int
+}
+
+func (s loginSubject) failKey() string { return "loginfail:" + s.kind + ":" + s.id }
+func (s loginSubject) lockKey() string { return "loginlock:" + s.kind + ":" + s.id }
+
+func loginSubjects(email string, ip string) []loginSubject {
+	return []loginSubject{
+		{kind: "email", id: email, maxFailures: LoginMaxFailures},
+		{kind: "ip", id: ip, maxFailures: LoginMaxFailuresPerIP},
+	}
+}
+
+// clientIP is the address failures are counted for (same source as RateLimitMiddleware)
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// loginLockedFor returns how long logins for this email or from this IP stay locked (0 = not locked)
+func loginLockedFor(email string, ip string) time.Duration {
+	subjects := loginSubjects(email, ip)
+	keys := make([]string, len(subjects))
+	for i, s := range subjects {
+		keys[i] = s.lockKey()
+	}
+
+	// Both lock keys in one round-trip; MGET returns nil for a key that doesn't exist
+	var values []interface{}
+	err := cache.RedisBreaker.Execute(func() error {
+		var err error
+		values, err = redis.Client.MGet(context.Background(), keys...).Result()
+		return err
+	})
+
+	// This server's AppCache always counts too: a lock set while Redis was down lives only there
+	var until int64
+	for i, key := range keys {
+		until = max(until, loginMemoryGet(key))
+		if err != nil {
+			continue
+		}
+		if s, ok := values[i].(string); ok {
+			lockedUntil, _ := strconv.ParseInt(s, 10, 64)
+			until = max(until, lockedUntil)
+		}
+	}
+
+	wait := time.Until(time.Unix(until, 0))
+	if wait <= 0 {
+		return 0
+	}
+	return wait
+}
+
+// recordLoginFailure counts a failed login for the email and the IP, locking the ones
+// over their limit. Returns the email's failure count and the longest lockout started.
+func recordLoginFailure(email string, ip string) (emailFailures int64, lockedFor time.Duration) {
+	for _, s := range loginSubjects(email, ip) {
+		failures := incrementCounter(s.failKey(), loginFailureWindow)
+		if s.kind == "email" {
+			emailFailures = failures
+		}
+		if failures < int64(s.maxFailures) {
+			continue
+		}
+
+		lock := lockoutDuration(failures - int64(s.maxFailures))
+		setCounter(s.lockKey(), time.Now().Add(lock).Unix(), lock)
+		lockedFor = max(lockedFor, lock)
+	}
+	return emailFailures, lockedFor
+}
+
+// clearLoginFailures forgets the email's failures after a successful login
+func clearLoginFailures(email string) {
+	s := loginSubjects(email, "")[0]
+
+	loginMemoryMu.Lock()
+	cache.AppCache.Delete(s.failKey())
+	cache.AppCache.Delete(s.lockKey())
+	loginMemoryMu.Unlock()
+
+	cache.RedisBreaker.Execute(func() error {
+		return redis.Client.Del(context.Background(), s.failKey(), s.lockKey()).Err()
+	})
+}
+
+// lockoutDuration is LoginLockoutBase doubled `extra` times, capped at LoginLockoutMax
+func lockoutDuration(extra int64) time.Duration {
+	lock := LoginLockoutBase
+	for i := int64(0); i < extra && lock < LoginLockoutMax; i++ {
+		lock *= 2
+	}
+	return min(lock, LoginLockoutMax)
+}
+
+// incrementCounter adds one to key and (re)starts its TTL; Redis first, AppCache if Redis is down
+func incrementCounter(key string, ttl time.Duration) int64 {
+	var count *goredis.IntCmd
+	err := cache.RedisBreaker.Execute(func() error {
+		ctx := context.Background()
+		// TxPipeline = MULTI/EXEC: INCR and EXPIRE in one round-trip
+		pipe := redis.Client.TxPipeline()
+		count = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err == nil {
+		return count.Val()
+	}
+
+	loginMemoryMu.Lock()
+	defer loginMemoryMu.Unlock()
+	n := loginMemoryGetLocked(key) + 1
+	cache.AppCache.Set(key, n, ttl)
+	return n
+}
+
+// setCounter stores value under key with a TTL; Redis first, AppCache if Redis is down
+func setCounter(key string, value int64, ttl time.Duration) {
+	err := cache.RedisBreaker.Execute(func() error {
+		return redis.Client.Set(context.Background(), key, value, ttl).Err()
+	})
+	if err == nil {
+		return
+	}
+
+	loginMemoryMu.Lock()
+	defer loginMemoryMu.Unlock()
+	cache.AppCache.Set(key, value, ttl)
+}
+
+// loginMemoryMu makes read-increment-write on AppCache atomic (AppCache only locks single calls)
+var loginMemoryMu sync.Mutex
+
+func loginMemoryGet(key string) int64 {
+	loginMemoryMu.Lock()
+	defer loginMemoryMu.Unlock()
+	return loginMemoryGetLocked(key)
+}
+
+func loginMemoryGetLocked(key string) int64 {
+	v, found := cache.AppCache.Get(key)
+	if !found {
+		return 0
+	}
+	n, _ := v.(int64)
+	return n
+}
+
+// respondLoginLocked sends 429 with Retry-After (whole seconds, rounded up)
+func respondLoginLocked(w http.ResponseWriter, wait time.Duration) {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	errorResponseAuth(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+}
+
+// loginAudit logs a security event with the fields every lockout log line shares
+func loginAudit(event string, email string, ip string, args ...any) {
+	slog.Warn("Login security event", append([]any{"event", event, "email", email, "ip", ip}, args...)...)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		extra int64 // failures past the limit
+		want  time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{5, 32 * time.Minute},
+		{6, 60 * time.Minute}, // 64 minutes, capped
+		{7, 60 * time.Minute},
+		{1000, 60 * time.Minute}, // stops doubling at the cap: no overflow
+	}
+	for _, tt := range tests {
+		if got := lockoutDuration(tt.extra); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %s, want %s", tt.extra, got, tt.want)
+		}
+	}
+}
+
+// Failures counted one after the other, like a client guessing passwords
+func TestRecordLoginFailure(t *testing.T) {
+	useFakeRedis(t)
+
+	tests := []struct {
+		failure int64
+		locked  time.Duration // lockout started by this failure
+	}{
+		{1, 0},
+		{4, 0},
+		{5, time.Minute}, // LoginMaxFailures
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{11, 60 * time.Minute},
+		{12, 60 * time.Minute},
+	}
+	count := int64(0)
+	for _, tt := range tests {
+		var failures int64
+		var locked time.Duration
+		for count < tt.failure {
+			failures, locked = recordLoginFailure("guess@example.com", "192.0.2.1")
+			count++
+		}
+		if failures != tt.failure || locked != tt.locked {
+			t.Errorf("failure %d: counted %d, locked %s, want %s", tt.failure, failures, locked, tt.locked)
+		}
+	}
+
+	// The IP's own limit is higher: 12 failures from it lock nothing for another account
+	if failures, locked := recordLoginFailure("other@example.com", "192.0.2.1"); failures != 1 || locked != 0 {
+		t.Errorf("other account from the same IP: counted %d, locked %s, want 1 and none", failures, locked)
+	}
+}
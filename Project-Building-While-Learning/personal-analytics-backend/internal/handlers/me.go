@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
+	"personal-analytics-backend/internal/worker"
+)
+
+/*
+=== THE USER'S OWN ACCOUNT (GDPR) ===
+
+  GET    /me/export   → ZIP: profile.json, entries.json, entries.csv
+  DELETE /me          {"password": "..."} → account and every entry gone for good
+
+Both are SessionOnly in main.go: a script's API key may read entries, but
+downloading everything at once or deleting the account needs a real login.
+
+The password is asked again for DELETE /me even though the request carries a
+valid token: a token left in a shared browser shouldn't be able to erase an
+account. Wrong passwords count towards the login lockout (lockout.go) so the
+endpoint can't be used to guess the password either.
+
+After deleting: refresh tokens and API keys are gone with the account, access
+tokens are put on the revocation list, and a "user_deleted" job tells the
+worker pool - the place for anything outside our database that has to forget
+the user.
+*/
+
+// DeleteAccountRequest is the body of DELETE /me
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// ExportAccount handles GET /me/export
+// The archive is built in memory before anything is sent, so a failing query
+// still gets a proper 500 instead of a cut-off download
+func (h *Handler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), userID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Error loading user for export", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to export data")
+		return
+	}
+
+	entries, err := h.entries.Export(r.Context(), userID)
+	if err != nil {
+		logger.Error("Error loading entries for export", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to export data")
+		return
+	}
+
+	archive, err := buildExportArchive(user, entries, time.Now())
+	if err != nil {
+		logger.Error("Error building export archive", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to export data")
+		return
+	}
+
+	logger.Info("Account data exported", "user_id", userID, "entries", len(entries), "bytes", len(archive))
+
+	filename := "personal-analytics-export-" + time.Now().UTC().Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// buildExportArchive zips the profile and entries: JSON for programs, CSV for spreadsheets
+func buildExportArchive(user models.User, entries []models.Entry, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"profile.json", func(w io.Writer) error { return writeIndentedJSON(w, user) }},
+		{"entries.json", func(w io.Writer) error { return writeIndentedJSON(w, entries) }},
+		{"entries.csv", func(w io.Writer) error { return writeEntriesCSV(w, entries) }},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return nil, err
+		}
+		if err := f.write(fw); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeIndentedJSON writes v as readable JSON - people open these files
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeEntriesCSV writes one row per entry; deleted_at is empty unless the entry is in the trash
+func writeEntriesCSV(w io.Writer, entries []models.Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "created_at", "deleted_at", "mood", "category", "tags", "text"})
+	for _, e := range entries {
+		deletedAt := ""
+		if e.DeletedAt != nil {
+			deletedAt = e.DeletedAt.UTC().Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			deletedAt,
+			strconv.Itoa(e.Mood),
+			e.Category,
+			strings.Join(e.Tags, ","),
+			e.Text,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// DeleteAccount handles DELETE /me
+// Deletes the account and all its data for good - there is no trash for accounts
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponseAuth(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponseAuth(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Password == "" {
+		errorResponseAuth(w, http.StatusBadRequest, "password is required")
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), userID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponseAuth(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Error loading user", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	// Same lockout as /login: a stolen token must not become a password-guessing oracle
+	if !checkCurrentPassword(w, r, user, req.Password, "account_delete") {
+		return
+	}
+
+	// Credentials first, so nothing can use the account while its data is being deleted
+	if err := h.tokens.RevokeUser(r.Context(), userID); err != nil {
+		logger.Error("Error revoking sessions", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	if err := h.apiKeys.RevokeUser(r.Context(), userID); err != nil {
+		logger.Error("Error revoking API keys", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	cache.RevokeUserTokens(userID, time.Now(), AccessTokenTTL)
+
+	// Entries before the account (see db/account.go): a failure here can be retried
+	entriesDeleted, err := h.entries.PurgeUser(r.Context(), userID)
+	if err != nil {
+		logger.Error("Error deleting entries", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	if err := h.users.Delete(r.Context(), userID); err != nil {
+		logger.Error("Error deleting user", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	clearLoginFailures(normalizeEmail(user.Email))
+
+	// Too late to fail the request: the account is gone. The log line holds the
+	// payload, so an admin can queue the job again by hand.
+	if err := worker.AddJob(JobUserDeleted, UserDeletedJob{UserID: userID, EntriesDeleted: entriesDeleted}); err != nil {
+		logger.Error("Failed to queue user_deleted job, replay it by hand", "error", err,
+			"job_type", JobUserDeleted, "user_id", userID, "entries_deleted", entriesDeleted)
+	}
+
+	// Audit trail: the account is gone, this log line is what's left of it
+	logger.Warn("Account deleted", "event", "account_deleted", "user_id", userID, "entries_deleted", entriesDeleted)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":         true,
+		"message":         "Account deleted",
+		"entries_deleted": entriesDeleted,
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/worker"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	fake := useFakeRedis(t)
+	ctx := context.Background()
+
+	// SQL repositories: the count cache and the cascades are theirs
+	h, conn := newSQLTestHandler(t)
+	users, tokens, apiKeys := h.users, h.tokens, h.apiKeys
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret1"), bcrypt.MinCost)
+	userID, err := users.Create(ctx, "delete@example.com", string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	createEntry(t, h, userID, `{"text":"gym","mood":7,"category":"health"}`)
+	createEntry(t, h, userID, `{"text":"run","mood":8,"category":"health"}`)
+	if _, err := apiKeys.Create(ctx, userID, "script", "pak_test1234", "keyhash", []string{ScopeEntriesRead}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Create(ctx, userID, "family", "refreshhash", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// From here on the only job queued is user_deleted
+	jobs := db.NewMemoryJobRepository()
+	previousStore := worker.Store
+	worker.Store = jobs
+	defer func() { worker.Store = previousStore }()
+	registerTestJobs(h)
+
+	// Wrong password: nothing deleted, and it counts like a failed login
+	if code := serve(t, h.DeleteAccount, newRequest(http.MethodDelete, "/me", `{"password":"wrong"}`, userID), nil); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d, want 401", code)
+	}
+	if !fake.sent("INCR loginfail:email:delete@example.com") {
+		t.Error("wrong password was not counted by the lockout")
+	}
+	if _, err := users.GetByID(ctx, userID); err != nil {
+		t.Fatalf("wrong password deleted the account: %v", err)
+	}
+
+	var resp struct {
+		EntriesDeleted int64 `json:"entries_deleted"`
+	}
+	if code := serve(t, h.DeleteAccount, newRequest(http.MethodDelete, "/me", `{"password":"secret1"}`, userID), &resp); code != http.StatusOK {
+		t.Fatalf("delete: status %d, want 200", code)
+	}
+	if resp.EntriesDeleted != 2 {
+		t.Errorf("entries_deleted %d, want 2", resp.EntriesDeleted)
+	}
+
+	if _, err := users.GetByID(ctx, userID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("user still there: %v", err)
+	}
+	var entries int
+	conn.QueryRow(`SELECT COUNT(*) FROM entries WHERE user_id = ?`, userID).Scan(&entries)
+	if entries != 0 {
+		t.Errorf("%d entries left", entries)
+	}
+	if keys, _ := apiKeys.List(ctx, userID); len(keys) != 0 {
+		t.Errorf("%d API keys left", len(keys))
+	}
+	if _, err := tokens.Rotate(ctx, "refreshhash", "newhash", time.Now().Add(time.Hour)); !errors.Is(err, db.ErrTokenInvalid) {
+		t.Errorf("refresh token still works: %v", err)
+	}
+	if !fake.sent("DEL count:user:" + strconv.FormatInt(userID, 10)) {
+		t.Error("entry count cache not invalidated")
+	}
+	job, err := jobs.Claim(ctx, "test", time.Now(), time.Now().Add(time.Minute), nil)
+	if err != nil || job.Type != JobUserDeleted {
+		t.Errorf("queued job %q (%v), want %s", job.Type, err, JobUserDeleted)
+	}
+}
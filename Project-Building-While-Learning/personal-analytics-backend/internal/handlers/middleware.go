@@ -6,14 +6,18 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 // AuthMiddleware verifies JWT token before allowing access to protected routes
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// A Handler method because API keys are looked up in its repository
+func (h *Handler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slog.Debug("Auth middleware checking")
 
@@ -25,6 +29,12 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// Personal API key instead of a JWT: "ApiKey pak_..." (see apikeys.go)
+		if key, isAPIKey := strings.CutPrefix(authHeader, "ApiKey "); isAPIKey {
+			h.apiKeyAuth(w, r, key, next)
+			return
+		}
+
 		// STEP 2: Extract token (remove "Bearer " prefix)
 		// Header format: "Bearer eyJhbGci...xyz"
 		// We want just: "eyJhbGci...xyz"
@@ -32,7 +42,7 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		if tokenString == authHeader {
 			// TrimPrefix didn't remove anything = no "Bearer " prefix
 			slog.Warn("Invalid Authorization header format")
-			errorResponseAuth(w, http.StatusUnauthorized, "Invalid authorization format. Use: Bearer <token>")
+			errorResponseAuth(w, http.StatusUnauthorized, "Invalid authorization format. Use: Bearer <token> or ApiKey <key>")
 			return
 		}
 
@@ -52,33 +62,85 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// STEP 5: Put user_id in request context (like a backpack for passing data)
+		// STEP 5: Reject revoked tokens (logout, "log out everywhere")
+		// The signature can't be taken back, so we keep a short list of revoked ones
+		// (see cache/revoked.go). Tokens without jti/iat predate the list: log in again.
+		jti, _ := claims["jti"].(string)
+		issuedAt, err := claims.GetIssuedAt()
+		if jti == "" || err != nil || issuedAt == nil {
+			slog.Warn("Token without jti or iat")
+			errorResponseAuth(w, http.StatusUnauthorized, "Invalid token claims")
+			return
+		}
+		// iat_ms when the token has it (all tokens signed since it was added): the
+		// cutoff of "log out everywhere" is in milliseconds, iat only in seconds
+		issued := issuedAt.Time
+		if ms, ok := claims["iat_ms"].(float64); ok {
+			issued = time.UnixMilli(int64(ms))
+		}
+		if cache.IsTokenRevoked(jti, int64(userID), issued) {
+			slog.Warn("Revoked token used", "user_id", int64(userID))
+			errorResponseAuth(w, http.StatusUnauthorized, "Token has been revoked")
+			return
+		}
+
+		// STEP 6: Put user_id and role in request context (like a backpack for passing data)
+		// Tokens from before roles existed have no "role" claim: they get the least privileges
+		role, _ := claims["role"].(string)
+		if role == "" {
+			role = models.RoleUser
+		}
 		ctx := context.WithValue(r.Context(), "user_id", int64(userID))
+		ctx = context.WithValue(ctx, "role", role)
 
-		// STEP 6: Call next handler with updated context
+		// STEP 7: Call next handler with updated context
 		slog.Debug("User authenticated", "user_id", int64(userID))
 		next(w, r.WithContext(ctx))
 	}
 }
 
+// apiKeyAuth is AuthMiddleware for "Authorization: ApiKey <key>"
+// Same context as a JWT (user_id, role) plus the key's scopes for RequireScope
+func (h *Handler) apiKeyAuth(w http.ResponseWriter, r *http.Request, key string, next http.HandlerFunc) {
+	apiKey, err := h.authenticateAPIKey(r, key)
+	if errors.Is(err, db.ErrTokenInvalid) {
+		slog.Warn("Invalid API key")
+		errorResponseAuth(w, http.StatusUnauthorized, "Invalid or revoked API key")
+		return
+	}
+	if err != nil {
+		slog.Error("API key lookup failed", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to authenticate")
+		return
+	}
+
+	// A key never carries admin rights, even when an admin created it
+	ctx := context.WithValue(r.Context(), "user_id", apiKey.UserID)
+	ctx = context.WithValue(ctx, "role", models.RoleUser)
+	ctx = context.WithValue(ctx, "api_key_scopes", apiKey.Scopes)
+
+	slog.Debug("User authenticated with API key", "user_id", apiKey.UserID, "api_key_id", apiKey.ID)
+	next(w, r.WithContext(ctx))
+}
+
+// userIDFromContext returns the user_id that AuthMiddleware put in the request context
+// ok is false if the route is not behind AuthMiddleware (or the value has the wrong type)
+func userIDFromContext(r *http.Request) (int64, bool) {
+	userID, ok := r.Context().Value("user_id").(int64)
+	return userID, ok
+}
+
 // validateToken verifies JWT token signature and returns claims
 func validateToken(tokenString string) (jwt.MapClaims, error) {
-	// Get secret key from environment
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return nil, fmt.Errorf("JWT_SECRET not configured")
+	// Keys are loaded once at startup (tokens.go: SigningKeys)
+	if SigningKeys == nil {
+		return nil, fmt.Errorf("signing keys not configured")
 	}
 
 	// Parse token (opposite of creating it!)
-	// jwt.Parse: Takes token string, returns token object
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// This function is called by jwt.Parse to get the secret key
-		// Check if signing method matches what we used (HS256)
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
+	// The key is chosen by the token's "kid" header, and the token's "alg" must
+	// match that key - retired keys still work during their grace window (see jwtkeys)
+	token, err := SigningKeys.Parse(tokenString)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -90,6 +152,8 @@ func validateToken(tokenString string) (jwt.MapClaims, error) {
 		if errors.Is(err, jwt.ErrTokenMalformed) {
 			return nil, fmt.Errorf("Malformed token %w", err)
 		}
+		// Unknown kid, retired key, wrong alg for the key...
+		return nil, err
 	}
 
 	// Extract claims from token
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+/*
+=== PROFILE AND PREFERENCES ===
+
+  GET   /me            the account with its preferences
+  PATCH /me            {"display_name": "Sam", "timezone": "America/New_York",
+                        "week_start": "sunday", "mood_labels": {"1": "awful", "10": "great"}}
+  POST  /me/password   {"current_password": "...", "new_password": "..."}
+
+PATCH changes only the fields in the body; mood_labels replaces all labels
+({} removes them).
+
+The time zone is not cosmetic: created_at is stored in UTC, so without it an
+entry written late in the evening in New York counts for the next day in
+/analytics/mood. The analytics handlers read it with calendarFor (see db/analytics.go).
+*/
+
+// Profile limits
+const (
+	displayNameMaxLength = 100
+	moodLabelMaxLength   = 50
+)
+
+// weekStartDays are the valid values of week_start
+var weekStartDays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// UpdateProfileRequest is the body of PATCH /me - a missing field stays unchanged
+type UpdateProfileRequest struct {
+	DisplayName *string        `json:"display_name"`
+	Timezone    *string        `json:"timezone"`
+	WeekStart   *string        `json:"week_start"`
+	MoodLabels  map[int]string `json:"mood_labels"`
+}
+
+// ChangePasswordRequest is the body of POST /me/password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// calendarFor is the user's calendar for analytics: their time zone and week start.
+// Both are validated by PATCH /me; should a stored zone ever stop loading
+// (older tzdata on a new server), analytics fall back to UTC instead of failing.
+func calendarFor(user models.User) db.Calendar {
+	cal := db.UTCCalendar
+	if loc, err := time.LoadLocation(user.Timezone); err == nil {
+		cal.Location = loc
+	}
+	if day, ok := weekStartDays[user.WeekStart]; ok {
+		cal.WeekStart = day
+	}
+	return cal
+}
+
+// GetProfile handles GET /me
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), userID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Error loading profile", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load profile")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"user":    user,
+	})
+}
+
+// UpdateProfile handles PATCH /me
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), userID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Error loading profile", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	// Start from what is stored, overwrite what the body contains
+	profile := db.ProfileInput{
+		DisplayName: user.DisplayName,
+		Timezone:    user.Timezone,
+		WeekStart:   user.WeekStart,
+		MoodLabels:  user.MoodLabels,
+	}
+
+	if req.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*req.DisplayName)
+		if utf8.RuneCountInString(profile.DisplayName) > displayNameMaxLength {
+			errorResponse(w, http.StatusBadRequest, "display_name must be at most "+strconv.Itoa(displayNameMaxLength)+" characters")
+			return
+		}
+	}
+
+	if req.Timezone != nil {
+		// LoadLocation also accepts "" (UTC) and "Local" (the SERVER's zone) - neither is a user's zone
+		tz := strings.TrimSpace(*req.Timezone)
+		if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+			errorResponse(w, http.StatusBadRequest, `timezone must be an IANA time zone name like "Europe/Berlin"`)
+			return
+		}
+		profile.Timezone = tz
+	}
+
+	if req.WeekStart != nil {
+		day := strings.ToLower(strings.TrimSpace(*req.WeekStart))
+		if _, ok := weekStartDays[day]; !ok {
+			errorResponse(w, http.StatusBadRequest, "week_start must be a day of the week, e.g. monday or sunday")
+			return
+		}
+		profile.WeekStart = day
+	}
+
+	if req.MoodLabels != nil {
+		labels := make(map[int]string, len(req.MoodLabels))
+		for mood, label := range req.MoodLabels {
+			// Same scale as entries (entries.go)
+			if mood < 1 || mood > 10 {
+				errorResponse(w, http.StatusBadRequest, "mood_labels keys must be moods between 1 and 10")
+				return
+			}
+			label = strings.TrimSpace(label)
+			if label == "" || utf8.RuneCountInString(label) > moodLabelMaxLength {
+				errorResponse(w, http.StatusBadRequest, "mood labels must be 1 to "+strconv.Itoa(moodLabelMaxLength)+" characters")
+				return
+			}
+			labels[mood] = label
+		}
+		profile.MoodLabels = labels
+	}
+
+	if err := h.users.UpdateProfile(r.Context(), userID, profile); err != nil {
+		logger.Error("Error updating profile", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	user.DisplayName, user.Timezone, user.WeekStart = profile.DisplayName, profile.Timezone, profile.WeekStart
+	user.MoodLabels = maps.Clone(profile.MoodLabels)
+
+	logger.Info("Profile updated", "user_id", userID, "timezone", user.Timezone, "week_start", user.WeekStart)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Profile updated",
+		"user":    user,
+	})
+}
+
+// ChangePassword handles POST /me/password
+// Like a password reset it logs out every session (this one too): whoever
+// else had a token is out, and the user logs in again with the new password.
+// API keys keep working - they never depended on the password.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponseAuth(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponseAuth(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.CurrentPassword == "" {
+		errorResponseAuth(w, http.StatusBadRequest, "current_password is required")
+		return
+	}
+	// Same password rules as Register
+	if len(req.NewPassword) < 6 {
+		errorResponseAuth(w, http.StatusBadRequest, "new_password must be at least 6 characters")
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), userID)
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponseAuth(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Error loading user", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	if !checkCurrentPassword(w, r, user, req.CurrentPassword, "password_change") {
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("Error hashing password", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+	if err := h.users.SetPassword(r.Context(), userID, string(passwordHash)); err != nil {
+		logger.Error("Error saving new password", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	// Log out everywhere, same as ResetPassword (account.go)
+	if err := h.tokens.RevokeUser(r.Context(), userID); err != nil {
+		logger.Error("Error revoking sessions after password change", "error", err, "user_id", userID)
+	}
+	cache.RevokeUserTokens(userID, time.Now(), AccessTokenTTL)
+	clearLoginFailures(normalizeEmail(user.Email))
+
+	logger.Warn("Password changed", "event", "password_changed", "user_id", userID, "ip", clientIP(r))
+	respondJSON(w, http.StatusOK, RegisterResponse{
+		Success: true,
+		Message: "Password changed, please log in again",
+	})
+}
+
+// checkCurrentPassword verifies the password a logged-in user typed again to
+// confirm something sensitive (event: "password_change", "account_delete").
+// Wrong passwords count towards the login lockout (lockout.go), so a stolen
+// token can't be used to guess the password. On false the response is sent:
+// 401 like a wrong password at /login, 429 while locked out.
+func checkCurrentPassword(w http.ResponseWriter, r *http.Request, user models.User, password string, event string) bool {
+	email, ip := normalizeEmail(user.Email), clientIP(r)
+	if wait := loginLockedFor(email, ip); wait > 0 {
+		loginAudit(event+"_blocked", email, ip, "user_id", user.ID)
+		respondLoginLocked(w, wait)
+		return false
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		failures, _ := recordLoginFailure(email, ip)
+		loginAudit(event+"_failed", email, ip, "user_id", user.ID, "failures", failures)
+		errorResponseAuth(w, http.StatusUnauthorized, "Incorrect password")
+		return false
+	}
+	return true
+}
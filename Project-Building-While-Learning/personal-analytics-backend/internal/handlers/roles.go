@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+)
+
+/*
+=== ROLE-BASED ACCESS CONTROL ===
+
+AuthMiddleware answers "WHO are you?" (user_id from the token).
+RequireRole answers "are you ALLOWED to do this?" (role from the token).
+
+  users.role ("user" / "admin")
+       ↓ login / refresh
+  JWT claim "role"
+       ↓ AuthMiddleware
+  request context "role"
+       ↓ RequireRole(models.RoleAdmin)
+  403 Forbidden, or the handler
+
+RequireRole must sit INSIDE AuthMiddleware (it reads what AuthMiddleware stored):
+
+  h.AuthMiddleware(handlers.RequireRole(models.RoleAdmin)(h.AdminStats))
+
+401 vs 403: 401 = "we don't know who you are" (log in), 403 = "we know, and no".
+
+The role is in the token, so checking it costs no database lookup. The price:
+a role change shows up with the user's next access token (at most
+AccessTokenTTL). Disabling an account doesn't wait - it revokes every token.
+*/
+
+// RequireRole only lets requests through whose token has one of roles
+func RequireRole(roles ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			role := roleFromContext(r)
+			if !slices.Contains(roles, role) {
+				userID, _ := userIDFromContext(r)
+				GetLoggerWithRequestID(r).Warn("Access denied",
+					"user_id", userID, "role", role, "required", roles, "path", r.URL.Path)
+				errorResponseAuth(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// roleFromContext returns the role AuthMiddleware put in the request context ("" if none)
+func roleFromContext(r *http.Request) string {
+	role, _ := r.Context().Value("role").(string)
+	return role
+}
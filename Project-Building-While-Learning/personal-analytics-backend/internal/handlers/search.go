@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"personal-analytics-backend/internal/db"
+	"strconv"
+	"strings"
+)
+
+// SearchEntries handles GET /entries/search?q=...&limit=20
+// Full-text search over the authenticated user's entries, best matches first
+// Each result has a snippet with the matched words wrapped in <mark></mark>
+func (h *Handler) SearchEntries(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		errorResponse(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	if len(q) > maxQueryLength {
+		errorResponse(w, http.StatusBadRequest, "q is too long")
+		return
+	}
+	if !db.HasSearchTerms(q) {
+		errorResponse(w, http.StatusBadRequest, "q must contain at least one letter or digit")
+		return
+	}
+
+	// Same rule as pagination: invalid limit falls back to the default
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 50 {
+		limit = l
+	}
+
+	results, err := h.entries.Search(r.Context(), userID, q, limit)
+	if err != nil {
+		logger.Error("Search failed", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to search entries")
+		return
+	}
+
+	logger.Info("Search results returned", "user_id", userID, "count", len(results))
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"query":   q,
+		"count":   len(results),
+		"results": results,
+	})
+}
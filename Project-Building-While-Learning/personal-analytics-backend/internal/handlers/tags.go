@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// GetTags handles GET /tags
+// Lists the authenticated user's tags with how many entries use each one
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	tags, err := h.entries.Tags(r.Context(), userID)
+	if err != nil {
+		logger.Error("Failed to load tags", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to load tags")
+		return
+	}
+
+	logger.Info("Tags returned", "user_id", userID, "count", len(tags))
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"tags":    tags,
+	})
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/jwtkeys"
+	"personal-analytics-backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+/*
+=== ACCESS TOKENS + REFRESH TOKENS ===
+
+Before: /login returned one JWT valid for 24 hours. Logout couldn't take it back.
+
+Now /login returns two tokens:
+
+  token (access)    JWT, valid AccessTokenTTL (15 min). Sent on every request,
+                    checked by AuthMiddleware without a database lookup.
+  refresh_token     random string, valid RefreshTokenTTL (30 days). Only sent to
+                    POST /token/refresh to get a NEW access token + NEW refresh token.
+
+Logout = POST /logout with the refresh token → its family is revoked, no new
+access tokens can be obtained. If the access token is sent along
+(Authorization: Bearer) and belongs to the same user, its jti goes on the
+revocation list and it stops working right away instead of at its exp.
+
+POST /logout/all ("my token was stolen") revokes every refresh token of the user
+AND every access token issued so far (cache.RevokeUserTokens).
+
+Rotation and reuse detection live in the repository (see db/refresh_tokens.go).
+Only sha256(refresh_token) is stored, like a password hash but fast: the token
+is 32 random bytes, there is nothing to brute-force.
+*/
+
+// Token lifetimes, overwritten by main.go from config (same pattern as RateLimitRequests)
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// SigningKeys signs new access tokens and verifies incoming ones (see jwtkeys).
+// Loaded once by main.go from JWT_KEYS / JWT_SECRET instead of reading the environment per request.
+var SigningKeys *jwtkeys.KeySet
+
+// RefreshRequest is the body of POST /token/refresh and POST /logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// signAccessToken creates the short-lived JWT AuthMiddleware accepts
+func signAccessToken(userID int64, role string) (string, error) {
+	// JWT token = "ticket" proving user logged in (contains user_id)
+	if SigningKeys == nil {
+		return "", fmt.Errorf("signing keys not configured")
+	}
+
+	// STEP 1: Create claims (data to put inside token)
+	// jwt.MapClaims: Just a map[string]interface{} - you already know this!
+	// "user_id": userID - So we know WHO this token belongs to
+	// "exp": Expiration time - Token becomes invalid after AccessTokenTTL
+	// "iat": Issued at - when the token was created
+	// "iat_ms": the same in milliseconds: iat is whole seconds, too coarse to tell a token
+	//           issued right after "log out everywhere" from one issued right before it
+	// "jti": JWT ID - unique per token, so ONE token can be revoked (cache/revoked.go)
+	// "role": what the user may do (RequireRole) - read from the database at login/refresh,
+	//         so a role change takes effect with the next access token
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"role":    role,
+		"exp":     now.Add(AccessTokenTTL).Unix(), // Unix() converts time to NUMBER
+		"iat":     now.Unix(),
+		"iat_ms":  now.UnixMilli(),
+		"jti":     GenerateRequestID(),
+		// Why Unix()? JWT needs simple numbers, not complex Go time objects
+		// Example: time.Now().Unix() = 1736359530 (just a number)
+	}
+
+	// STEP 2: Sign with the ACTIVE key and convert to string (this makes it official!)
+	// The header gets "kid" (which key) and "alg" (HS256, RS256 or EdDSA), so
+	// validation still works after the active key is rotated
+	// Format: header.payload.signature (3 parts separated by dots)
+	return SigningKeys.Sign(claims)
+}
+
+// newOpaqueToken returns a random token (refresh token, email link) and the hash to store
+func newOpaqueToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashOpaqueToken(token), nil
+}
+
+// hashOpaqueToken is what the database stores and looks tokens up by
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSession starts a new refresh token family for a login and returns both tokens
+func (h *Handler) newSession(ctx context.Context, user models.User) (LoginResponse, error) {
+	refreshToken, refreshHash, err := newOpaqueToken()
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	// The family id only groups tokens, it is never sent to the client
+	familyID := GenerateRequestID()
+	err = h.tokens.Create(ctx, user.ID, familyID, refreshHash, time.Now().Add(RefreshTokenTTL))
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	accessToken, err := signAccessToken(user.ID, user.Role)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	return LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RefreshToken handles POST /token/refresh
+// Exchanges a refresh token for a new access token and a new refresh token.
+// The old refresh token stops working; using it again revokes the whole family.
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		errorResponseAuth(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	newToken, newHash, err := newOpaqueToken()
+	if err != nil {
+		logger.Error("Error generating refresh token", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	userID, err := h.tokens.Rotate(r.Context(), hashOpaqueToken(req.RefreshToken), newHash, time.Now().Add(RefreshTokenTTL))
+	if errors.Is(err, db.ErrTokenReused) {
+		// Someone else has a copy of this token - every token of the login is now revoked
+		logger.Warn("Refresh token reuse, session revoked")
+		errorResponseAuth(w, http.StatusUnauthorized, "Refresh token already used, please log in again")
+		return
+	}
+	if errors.Is(err, db.ErrTokenInvalid) {
+		errorResponseAuth(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+	if err != nil {
+		logger.Error("Error rotating refresh token", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	// Read the user again: the new access token carries the CURRENT role, and a
+	// disabled (or deleted) account must not keep itself alive by refreshing.
+	// Rotate has stored newHash by now: revoke its family on the way out, or a
+	// refresh racing the admin's disable would leave a valid token behind.
+	user, err := h.users.GetByID(r.Context(), userID)
+	if errors.Is(err, db.ErrNotFound) {
+		h.revokeRotatedFamily(r, newHash, userID)
+		errorResponseAuth(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+	if err != nil {
+		logger.Error("Error loading user", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+	if user.DisabledAt != nil {
+		logger.Warn("Refresh attempt on disabled account", "user_id", userID)
+		h.revokeRotatedFamily(r, newHash, userID)
+		errorResponseAuth(w, http.StatusForbidden, "Account disabled")
+		return
+	}
+
+	accessToken, err := signAccessToken(user.ID, user.Role)
+	if err != nil {
+		logger.Error("Error generating JWT token", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	logger.Info("Token refreshed", "user_id", userID)
+	respondJSON(w, http.StatusOK, LoginResponse{
+		Success:      true,
+		Message:      "Token refreshed",
+		Token:        accessToken,
+		RefreshToken: newToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	})
+}
+
+// revokeRotatedFamily revokes the family of a refresh token RefreshToken just
+// stored but won't hand out. Only logged on failure: the response is an error anyway.
+func (h *Handler) revokeRotatedFamily(r *http.Request, tokenHash string, userID int64) {
+	if _, err := h.tokens.RevokeFamily(r.Context(), tokenHash); err != nil && !errors.Is(err, db.ErrTokenInvalid) {
+		GetLoggerWithRequestID(r).Error("Error revoking refresh token", "error", err, "user_id", userID)
+	}
+}
+
+// Logout handles POST /logout
+// Revokes the refresh token family of this login: no new access tokens can be
+// obtained with it. Needs the refresh token, not the access token, so an expired
+// access token doesn't prevent logging out.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		errorResponseAuth(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	userID, err := h.tokens.RevokeFamily(r.Context(), hashOpaqueToken(req.RefreshToken))
+	if errors.Is(err, db.ErrTokenInvalid) {
+		errorResponseAuth(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if err != nil {
+		logger.Error("Error revoking refresh token", "error", err)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	// Optional: also kill the access token this client is holding - if it's the
+	// same user's. Anyone's refresh token must not revoke anyone's access token.
+	revokeBearerToken(r, userID)
+
+	logger.Info("User logged out", "user_id", userID)
+	respondJSON(w, http.StatusOK, RegisterResponse{
+		Success: true,
+		Message: "Logged out successfully",
+	})
+}
+
+// LogoutAll handles POST /logout/all (PROTECTED)
+// Logs the user out on every device: all refresh tokens are revoked, and every
+// access token issued until now is rejected by AuthMiddleware - including the one
+// used for this request.
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponseAuth(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Refresh tokens first: if this fails nothing was revoked and the client can retry
+	if err := h.tokens.RevokeUser(r.Context(), userID); err != nil {
+		logger.Error("Error revoking refresh tokens", "error", err, "user_id", userID)
+		errorResponseAuth(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	// No access token lives longer than AccessTokenTTL, so the list entry doesn't need to either
+	cache.RevokeUserTokens(userID, time.Now(), AccessTokenTTL)
+
+	logger.Info("User logged out everywhere", "user_id", userID)
+	respondJSON(w, http.StatusOK, RegisterResponse{
+		Success: true,
+		Message: "All sessions revoked",
+	})
+}
+
+// revokeBearerToken puts the request's access token (if any, valid and userID's)
+// on the revocation list
+func revokeBearerToken(r *http.Request, userID int64) {
+	tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return
+	}
+
+	claims, err := validateToken(tokenString)
+	if err != nil {
+		return // expired or forged: nothing worth revoking
+	}
+	if sub, _ := claims["user_id"].(float64); int64(sub) != userID {
+		return // someone else's token
+	}
+	jti, _ := claims["jti"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || expiresAt == nil {
+		return
+	}
+	cache.RevokeToken(jti, expiresAt.Time)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"personal-analytics-backend/internal/cache"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/jwtkeys"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenDisabledOrDeletedUser(t *testing.T) {
+	useFakeRedis(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		change func(h *Handler, conn *db.Conn, userID int64) error
+		want   int
+	}{
+		{"disabled", func(h *Handler, conn *db.Conn, userID int64) error {
+			return h.users.SetDisabled(ctx, userID, true)
+		}, http.StatusForbidden},
+		// Only the users row: as if the account went between Rotate and GetByID
+		{"deleted", func(h *Handler, conn *db.Conn, userID int64) error {
+			_, err := conn.Exec(`DELETE FROM users WHERE id = ?`, userID)
+			return err
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, conn := newSQLTestHandler(t)
+			userID, err := h.users.Create(ctx, "refresh@example.com", "hash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.tokens.Create(ctx, userID, "family", hashOpaqueToken("refresh-1"), time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.change(h, conn, userID); err != nil {
+				t.Fatal(err)
+			}
+
+			r := newRequest(http.MethodPost, "/token/refresh", `{"refresh_token":"refresh-1"}`, 0)
+			if code := serve(t, h.RefreshToken, r, nil); code != tt.want {
+				t.Errorf("status %d, want %d", code, tt.want)
+			}
+			// Rotate stored a new token; it must not be left usable
+			var live int
+			conn.QueryRow(`SELECT COUNT(*) FROM refresh_tokens WHERE used_at IS NULL AND revoked_at IS NULL`).Scan(&live)
+			if live != 0 {
+				t.Errorf("%d usable refresh tokens left, want 0", live)
+			}
+		})
+	}
+}
+
+func TestLogoutRevokesOnlyOwnAccessToken(t *testing.T) {
+	useFakeRedis(t)
+	ctx := context.Background()
+	keys, err := jwtkeys.FromSecret("test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousKeys := SigningKeys
+	SigningKeys = keys
+	defer func() { SigningKeys = previousKeys }()
+
+	const owner, other = 1, 2
+	tests := []struct {
+		name        string
+		bearerUser  int64
+		wantRevoked bool
+	}{
+		{"own access token", owner, true},
+		// Holding someone's refresh token must not log a third party out
+		{"other user's access token", other, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler()
+			if err := h.tokens.Create(ctx, owner, "family", hashOpaqueToken("refresh-1"), time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			access, err := signAccessToken(tt.bearerUser, "user")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := newRequest(http.MethodPost, "/logout", `{"refresh_token":"refresh-1"}`, 0)
+			r.Header.Set("Authorization", "Bearer "+access)
+			if code := serve(t, h.Logout, r, nil); code != http.StatusOK {
+				t.Fatalf("status %d, want 200", code)
+			}
+
+			claims, err := validateToken(access)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jti, _ := claims["jti"].(string)
+			if revoked := cache.IsTokenRevoked(jti, tt.bearerUser, time.Now()); revoked != tt.wantRevoked {
+				t.Errorf("access token revoked %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"personal-analytics-backend/internal/db"
+	"strconv"
+)
+
+// ListTrash handles GET /entries/trash
+// Lists the user's deleted entries (most recently deleted first) that can still be restored
+// Supports pagination: ?page=1&limit=10
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Same defaults and limits as GET /entries
+	page, limit := 1, 10
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	entries, total, err := h.entries.ListTrash(r.Context(), userID, page, limit)
+	if err != nil {
+		logger.Error("Failed to fetch trash", "error", err, "user_id", userID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+
+	logger.Info("Trash returned", "count", len(entries), "user_id", userID, "page", page)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"entries":    entries,
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+	})
+}
+
+// RestoreEntry handles POST /entries/restore?id=5
+// Takes an entry out of the trash (undo of DELETE /entries)
+func (h *Handler) RestoreEntry(w http.ResponseWriter, r *http.Request) {
+	logger := GetLoggerWithRequestID(r)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entryID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid or missing entry ID")
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		errorResponse(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err = h.entries.Restore(r.Context(), userID, entryID)
+	// ErrNotFound: not in the trash, not the user's, or already purged
+	if errors.Is(err, db.ErrNotFound) {
+		errorResponse(w, http.StatusNotFound, "Entry not found in trash")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to restore entry", "error", err, "entry_id", entryID)
+		errorResponse(w, http.StatusInternalServerError, "Failed to restore entry")
+		return
+	}
+
+	logger.Info("Entry restored", "entry_id", entryID, "user_id", userID)
+	respondJSON(w, http.StatusOK, CreateEntryResponse{
+		Success: true,
+		Message: "Entry restored successfully",
+	})
+}
@@ -0,0 +1,307 @@
+package jwtkeys
+
+/*
+=== SIGNING KEY ROTATION ===
+
+Before: one JWT_SECRET, read from the environment on every request, HMAC only.
+Changing it logged everybody out at once, and nobody but us could verify a
+token (verifying HMAC needs the same secret that signs).
+
+Now: a KeySet, loaded once at startup.
+
+  kid "2026-10"  RS256  ACTIVE   signs every new token, header {"kid":"2026-10"}
+  kid "2026-07"  EdDSA  retired  verifies only, until retired_at + grace
+  kid "legacy"   HS256  retired  verifies only, until retired_at + grace
+
+Validation picks the key by the token's kid header - no guessing, no trying
+every key. A token signed with a retired key keeps working until the grace
+window ends; after that it is rejected (a leaked old key can't mint tokens forever).
+
+=== JWT_KEYS FORMAT ===
+
+  JWT_KEYS="kid,alg,source[,retired_at];kid,alg,source[,retired_at];..."
+
+  kid         any name, e.g. the month it was created
+  alg         HS256, RS256 or EdDSA
+  source      HS256: NAME of the environment variable holding the secret (e.g. JWT_SECRET)
+              RS256 / EdDSA: path to a PEM file (private key, or public key for retired keys)
+  retired_at  RFC 3339 time the key stopped signing, e.g. 2026-10-01T00:00:00Z
+
+The FIRST key is the active one and must not be retired.
+No JWT_KEYS = one HS256 key "default" from JWT_SECRET (the old behavior).
+
+=== WHY ASYMMETRIC (RS256 / EdDSA)? ===
+
+HS256: same secret signs AND verifies → every service that verifies could also forge.
+RS256 / EdDSA: private key signs (only this server), public key verifies (anyone).
+The public keys are published at /.well-known/jwks.json, so other services
+verify our tokens without ever seeing a secret.
+*/
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is one entry of the key set
+type Key struct {
+	ID        string
+	Alg       string    // HS256, RS256 or EdDSA
+	RetiredAt time.Time // zero = not retired
+
+	method    jwt.SigningMethod
+	signKey   interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey; nil for verify-only keys
+	verifyKey interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// KeySet holds the active signing key and the retired keys that still verify
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key // by kid
+	list   []*Key          // JWT_KEYS order, active key first
+	grace  time.Duration   // how long a retired key keeps verifying
+}
+
+// FromSecret builds the single-key HS256 set used when JWT_KEYS is not set
+func FromSecret(secret string) (*KeySet, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET is empty")
+	}
+	key := &Key{ID: "default", Alg: "HS256", method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+	return &KeySet{active: key, keys: map[string]*Key{key.ID: key}, list: []*Key{key}}, nil
+}
+
+// Load parses a JWT_KEYS value (see format above) and reads the key files
+func Load(spec string, grace time.Duration) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key), grace: grace}
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, err := parseEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("JWT_KEYS: duplicate kid %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ks.list = append(ks.list, key)
+
+		// The first key signs
+		if ks.active == nil {
+			if !key.RetiredAt.IsZero() || key.signKey == nil {
+				return nil, fmt.Errorf("JWT_KEYS: first key %q must be a private key that is not retired", key.ID)
+			}
+			ks.active = key
+		}
+	}
+
+	if ks.active == nil {
+		return nil, fmt.Errorf("JWT_KEYS: no keys")
+	}
+	return ks, nil
+}
+
+// parseEntry turns "kid,alg,source[,retired_at]" into a Key
+func parseEntry(entry string) (*Key, error) {
+	parts := strings.Split(entry, ",")
+	if len(parts) < 3 || len(parts) > 4 {
+		return nil, fmt.Errorf("JWT_KEYS: %q must be kid,alg,source[,retired_at]", entry)
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	key := &Key{ID: parts[0], Alg: parts[1]}
+	if key.ID == "" {
+		return nil, fmt.Errorf("JWT_KEYS: %q has an empty kid", entry)
+	}
+	if len(parts) == 4 {
+		retiredAt, err := time.Parse(time.RFC3339, parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("JWT_KEYS: key %q: retired_at: %w", key.ID, err)
+		}
+		key.RetiredAt = retiredAt
+	}
+
+	source := parts[2]
+	switch key.Alg {
+	case "HS256":
+		secret := os.Getenv(source)
+		if secret == "" {
+			return nil, fmt.Errorf("JWT_KEYS: key %q: environment variable %s is empty", key.ID, source)
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey, key.verifyKey = []byte(secret), []byte(secret)
+
+	case "RS256", "EdDSA":
+		signKey, verifyKey, err := readPEM(source)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_KEYS: key %q: %w", key.ID, err)
+		}
+		key.signKey, key.verifyKey = signKey, verifyKey
+
+		// The key type must match the algorithm, or RS256 could be "verified" with an Ed25519 key
+		_, isRSA := verifyKey.(*rsa.PublicKey)
+		_, isEd := verifyKey.(ed25519.PublicKey)
+		switch {
+		case key.Alg == "RS256" && isRSA:
+			key.method = jwt.SigningMethodRS256
+		case key.Alg == "EdDSA" && isEd:
+			key.method = jwt.SigningMethodEdDSA
+		default:
+			return nil, fmt.Errorf("JWT_KEYS: key %q: %s is not a %s key", key.ID, source, key.Alg)
+		}
+
+	default:
+		return nil, fmt.Errorf("JWT_KEYS: key %q: unsupported alg %q (use HS256, RS256 or EdDSA)", key.ID, key.Alg)
+	}
+
+	return key, nil
+}
+
+// readPEM loads a private key (PKCS#8 or PKCS#1) or a public key (PKIX) from a PEM file.
+// For a public key signKey is nil: the key can only verify.
+func readPEM(path string) (signKey interface{}, verifyKey interface{}, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, pub, nil
+
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return priv, &priv.PublicKey, nil
+
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		switch k := priv.(type) {
+		case *rsa.PrivateKey:
+			return k, &k.PublicKey, nil
+		case ed25519.PrivateKey:
+			return k, k.Public().(ed25519.PublicKey), nil
+		}
+		return nil, nil, fmt.Errorf("%s: unsupported private key type %T", path, priv)
+	}
+
+	return nil, nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+}
+
+// ActiveKID is the kid new tokens are signed with
+func (ks *KeySet) ActiveKID() string {
+	return ks.active.ID
+}
+
+// Sign signs the claims with the active key and puts its kid in the header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.signKey)
+}
+
+// Parse verifies a token against the key named by its kid header
+func (ks *KeySet) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, ks.keyFunc)
+}
+
+// keyFunc is called by jwt.Parse to pick the verification key
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	// The header's alg must be the key's alg: never let the token choose
+	// (classic attack: alg=HS256 with the RSA PUBLIC key used as the HMAC secret)
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for kid %q", token.Header["alg"], kid)
+	}
+	if !ks.usable(key, time.Now()) {
+		return nil, fmt.Errorf("kid %q is retired", kid)
+	}
+	return key.verifyKey, nil
+}
+
+// usable reports whether key may verify tokens at now: active, or retired less than grace ago
+func (ks *KeySet) usable(key *Key, now time.Time) bool {
+	return key.RetiredAt.IsZero() || now.Before(key.RetiredAt.Add(ks.grace))
+}
+
+// JWK is one public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`           // "RSA" or "OKP" (Ed25519)
+	Kid string `json:"kid"`           // matches the token header
+	Alg string `json:"alg"`           // RS256 or EdDSA
+	Use string `json:"use"`           // always "sig"
+	N   string `json:"n,omitempty"`   // RSA modulus, base64url
+	E   string `json:"e,omitempty"`   // RSA exponent, base64url
+	Crv string `json:"crv,omitempty"` // "Ed25519"
+	X   string `json:"x,omitempty"`   // Ed25519 public key, base64url
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that currently verify tokens.
+// HS256 keys are never published - their "public" key is the secret.
+func (ks *KeySet) JWKS() JWKS {
+	doc := JWKS{Keys: []JWK{}}
+	now := time.Now()
+
+	// Active key first, so clients that just take keys[0] get the right one
+	for _, key := range ks.list {
+		if !ks.usable(key, now) {
+			continue
+		}
+		b64 := base64.RawURLEncoding.EncodeToString
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			doc.Keys = append(doc.Keys, JWK{
+				Kty: "RSA", Kid: key.ID, Alg: key.Alg, Use: "sig",
+				N: b64(pub.N.Bytes()),
+				E: b64(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			doc.Keys = append(doc.Keys, JWK{
+				Kty: "OKP", Kid: key.ID, Alg: key.Alg, Use: "sig",
+				Crv: "Ed25519",
+				X:   b64(pub),
+			})
+		}
+	}
+	return doc
+}
@@ -0,0 +1,227 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKeys are the PEM files writeTestKeys wrote to a temp dir
+type testKeys struct {
+	rsa, rsaPublic, ed string // paths
+	rsaPublicPEM       []byte
+}
+
+// writeTestKeys writes fresh RSA and Ed25519 keys and sets the HS256 secrets
+// JWTKEYS_TEST_SECRET and JWTKEYS_TEST_EMPTY (empty) until the test ends
+func writeTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name string, blockType string, der []byte) (string, []byte) {
+		data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path, data
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys testKeys
+	keys.rsa, _ = write("rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	keys.rsaPublic, keys.rsaPublicPEM = write("rsa.pub.pem", "PUBLIC KEY", pubDER)
+	keys.ed, _ = write("ed.pem", "PRIVATE KEY", edDER)
+
+	t.Setenv("JWTKEYS_TEST_SECRET", "old-secret")
+	t.Setenv("JWTKEYS_TEST_EMPTY", "")
+	return keys
+}
+
+// mustLoad is Load for specs the test expects to be valid
+func mustLoad(t *testing.T, spec string, grace time.Duration) *KeySet {
+	t.Helper()
+	ks, err := Load(spec, grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func TestParse(t *testing.T) {
+	keys := writeTestKeys(t)
+	now := time.Now()
+	rfc := func(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+
+	// EdDSA signs; the RSA key was retired within the grace hour, the HS256 one before it
+	verifier := mustLoad(t, "new,EdDSA,"+keys.ed+
+		"; recent,RS256,"+keys.rsaPublic+","+rfc(now.Add(-30*time.Minute))+
+		"; old,HS256,JWTKEYS_TEST_SECRET,"+rfc(now.Add(-2*time.Hour)), time.Hour)
+
+	// A key nobody loaded, for a valid-looking signature the verifier must reject
+	_, otherEd, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{"user_id": 1, "exp": now.Add(time.Hour).Unix()}
+	// signWith signs claims with the first key of spec, the way the server that had it active did
+	signWith := func(spec string) string {
+		t.Helper()
+		token, err := mustLoad(t, spec, 0).Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	// forge signs claims with any method, key and kid
+	forge := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		t.Helper()
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string // "" = valid
+	}{
+		{"active key", signWith("new,EdDSA," + keys.ed), ""},
+		{"retired key within grace", signWith("recent,RS256," + keys.rsa), ""},
+		{"retired key past grace", signWith("old,HS256,JWTKEYS_TEST_SECRET"), `kid "old" is retired`},
+		{"unknown kid", signWith("other,EdDSA," + keys.ed), `unknown kid "other"`},
+		{"no kid", forge(jwt.SigningMethodHS256, "", []byte("old-secret")), `unknown kid ""`},
+		{"HS256 with the RSA public key as secret", forge(jwt.SigningMethodHS256, "recent", keys.rsaPublicPEM), "unexpected signing method HS256"},
+		{"RS256 under the EdDSA kid", forge(jwt.SigningMethodRS256, "new", mustLoad(t, "x,RS256,"+keys.rsa, 0).active.signKey), "unexpected signing method RS256"},
+		{"signature of another key", forge(jwt.SigningMethodEdDSA, "new", otherEd), "signature is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := verifier.Parse(tt.token)
+			if tt.wantErr == "" {
+				if err != nil || !token.Valid {
+					t.Fatalf("rejected: %v", err)
+				}
+				if got := token.Claims.(jwt.MapClaims)["user_id"]; got != float64(1) {
+					t.Errorf("user_id %v, want 1", got)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Only the asymmetric keys that still verify are published, the active one first
+	var kids []string
+	for _, k := range verifier.JWKS().Keys {
+		kids = append(kids, k.Kid)
+	}
+	if want := []string{"new", "recent"}; !slices.Equal(kids, want) {
+		t.Errorf("JWKS kids %v, want %v", kids, want)
+	}
+}
+
+func TestGraceWindow(t *testing.T) {
+	retiredAt := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	ks := &KeySet{grace: 24 * time.Hour}
+	active := &Key{ID: "active"}
+	retired := &Key{ID: "retired", RetiredAt: retiredAt}
+
+	tests := []struct {
+		name string
+		key  *Key
+		at   time.Time
+		want bool
+	}{
+		{"active key, any time", active, retiredAt.AddDate(10, 0, 0), true},
+		{"before it was retired", retired, retiredAt.Add(-time.Hour), true},
+		{"just retired", retired, retiredAt, true},
+		{"last second of the grace window", retired, retiredAt.Add(24*time.Hour - time.Second), true},
+		{"grace window over", retired, retiredAt.Add(24 * time.Hour), false},
+		{"long after", retired, retiredAt.AddDate(1, 0, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ks.usable(tt.key, tt.at); got != tt.want {
+				t.Errorf("usable at %s = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	keys := writeTestKeys(t)
+	past := "2026-01-01T00:00:00Z"
+
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string // "" = valid
+	}{
+		{"HS256 active, RSA public key retired", "a,HS256,JWTKEYS_TEST_SECRET; b,RS256," + keys.rsaPublic + "," + past, ""},
+		{"PKCS#1 RSA and PKCS#8 Ed25519", "a,RS256," + keys.rsa + ";b,EdDSA," + keys.ed, ""},
+		{"empty", " ; ", "no keys"},
+		{"missing source", "a,HS256", "must be kid,alg,source"},
+		{"empty kid", ",HS256,JWTKEYS_TEST_SECRET", "empty kid"},
+		{"empty secret", "a,HS256,JWTKEYS_TEST_EMPTY", "JWTKEYS_TEST_EMPTY is empty"},
+		{"unsupported alg", "a,HS512,JWTKEYS_TEST_SECRET", `unsupported alg "HS512"`},
+		{"Ed25519 file as RS256", "a,RS256," + keys.ed, "is not a RS256 key"},
+		{"RSA file as EdDSA", "a,EdDSA," + keys.rsa, "is not a EdDSA key"},
+		{"missing file", "a,RS256," + keys.rsa + ".missing", "no such file"},
+		{"bad retired_at", "a,HS256,JWTKEYS_TEST_SECRET;b,HS256,JWTKEYS_TEST_SECRET,yesterday", "retired_at"},
+		{"duplicate kid", "a,HS256,JWTKEYS_TEST_SECRET;a,EdDSA," + keys.ed, `duplicate kid "a"`},
+		{"first key retired", "a,HS256,JWTKEYS_TEST_SECRET," + past + ";b,EdDSA," + keys.ed, `first key "a" must be a private key that is not retired`},
+		{"first key public only", "a,RS256," + keys.rsaPublic, `first key "a" must be a private key`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := Load(tt.spec, time.Hour)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ks.ActiveKID() != "a" {
+					t.Errorf("active kid %q, want a", ks.ActiveKID())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package mail
+
+/*
+=== PLUGGABLE MAIL SENDER ===
+
+Handlers never talk to a mail server. They build a Message and hand it to the
+worker pool (job "send_email"), which calls mail.Default.Send.
+
+  Sender (interface)   Send(ctx, Message) error
+  ├── LogSender        writes the mail to the log         (default, local dev)
+  └── FileSender       writes one .eml file per mail      (local testing: open it, click the link)
+
+A real provider (SMTP, SES, Postmark...) is one more type with a Send method;
+nothing else changes. Same idea as the repositories: depend on the interface.
+
+NEVER use LogSender in production: reset links in logs = account takeover for
+anyone who can read the logs.
+*/
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is one plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers a Message
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the sender the "send_email" job uses, overwritten by main.go from config
+var Default Sender = LogSender{}
+
+// NewSender returns the sender for MAIL_SENDER: "log" or "file" (dir = MAIL_DIR)
+func NewSender(kind string, dir string) (Sender, error) {
+	switch kind {
+	case "log":
+		return LogSender{}, nil
+	case "file":
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("mail dir %s: %w", dir, err)
+		}
+		return FileSender{Dir: dir}, nil
+	}
+	return nil, fmt.Errorf("MAIL_SENDER must be \"log\" or \"file\", got %q", kind)
+}
+
+// LogSender logs every mail instead of sending it
+type LogSender struct{}
+
+// Send writes the whole mail to the log at Info level
+func (LogSender) Send(ctx context.Context, msg Message) error {
+	slog.Info("Mail (not sent, MAIL_SENDER=log)", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileSender writes every mail as an .eml file into Dir (most mail clients open them)
+type FileSender struct {
+	Dir string
+}
+
+// Send writes <time>-<recipient>.eml
+func (s FileSender) Send(ctx context.Context, msg Message) error {
+	now := time.Now().UTC()
+	// Recipient in the name makes the folder easy to scan; strip anything path-like
+	safeTo := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000"), safeTo)
+
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		msg.To, msg.Subject, now.Format(time.RFC1123Z), msg.Body)
+
+	path := filepath.Join(s.Dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return err
+	}
+	slog.Debug("Mail written to file", "to", msg.To, "path", path)
+	return nil
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // =============================================================================
 // DATA MODELS - Struct definitions only, NO database logic
@@ -11,12 +14,27 @@ import "time"
 
 // Entry represents a mood/activity log entry
 type Entry struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Text      string     `json:"text"`
+	Mood      int        `json:"mood"`
+	Category  string     `json:"category"`
+	Tags      []string   `json:"tags"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // set only for entries in the trash
+}
+
+// EntryRevision is an earlier version of an entry, saved when it was edited or deleted
+type EntryRevision struct {
 	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
+	EntryID   int64     `json:"entry_id"`
 	Text      string    `json:"text"`
 	Mood      int       `json:"mood"`
 	Category  string    `json:"category"`
-	CreatedAt time.Time `json:"created_at"`
+	Tags      []string  `json:"tags"`
+	Action    string    `json:"action"`     // what replaced this version: "update" or "delete"
+	RequestID string    `json:"request_id"` // request that made the change (see RequestIDMiddleware)
+	ChangedAt time.Time `json:"changed_at"` // when this version stopped being the current one
 }
 
 // User represents a registered user account
@@ -25,8 +43,133 @@ type User struct {
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"` // "-" means NEVER include in JSON (security!)
 	CreatedAt    time.Time `json:"created_at"`
+
+	// EmailVerifiedAt is set by GET /verify, nil until the user clicked the link
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	Role       string     `json:"role"`                  // RoleUser or RoleAdmin, copied into the JWT
+	DisabledAt *time.Time `json:"disabled_at,omitempty"` // set by an admin, nil = account active
+
+	// Profile and preferences, changed with PATCH /me
+	DisplayName string         `json:"display_name"`
+	Timezone    string         `json:"timezone"`    // IANA name ("Europe/Berlin"): analytics count days in it
+	WeekStart   string         `json:"week_start"`  // "monday" … "sunday": first day of a week in analytics
+	MoodLabels  map[int]string `json:"mood_labels"` // mood value → what it means to the user, e.g. 10: "great"
+}
+
+// Roles a user can have (users.role, "role" claim in the access token)
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// APIKey is a personal API key (the key itself is only shown once, at creation)
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // first characters of the key, to tell keys apart
+	Scopes     []string   `json:"scopes"` // e.g. "entries:read", see handlers/apikeys.go
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
 }
 
+// UserStats counts accounts for GET /admin/stats
+type UserStats struct {
+	Total    int `json:"total"`
+	Admins   int `json:"admins"`
+	Disabled int `json:"disabled"`
+	Verified int `json:"verified"` // email confirmed
+}
+
+// EntryStats counts entries of ALL users for GET /admin/stats
+type EntryStats struct {
+	Total          int `json:"total"`    // not in the trash
+	InTrash        int `json:"in_trash"` // deleted, still restorable
+	CreatedLast24h int `json:"created_last_24h"`
+}
+
+// MoodBucket is one row of the mood trend report (a day, week or month)
+type MoodBucket struct {
+	PeriodStart string  `json:"period_start"` // first day of the bucket, YYYY-MM-DD
+	Count       int     `json:"count"`
+	AvgMood     float64 `json:"avg_mood"`
+	MinMood     int     `json:"min_mood"`
+	MaxMood     int     `json:"max_mood"`
+}
+
+// MoodSummary aggregates mood over the whole requested date range
+type MoodSummary struct {
+	Count   int     `json:"count"`
+	AvgMood float64 `json:"avg_mood"`
+	MinMood int     `json:"min_mood"`
+	MaxMood int     `json:"max_mood"`
+}
+
+// CategoryStats is mood statistics for a single category
+// DiffFromOverall is AvgMood minus the user's overall average (+1.8 = "better than usual")
+type CategoryStats struct {
+	Category        string  `json:"category"`
+	Count           int     `json:"count"`
+	AvgMood         float64 `json:"avg_mood"`
+	StdDevMood      float64 `json:"stddev_mood"`
+	DiffFromOverall float64 `json:"diff_from_overall"`
+}
+
+// CategoryReport is the per-category breakdown plus the overall numbers it is compared to
+type CategoryReport struct {
+	Count      int             `json:"count"`
+	AvgMood    float64         `json:"avg_mood"`
+	StdDevMood float64         `json:"stddev_mood"`
+	Categories []CategoryStats `json:"categories"`
+}
+
+// SearchResult is an entry matched by full-text search
+// Embedding Entry flattens its fields into the JSON next to snippet/score
+type SearchResult struct {
+	Entry
+	Snippet string  `json:"snippet"` // matched text with <mark>…</mark> around hits
+	Score   float64 `json:"score"`   // relevance, higher = better match
+}
+
+// TagCount is a tag name with how many of the user's entries use it
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Job is a background job in the durable queue (db/jobs.go, worker package)
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`              // what to do: "send_email", "entry_created" ...
+	Payload     json.RawMessage `json:"payload,omitempty"` // what AddJob got, as JSON
+	Status      string          `json:"status"`            // JobPending, JobRunning, JobSucceeded or JobFailed
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	RunAt       time.Time       `json:"run_at"` // not started before (retry backoff)
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	Errors      []JobError      `json:"errors,omitempty"` // every failed attempt, oldest first (only filled by JobRepository.Get)
+}
+
+// JobError is one failed attempt of a job (table job_errors)
+type JobError struct {
+	Attempt  int       `json:"attempt"`
+	Error    string    `json:"error"`
+	Worker   string    `json:"worker"` // which server / worker ran the attempt
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Job states: pending → running → succeeded, or back to pending for a retry, or failed
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
 // =============================================================================
 // WHY THIS STRUCTURE?
 // =============================================================================
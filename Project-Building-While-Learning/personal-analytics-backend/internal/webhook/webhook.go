@@ -2,12 +2,14 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 )
 
-func Send(url string, payload interface{}) error {
+// Send POSTs payload as JSON to url; ctx cancels the request (the job's timeout)
+func Send(ctx context.Context, url string, payload interface{}) error {
 	data, err := json.Marshal(payload)
 
 	if err != nil {
@@ -16,7 +18,13 @@ func Send(url string, payload interface{}) error {
 
 	body := bytes.NewReader(data)
 
-	resp, err := http.Post(url, "application/json", body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return fmt.Errorf("Failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
 
 	if err != nil {
 		return fmt.Errorf("http post failed: %w", err)
@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+=== CRON EXPRESSIONS ===
+
+The classic 5 fields, always in UTC (servers in different time zones must agree
+on when "3 a.m." is):
+
+  ┌──────── minute        0-59
+  │ ┌────── hour          0-23
+  │ │ ┌──── day of month  1-31
+  │ │ │ ┌── month         1-12
+  │ │ │ │ ┌ day of week   0-7 (0 and 7 are Sunday)
+  * * * * *
+
+Each field: * (any), 5, 1-5, a list 1,15 and steps: 0-30/10, or * with /15
+for every 15th. Numbers only - no JAN or MON.
+
+Like every cron since Vixie cron: when BOTH day fields are restricted, a day
+matches if EITHER does ("0 0 1 * 1" = the 1st of the month and every Monday).
+
+Shortcuts:
+  @yearly (@annually)  0 0 1 1 *
+  @monthly             0 0 1 * *
+  @weekly              0 0 * * 0
+  @daily (@midnight)   0 0 * * *
+  @hourly              0 * * * *
+  @every 15m           every 15 minutes, on the clock (:00, :15, :30, :45)
+
+Each field is a bitset: bit n set = value n allowed. Checking a minute is one AND.
+*/
+
+// cronSchedule is a parsed cron expression
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool          // the day field started with * (see dayMatches)
+	every                         time.Duration // @every; the fields are unused then
+}
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a cron expression or shortcut (see above)
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %v", spec, err)
+		}
+		// Next runs are stored to the second (storedTimestamp)
+		if every < time.Second || every%time.Second != 0 {
+			return nil, fmt.Errorf("cron %q: @every needs whole seconds", spec)
+		}
+		return &cronSchedule{every: every}, nil
+	}
+	if expanded, ok := cronShortcuts[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", spec, len(fields))
+	}
+
+	s := &cronSchedule{
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("cron %q: minute: %v", spec, err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("cron %q: hour: %v", spec, err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("cron %q: day of month: %v", spec, err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("cron %q: month: %v", spec, err)
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("cron %q: day of week: %v", spec, err)
+	}
+	// 7 is Sunday too; time.Weekday only knows 0
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 << 0
+	}
+	return s, nil
+}
+
+// parseCronField turns one field ("1-5", "0,30", "0-30/10") into a bitset of the values in [lo, hi]
+func parseCronField(field string, lo int, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step %q", part)
+			}
+			step = n
+		}
+
+		first, last := lo, hi
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var errA, errB error
+			first, errA = strconv.Atoi(a)
+			last, errB = strconv.Atoi(b)
+			if errA != nil || errB != nil || first > last {
+				return 0, fmt.Errorf("bad range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			first = n
+			if !hasStep {
+				last = n // "5" is 5; "5/10" is 5, 15, 25 ... like in other crons
+			}
+		}
+		if first < lo || last > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time after t the schedule fires, or the zero time if
+// it never does ("0 0 30 2 *" - February 30th)
+func (s *cronSchedule) next(t time.Time) time.Time {
+	if s.every > 0 {
+		// On the clock, not "every from whenever the server started": every
+		// server computes the same times
+		return t.UTC().Truncate(s.every).Add(s.every)
+	}
+
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Any valid expression fires within 4 years (February 29th); give up after 5
+	limit := t.AddDate(5, 0, 0)
+
+	// Skip whole months, days and hours that can't match instead of trying every minute
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches checks both day fields: either one when both are restricted, otherwise both
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
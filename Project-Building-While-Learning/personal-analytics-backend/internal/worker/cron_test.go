@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+// bits builds the bitset of the given values
+func bits(values ...int) uint64 {
+	var b uint64
+	for _, v := range values {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field   string
+		lo, hi  int
+		want    uint64
+		wantErr bool
+	}{
+		{field: "*", lo: 0, hi: 59, want: 1<<60 - 1},
+		{field: "*", lo: 1, hi: 12, want: 1<<13 - 2},
+		{field: "5", lo: 0, hi: 59, want: bits(5)},
+		{field: "1-3", lo: 0, hi: 59, want: bits(1, 2, 3)},
+		{field: "0,30", lo: 0, hi: 59, want: bits(0, 30)},
+		{field: "0-30/10", lo: 0, hi: 59, want: bits(0, 10, 20, 30)},
+		{field: "*/20", lo: 0, hi: 59, want: bits(0, 20, 40)},
+		{field: "5/20", lo: 0, hi: 59, want: bits(5, 25, 45)},
+		{field: "*/10", lo: 1, hi: 31, want: bits(1, 11, 21, 31)},
+		{field: "1,10-12,20/5", lo: 0, hi: 23, want: bits(1, 10, 11, 12, 20)},
+		{field: "60", lo: 0, hi: 59, wantErr: true},
+		{field: "0", lo: 1, hi: 31, wantErr: true},
+		{field: "5-1", lo: 0, hi: 59, wantErr: true},
+		{field: "*/0", lo: 0, hi: 59, wantErr: true},
+		{field: "1-", lo: 0, hi: 59, wantErr: true},
+		{field: "MON", lo: 0, hi: 7, wantErr: true},
+		{field: "", lo: 0, hi: 59, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			got, err := parseCronField(tt.field, tt.lo, tt.hi)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("bits %b, want %b", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+		check   func(s *cronSchedule) bool
+	}{
+		{spec: "0 3 * * *", check: func(s *cronSchedule) bool {
+			return s.minute == bits(0) && s.hour == bits(3) && s.domStar && s.dowStar
+		}},
+		{spec: "@daily", check: func(s *cronSchedule) bool { return s.minute == bits(0) && s.hour == bits(0) }},
+		{spec: "  @hourly  ", check: func(s *cronSchedule) bool { return s.minute == bits(0) && s.hour == 1<<24-1 }},
+		{spec: "0 0 * * 7", check: func(s *cronSchedule) bool { return s.dow == bits(0, 7) }},
+		{spec: "0 0 */2 * 1", check: func(s *cronSchedule) bool { return s.domStar && !s.dowStar }},
+		{spec: "@every 15m", check: func(s *cronSchedule) bool { return s.every == 15*time.Minute }},
+		{spec: "@every 90s", check: func(s *cronSchedule) bool { return s.every == 90*time.Second }},
+		{spec: "* * * *", wantErr: true},
+		{spec: "* * * * * *", wantErr: true},
+		{spec: "@reboot", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "0 0 0 * *", wantErr: true},
+		{spec: "0 0 * 13 *", wantErr: true},
+		{spec: "0 0 * * 8", wantErr: true},
+		{spec: "@every 500ms", wantErr: true},
+		{spec: "@every 1.5s", wantErr: true},
+		{spec: "@every soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := parseCron(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.check != nil && !tt.check(s) {
+				t.Errorf("parsed %+v", s)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// A Monday, between two minutes
+	from := time.Date(2026, 1, 12, 10, 7, 30, 0, time.UTC)
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time // zero: never
+	}{
+		{"every minute", "* * * * *", from, at(2026, 1, 12, 10, 8)},
+		{"every 15 minutes", "*/15 * * * *", from, at(2026, 1, 12, 10, 15)},
+		{"strictly after from", "*/15 * * * *", at(2026, 1, 12, 10, 15), at(2026, 1, 12, 10, 30)},
+		{"later today", "30 22 * * *", from, at(2026, 1, 12, 22, 30)},
+		{"tomorrow", "0 3 * * *", from, at(2026, 1, 13, 3, 0)},
+		{"first of next month", "0 0 1 * *", from, at(2026, 2, 1, 0, 0)},
+		{"next year", "@yearly", from, at(2027, 1, 1, 0, 0)},
+		{"Sunday as 0", "0 0 * * 0", from, at(2026, 1, 18, 0, 0)},
+		{"Sunday as 7", "0 0 * * 7", from, at(2026, 1, 18, 0, 0)},
+		{"weekdays", "0 9 * * 1-5", from, at(2026, 1, 13, 9, 0)},
+		{"both days restricted: the day of month comes first", "0 0 13 * 5", from, at(2026, 1, 13, 0, 0)},
+		{"both days restricted: the weekday comes first", "0 0 20 * 3", from, at(2026, 1, 14, 0, 0)},
+		{"day of month starting with *: both must match", "0 0 */10 * 1", from, at(2026, 5, 11, 0, 0)},
+		{"leap day", "0 12 29 2 *", from, at(2028, 2, 29, 12, 0)},
+		{"February 30th never comes", "0 0 30 2 *", from, time.Time{}},
+		{"@every on the clock", "@every 15m", from, at(2026, 1, 12, 10, 15)},
+		{"@every strictly after from", "@every 1h", at(2026, 1, 12, 11, 0), at(2026, 1, 12, 12, 0)},
+		{"from in another time zone", "0 3 * * *", from.In(time.FixedZone("UTC+14", 14*3600)), at(2026, 1, 13, 3, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
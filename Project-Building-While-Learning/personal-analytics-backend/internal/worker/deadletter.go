@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
+	"time"
+)
+
+/*
+=== DEAD-LETTER QUEUE ===
+
+A job that failed MaxAttempts times (or with errPermanent) used to be a line
+in the log and nothing more: a password reset mail that never went out was
+gone for good. Now finishJob marks it failed and it stays in the jobs table,
+payload and every error included (see db/jobs.go):
+
+  send_email fails    attempt 1: "dial tcp: connection refused"   → retry in 10s
+                      attempt 2: "dial tcp: connection refused"   → retry in 20s
+                      ...
+                      attempt 5: "circuit breaker is open"        → dead-letter queue
+
+Once the mail provider works again an admin replays it (GET/POST
+/admin/jobs/failed, handlers/admin.go): the job is pending again with
+MaxAttempts fresh tries. Jobs that can never work (a payload for a type that
+no longer exists) are discarded instead.
+
+Nothing replays automatically: a job that failed five times in a row usually
+needs a human to fix something first, or it just fails five more times.
+*/
+
+// DeadLetters returns one page of failed jobs, most recently failed first, and how many there are
+func DeadLetters(ctx context.Context, page, limit int) ([]models.Job, int, error) {
+	if Store == nil {
+		return []models.Job{}, 0, nil
+	}
+	return Store.ListFailed(ctx, page, limit)
+}
+
+// DeadLetter returns a failed job with its error history, or db.ErrNotFound
+func DeadLetter(ctx context.Context, jobID int64) (models.Job, error) {
+	if Store == nil {
+		return models.Job{}, db.ErrNotFound
+	}
+	job, err := Store.Get(ctx, jobID)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if job.Status != models.JobFailed {
+		return models.Job{}, db.ErrNotFound
+	}
+	return job, nil
+}
+
+// Replay puts a failed job back into the queue to run now, or returns db.ErrNotFound
+func Replay(ctx context.Context, jobID int64) error {
+	if Store == nil {
+		return db.ErrNotFound
+	}
+	if err := Store.Replay(ctx, jobID, time.Now()); err != nil {
+		return err
+	}
+	slog.Info("Job replayed from the dead-letter queue", "job_id", jobID)
+	wakeWorker()
+	return nil
+}
+
+// Discard deletes a failed job for good, or returns db.ErrNotFound
+func Discard(ctx context.Context, jobID int64) error {
+	if Store == nil {
+		return db.ErrNotFound
+	}
+	return Store.Discard(ctx, jobID)
+}
@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+)
+
+/*
+=== JOB HANDLER REGISTRY ===
+
+processJob used to be one big switch over job.Type: every new kind of job
+meant editing the worker package, and a typo in a job type was only noticed
+when a worker picked the job up (and failed it). Now the code that owns a job
+type registers it once, at startup, with the Go type of its payload:
+
+  worker.Register("send_email", worker.Options{Concurrency: 2, Timeout: 30 * time.Second},
+      func(ctx context.Context, job worker.Job, msg mail.Message) error {
+          return mail.Default.Send(ctx, msg)
+      })
+
+  worker.AddJob("send_email", mail.Message{...})   // ok
+  worker.AddJob("send_emial", mail.Message{...})   // error: unknown job type
+  worker.AddJob("send_email", map[string]any{...}) // error: payload must be mail.Message
+
+The payload still travels as JSON through the jobs table; the registry decodes
+it back into the registered type before calling the handler. A payload that no
+longer decodes can't be fixed by retrying, so the job fails for good.
+
+=== PER-TYPE OPTIONS ===
+
+  Concurrency   at most N jobs of this type at once on this server. Workers
+                don't claim a type that is at its limit, so a slow mail
+                provider can't occupy every worker.
+  Timeout       the handler's ctx is cancelled after it and the attempt counts
+                as failed. Keep it below JOB_VISIBILITY_TIMEOUT, or another
+                worker takes the job over while it is still running.
+  MaxAttempts   tries before the dead-letter queue (stored with the job at AddJob)
+  Backoff       wait before the 2nd attempt, doubling up to MaxBackoff
+
+Zero values mean the pool-wide defaults (MaxAttempts, retryBackoff ...).
+
+Register panics on a duplicate type, like http.HandleFunc on a duplicate
+pattern: that is a programming error, found at startup.
+*/
+
+// Options are the per-type settings of a job handler; zero values use the defaults
+type Options struct {
+	Concurrency int           // jobs of this type running at once on this server (0: no limit besides the pool size)
+	Timeout     time.Duration // per attempt (0: defaultJobTimeout)
+	MaxAttempts int           // tries before the dead-letter queue (0: MaxAttempts)
+	Backoff     time.Duration // wait before the 2nd attempt, doubling after each failure (0: retryBackoff)
+	MaxBackoff  time.Duration // longest wait between attempts (0: maxRetryBackoff)
+}
+
+// HandlerFunc runs one attempt of a job with its payload decoded.
+// Return nil when done, an error to retry later, Permanent(err) to give up.
+type HandlerFunc[T any] func(ctx context.Context, job Job, payload T) error
+
+// ErrUnknownJobType is returned by AddJob for a type nobody registered
+var ErrUnknownJobType = errors.New("unknown job type")
+
+// defaultJobTimeout is the Timeout of handlers that don't set one
+const defaultJobTimeout = time.Minute
+
+// jobHandler is a registered type with its payload type erased
+type jobHandler struct {
+	opts        Options
+	payloadType reflect.Type
+	slots       chan struct{} // one per running job; nil without a Concurrency limit
+	run         func(ctx context.Context, job Job) error
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*jobHandler{}
+)
+
+// Register makes jobType known to AddJob and the workers. AddJob then only
+// accepts payloads of type T for it, and handle gets them back decoded.
+// Call it at startup, before WorkerPool.Start.
+func Register[T any](jobType string, opts Options, handle HandlerFunc[T]) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[jobType]; exists {
+		panic("worker: job type " + jobType + " registered twice")
+	}
+
+	h := &jobHandler{
+		opts:        opts,
+		payloadType: reflect.TypeFor[T](),
+		run: func(ctx context.Context, job Job) error {
+			var payload T
+			if err := json.Unmarshal(job.Payload, &payload); err != nil {
+				return Permanent(fmt.Errorf("%s payload: %v", job.Type, err))
+			}
+			return handle(ctx, job, payload)
+		},
+	}
+	if opts.Concurrency > 0 {
+		h.slots = make(chan struct{}, opts.Concurrency)
+	}
+	registry[jobType] = h
+}
+
+// Permanent marks a handler error retrying can't fix: the job goes to the
+// dead-letter queue at once instead of after MaxAttempts
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", errPermanent, err)
+}
+
+// lookupHandler returns the handler of jobType, or nil
+func lookupHandler(jobType string) *jobHandler {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[jobType]
+}
+
+// busyTypes are the job types at their Concurrency limit right now; workers don't claim them
+func busyTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var busy []string
+	for jobType, h := range registry {
+		if h.slots != nil && len(h.slots) == cap(h.slots) {
+			busy = append(busy, jobType)
+		}
+	}
+	return busy
+}
+
+// checkTimeouts warns about handlers that may outlive their claim
+func checkTimeouts() {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for jobType, h := range registry {
+		if h.timeout() >= VisibilityTimeout {
+			slog.Warn("Job timeout not below the visibility timeout, a slow job may run twice at once",
+				"job_type", jobType, "timeout_seconds", h.timeout().Seconds(),
+				"visibility_timeout_seconds", VisibilityTimeout.Seconds())
+		}
+	}
+}
+
+// accepts reports whether payload has the registered type
+func (h *jobHandler) accepts(payload interface{}) bool {
+	return payload != nil && reflect.TypeOf(payload) == h.payloadType
+}
+
+func (h *jobHandler) timeout() time.Duration {
+	if h.opts.Timeout > 0 {
+		return h.opts.Timeout
+	}
+	return defaultJobTimeout
+}
+
+func (h *jobHandler) maxAttempts() int {
+	if h.opts.MaxAttempts > 0 {
+		return h.opts.MaxAttempts
+	}
+	return MaxAttempts
+}
+
+// backoff is the wait after the given failed attempt: Backoff, 2×, 4× ... up to MaxBackoff
+// (the defaults for a nil handler)
+func (h *jobHandler) backoff(attempt int) time.Duration {
+	first, limit := retryBackoff, maxRetryBackoff
+	if h != nil && h.opts.Backoff > 0 {
+		first = h.opts.Backoff
+	}
+	if h != nil && h.opts.MaxBackoff > 0 {
+		limit = h.opts.MaxBackoff
+	}
+	// Past 2^20 × first the limit has long been reached (and the shift would overflow)
+	if attempt > 20 {
+		return limit
+	}
+	return min(first<<(attempt-1), limit)
+}
+
+// execute runs one attempt with the handler's concurrency limit and timeout.
+// A panic becomes an error: one bad job must not kill the server.
+func (h *jobHandler) execute(job Job) error {
+	// Normally free: the worker didn't claim a busy type. Another worker may
+	// have taken the last slot in between, then this waits for it.
+	if h.slots != nil {
+		h.slots <- struct{}{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout())
+	defer cancel()
+
+	done := make(chan error, 1) // buffered: a timed-out handler can still finish and leave
+	go func() {
+		// The slot is freed when the handler really returns, so a handler that
+		// ignores ctx still counts against the limit
+		defer func() {
+			if h.slots != nil {
+				<-h.slots
+			}
+		}()
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- h.run(ctx, job)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", h.timeout())
+	}
+}
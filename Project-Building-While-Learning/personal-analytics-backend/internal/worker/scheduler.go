@@ -0,0 +1,228 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"personal-analytics-backend/internal/db"
+)
+
+/*
+=== SCHEDULER (RECURRING JOBS) ===
+
+Periodic work used to be a goroutine with a ticker per task (the trash purger,
+the pool's hourly cleanup). Fine with one server - with three, every purge ran
+three times, and a daily digest mail would arrive three times.
+
+The scheduler doesn't run anything itself. It only decides WHEN a job is due
+and hands it to the queue with AddJobAt; the worker pool runs it with the
+usual retries, timeouts and dead-letter queue:
+
+  scheduler := worker.NewScheduler(db.NewSQLScheduleRepository(conn))
+  scheduler.Add("purge_trash", "@every 1h", "purge_trash", PurgeJob{})
+  scheduler.Add("weekly_digest", "0 8 * * 1", "send_digest", DigestJob{}) // Mondays 08:00 UTC
+  scheduler.Start(context.Background())
+  ...
+  scheduler.Stop() // on shutdown, before the worker pool
+
+ONE SERVER FIRES (db/schedules.go):
+Every server runs a scheduler; every schedulerInterval each one tries to take
+(or renew) the "scheduler" lease. Only the holder looks at the schedules. For
+each one whose stored next run has come, it moves the next run forward with a
+compare-and-set and, only if that worked, queues the job. If the holder dies,
+its lease expires after leaseTTL and another server carries on.
+
+Why the lease is in the database and not in Redis: see worker.go.
+
+MISSED RUNS: a server that was down for a day fires a daily schedule once when
+it comes back, not once per missed day - a purge or a digest doesn't need
+repeating.
+
+TRADE-OFFS:
+- Precision: a job is queued up to schedulerInterval late (fine for hourly work)
+- At-most-once per tick: if the holder crashes between the compare-and-set and
+  the INSERT, that tick is skipped. The next tick comes; the queue takes over
+  from there (at-least-once, like every job).
+- A changed spec applies after the stored next run has fired once
+*/
+
+const (
+	leaseName         = "scheduler"
+	schedulerInterval = 10 * time.Second // how often the holder checks the schedules (and renews the lease)
+	leaseTTL          = 30 * time.Second // a dead holder is replaced after this: 3 missed renewals
+)
+
+// schedulerSeq tells schedulers of one process apart (tests run several)
+var schedulerSeq atomic.Int64
+
+// Scheduler queues jobs on cron schedules, on one server at a time
+type Scheduler struct {
+	repo   db.ScheduleRepository
+	holder string // lease holder: unique across servers
+
+	mu        sync.Mutex
+	schedules []schedule
+
+	stop   context.CancelFunc
+	done   chan struct{}
+	leader bool // holds the lease (only touched by the loop goroutine)
+}
+
+// schedule is one Add call
+type schedule struct {
+	name    string
+	spec    string
+	cron    *cronSchedule
+	jobType string
+	payload interface{}
+}
+
+// NewScheduler creates a scheduler on repo; nothing runs before Start
+func NewScheduler(repo db.ScheduleRepository) *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		repo:   repo,
+		holder: fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), schedulerSeq.Add(1)),
+	}
+}
+
+// Add queues the job jobType with payload on every run of spec (see cron.go).
+// name identifies the schedule across servers and restarts: keep it when the
+// spec changes. The checks are AddJob's, done now instead of at 3 a.m.
+func (s *Scheduler) Add(name string, spec string, jobType string, payload interface{}) error {
+	c, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+	if c.next(time.Now()).IsZero() {
+		return fmt.Errorf("cron %q never fires", spec)
+	}
+	h := lookupHandler(jobType)
+	if h == nil {
+		return fmt.Errorf("schedule %q: %w %q", name, ErrUnknownJobType, jobType)
+	}
+	if !h.accepts(payload) {
+		return fmt.Errorf("schedule %q: payload is %T, want %s", name, payload, h.payloadType)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.schedules {
+		if existing.name == name {
+			return fmt.Errorf("schedule %q added twice", name)
+		}
+	}
+	s.schedules = append(s.schedules, schedule{name: name, spec: spec, cron: c, jobType: jobType, payload: payload})
+	return nil
+}
+
+// Start checks the schedules now and then every schedulerInterval until Stop. Call once.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.stop = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.loop(ctx)
+
+	s.mu.Lock()
+	slog.Info("Scheduler started", "schedules", len(s.schedules), "holder", s.holder)
+	s.mu.Unlock()
+}
+
+// Stop ends the loop and releases the lease, so another server takes over
+// without waiting for it to expire
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return // never started
+	}
+	s.stop()
+	<-s.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.repo.ReleaseLease(ctx, leaseName, s.holder); err != nil {
+		slog.Error("Failed to release scheduler lease", "error", err)
+	}
+	slog.Info("Scheduler stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tick renews the lease and, while holding it, fires what is due
+func (s *Scheduler) tick(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, schedulerInterval)
+	defer cancel()
+
+	now := time.Now()
+	held, err := s.repo.AcquireLease(ctx, leaseName, s.holder, now, now.Add(leaseTTL))
+	if err != nil {
+		// Can't tell whether we still hold it: stay out, the lease runs out on its own
+		slog.Error("Failed to renew scheduler lease", "error", err)
+		held = false
+	}
+	if held != s.leader {
+		s.leader = held
+		if held {
+			slog.Info("Scheduler lease acquired, this server fires the schedules", "holder", s.holder)
+		} else {
+			slog.Warn("Scheduler lease lost", "holder", s.holder)
+		}
+	}
+	if !held {
+		return
+	}
+
+	s.mu.Lock()
+	schedules := append([]schedule(nil), s.schedules...)
+	s.mu.Unlock()
+
+	for _, sc := range schedules {
+		if err := s.fire(ctx, sc, now); err != nil {
+			slog.Error("Schedule failed", "schedule", sc.name, "error", err)
+		}
+	}
+}
+
+// fire queues sc's job if its next run has come and moves the next run forward
+func (s *Scheduler) fire(ctx context.Context, sc schedule, now time.Time) error {
+	due, err := s.repo.NextRun(ctx, sc.name)
+	if errors.Is(err, db.ErrNotFound) {
+		// New schedule: its first run is the next one from now, not right away
+		// (a deploy shouldn't trigger every daily job)
+		_, err = s.repo.SetNextRun(ctx, sc.name, time.Time{}, sc.cron.next(now))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if now.Before(due) {
+		return nil
+	}
+
+	// Claim this run: whoever moves next_run_at from `due` fires it
+	claimed, err := s.repo.SetNextRun(ctx, sc.name, due, sc.cron.next(now))
+	if err != nil || !claimed {
+		return err
+	}
+
+	slog.Info("Schedule fired", "schedule", sc.name, "spec", sc.spec, "job_type", sc.jobType, "due", due)
+	return AddJobAt(due, sc.jobType, sc.payload)
+}
@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"personal-analytics-backend/internal/db"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testJobType = "scheduler_test"
+
+// Register panics on a second call (go test -count=2 runs the tests again)
+var registerTestJob sync.Once
+
+// staleView is a ScheduleRepository whose NextRun returns an old value: a
+// server that read next_run_at just before another one moved it
+type staleView struct {
+	db.ScheduleRepository
+	due time.Time
+}
+
+func (s staleView) NextRun(ctx context.Context, name string) (time.Time, error) {
+	return s.due, nil
+}
+
+func TestSchedulerFiresOnce(t *testing.T) {
+	registerTestJob.Do(func() {
+		Register(testJobType, Options{}, func(ctx context.Context, job Job, payload struct{}) error { return nil })
+	})
+	jobs := db.NewMemoryJobRepository()
+	previousStore := Store
+	Store = jobs
+	defer func() { Store = previousStore }()
+
+	ctx := context.Background()
+	repo := db.NewMemoryScheduleRepository()
+	a, b := NewScheduler(repo), NewScheduler(repo)
+	for _, s := range []*Scheduler{a, b} {
+		if err := s.Add("hourly", "@hourly", testJobType, struct{}{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queued := func() int {
+		counts, err := jobs.Counts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return counts["pending"]
+	}
+	// movePast sets the stored next run an hour back, as if it had come
+	movePast := func() time.Time {
+		t.Helper()
+		next, err := repo.NextRun(ctx, "hourly")
+		if err != nil {
+			t.Fatal(err)
+		}
+		past := time.Now().Add(-time.Hour).Truncate(time.Second)
+		if ok, err := repo.SetNextRun(ctx, "hourly", next, past); err != nil || !ok {
+			t.Fatalf("move next run: %v (%v)", ok, err)
+		}
+		return past
+	}
+
+	steps := []struct {
+		name   string
+		run    func()
+		leader *Scheduler // holds the lease afterwards
+		queued int        // jobs in the queue afterwards
+	}{
+		{"first tick takes the lease and only stores the next run", func() { a.tick(ctx) }, a, 0},
+		{"the other server stays out", func() { b.tick(ctx) }, a, 0},
+		{"a due run is queued by the holder", func() { movePast(); a.tick(ctx) }, a, 1},
+		{"and not again on the next tick", func() { a.tick(ctx) }, a, 1},
+		{"a due run is ignored by the other server", func() { movePast(); b.tick(ctx) }, a, 1},
+		{"the holder fires it", func() { a.tick(ctx) }, a, 2},
+		{"a second holder with a stale next run loses the compare-and-set", func() {
+			due := movePast()
+			a.tick(ctx)
+			stale := &Scheduler{repo: staleView{repo, due}, holder: b.holder}
+			if err := stale.fire(ctx, b.schedules[0], time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}, a, 3},
+		{"released lease: the other server takes over", func() {
+			if err := repo.ReleaseLease(ctx, leaseName, a.holder); err != nil {
+				t.Fatal(err)
+			}
+			movePast()
+			b.tick(ctx)
+			a.tick(ctx) // finds out it lost the lease
+		}, b, 4},
+		{"the old holder stays out", func() { movePast(); a.tick(ctx) }, b, 4},
+	}
+	for _, s := range steps {
+		s.run()
+		if a.leader != (s.leader == a) || b.leader != (s.leader == b) {
+			t.Errorf("%s: leaders a=%v b=%v", s.name, a.leader, b.leader)
+		}
+		if got := queued(); got != s.queued {
+			t.Errorf("%s: %d jobs queued, want %d", s.name, got, s.queued)
+		}
+	}
+
+	// The queued job runs at the time that was due, not when the tick saw it
+	job, err := jobs.Claim(ctx, "test", time.Now(), time.Now().Add(time.Minute), nil)
+	if err != nil || job.Type != testJobType || !job.RunAt.Before(time.Now().Add(-30*time.Minute)) {
+		t.Errorf("queued job %s at %s (%v), want %s an hour ago", job.Type, job.RunAt, err, testJobType)
+	}
+}
@@ -1,10 +1,16 @@
 package worker
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
-	"personal-analytics-backend/internal/circuitbreaker"
-	"personal-analytics-backend/internal/retry"
-	"personal-analytics-backend/internal/webhook"
+	"os"
+	"personal-analytics-backend/internal/db"
+	"personal-analytics-backend/internal/models"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,76 +21,289 @@ import (
 // Job represents a background task to be processed
 // Think of it as an "order ticket" in a pizza shop
 type Job struct {
-	Type    string      // What kind of job? "entry_created", "email", etc.
-	Payload interface{} // The data for this job (entry ID, user ID, etc.)
+	ID      int64           // Row in the jobs table; a job can run twice (at-least-once), the ID tells repeats apart
+	Type    string          // What kind of job? "entry_created", "send_email", etc. (see Register)
+	Payload json.RawMessage // The data as stored; handlers get it decoded into their payload type
+	Attempt int             // 1 on the first try
 }
 
+// errPermanent marks job errors retrying can't fix (unknown type, broken payload):
+// the job is failed at once instead of after MaxAttempts (handlers use Permanent)
+var errPermanent = errors.New("permanent job error")
+
 // ========================================
 // WORKER POOL
 // ========================================
 
-// JobQueue is the channel where jobs are sent
-// Think of it as the "order counter" where tickets pile up
-var JobQueue chan Job
+// Store is the durable queue the jobs live in (db/jobs.go). Set by main.go before
+// WorkerPool.Start (same pattern as handlers.SigningKeys); if nil, Start uses an
+// in-memory queue that is gone on restart.
+var Store db.JobRepository
+
+// Queue settings, overwritten by main.go from config
+var (
+	MaxAttempts       = 5               // tries before a job is marked failed, unless its Options say otherwise
+	VisibilityTimeout = 2 * time.Minute // a running job not finished by then is given to another worker
+)
+
+const (
+	pollInterval       = time.Second      // idle workers look for due jobs this often (AddJob wakes one sooner)
+	retryBackoff       = 10 * time.Second // wait before the 2nd attempt, doubling after each failure (Options.Backoff)
+	maxRetryBackoff    = 10 * time.Minute // (Options.MaxBackoff)
+	succeededRetention = 24 * time.Hour   // succeeded jobs are deleted after this
+	expiredCheck       = 30 * time.Second // how often jobs whose last lease expired are failed (failExpired)
+)
 
-var WebhookBreaker = circuitbreaker.NewCircuitBreaker(5, 3*time.Second)
+// wake tells an idle worker a job was just added, so it doesn't wait for the next poll
+var wake = make(chan struct{}, 1)
+
+// WorkerPool is a fixed number of workers claiming jobs from Store.
+// main.go starts it, and on shutdown - after the HTTP server, whose last
+// requests may still add jobs, and the scheduler - stops it:
+//
+//	pool := worker.NewWorkerPool(cfg.WorkerPoolSize)
+//	pool.Start(context.Background())
+//	...
+//	dropped, err := pool.Shutdown(ctx) // ctx: cfg.ShutdownTimeout
+type WorkerPool struct {
+	numWorkers int
+	drain      bool // run every due job before stopping (in-memory Store only, see Shutdown)
+
+	ctx  context.Context    // cancelled by Shutdown (stop claiming)
+	stop context.CancelFunc // cancels ctx
+	halt chan struct{}      // closed when Shutdown gives up: stop even while draining
+	wg   sync.WaitGroup     // one per worker goroutine, plus failExpired
+
+	closing  atomic.Bool  // AddJob rejects jobs
+	inFlight atomic.Int64 // jobs being run right now
+	rejected atomic.Int64 // AddJob calls turned away while shutting down
+}
 
-// StartWorkerPool starts N workers that listen for jobs
-// Each worker is like a "chef" waiting for orders
-func StartWorkerPool(numWorkers int) {
-	// Create the job queue (buffered channel with capacity 100)
-	// Buffer = how many jobs can wait in line before blocking
-	JobQueue = make(chan Job, 100)
+// pool is the started pool AddJob checks (nil before Start)
+var pool atomic.Pointer[WorkerPool]
+
+// NewWorkerPool creates a pool of numWorkers workers; nothing runs before Start
+func NewWorkerPool(numWorkers int) *WorkerPool {
+	return &WorkerPool{numWorkers: numWorkers, halt: make(chan struct{})}
+}
+
+// Start starts the workers. They run until Shutdown (or until ctx is cancelled,
+// which stops them like Shutdown but without waiting). Call once.
+func (p *WorkerPool) Start(ctx context.Context) {
+	if Store == nil {
+		Store = db.NewMemoryJobRepository()
+	}
+	// In memory, whatever is still queued at exit is gone: finish it first.
+	// In the database it just waits for the next start (or another server).
+	_, p.drain = Store.(*db.MemoryJobRepository)
+
+	checkTimeouts()
+
+	p.ctx, p.stop = context.WithCancel(ctx)
+	pool.Store(p)
 
 	// Start the workers (each runs in its own goroutine)
-	for i := 1; i <= numWorkers; i++ {
-		go worker(i) // "go" = run in background
+	p.wg.Add(p.numWorkers + 1)
+	for i := 1; i <= p.numWorkers; i++ {
+		go p.worker(i) // "go" = run in background
 	}
+	go p.failExpired()
 
-	slog.Info("Worker pool started", "num_workers", numWorkers, "queue_capacity", 100)
+	slog.Info("Worker pool started", "num_workers", p.numWorkers, "max_attempts", MaxAttempts,
+		"visibility_timeout_seconds", VisibilityTimeout.Seconds())
 }
 
 // worker is a single worker that processes jobs from the queue
-// It runs forever, waiting for jobs
-func worker(id int) {
-	// This loop runs FOREVER (until program exits)
-	for job := range JobQueue {
-		// "range JobQueue" = wait for next job, then process it
-		// When JobQueue is closed, this loop exits
+// It runs until the pool stops: claim a due job, run it, report back, repeat
+func (p *WorkerPool) worker(id int) {
+	defer p.wg.Done()
+
+	// Unique across servers sharing the database: a claim belongs to exactly one worker
+	hostname, _ := os.Hostname()
+	workerID := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), id)
+
+	for {
+		select {
+		case <-p.halt:
+			return
+		default:
+		}
+		stopping := p.ctx.Err() != nil
+		if stopping && !p.drain {
+			return
+		}
+
+		now := time.Now()
+		// Types at their concurrency limit wait for a worker that is done with one (registry.go)
+		claimed, err := Store.Claim(context.Background(), workerID, now, now.Add(VisibilityTimeout), busyTypes())
+		if err != nil {
+			if stopping {
+				// Drained: nothing due anymore
+				return
+			}
+			if !errors.Is(err, db.ErrNotFound) {
+				slog.Error("Failed to claim job", "worker_id", id, "error", err)
+			}
+			// Nothing due (or the database is unhappy): sleep until woken, the next poll or shutdown
+			select {
+			case <-wake:
+			case <-time.After(pollInterval):
+			case <-p.ctx.Done():
+			}
+			continue
+		}
 
-		slog.Info("Worker processing job", "worker_id", id, "job_type", job.Type)
+		p.inFlight.Add(1)
+		job := Job{ID: claimed.ID, Type: claimed.Type, Payload: claimed.Payload, Attempt: claimed.Attempts}
+		slog.Info("Worker processing job", "worker_id", id, "job_type", job.Type, "job_id", job.ID, "attempt", job.Attempt)
 
-		// Process the job based on its type
-		processJob(job)
+		// Run the handler registered for the type
+		h := lookupHandler(job.Type)
+		err = runJob(h, job)
+		finishJob(workerID, claimed, h, err)
+		p.inFlight.Add(-1)
 
-		slog.Info("Worker completed job", "worker_id", id, "job_type", job.Type)
+		slog.Info("Worker completed job", "worker_id", id, "job_type", job.Type, "job_id", job.ID, "ok", err == nil)
 	}
 }
 
-// processJob handles different job types
-func processJob(job Job) {
-	switch job.Type {
-	case "entry_created":
-		// Simulate sending notification / updating analytics
-		// In real app: send email, update stats, notify webhooks, etc.
-		slog.Debug("Processing entry creation", "payload", job.Payload)
-		err := WebhookBreaker.Execute(func() error {
-			return retry.Do(3, 500*time.Millisecond, func() error {
-				return webhook.Send("https://webhook.site/11eccba7-a84d-4b58-b86e-68d56a5d7021", job)
-			})
-		})
+// failExpired moves jobs whose last attempt never reported back to the
+// dead-letter queue, every expiredCheck until the pool stops. A ticker and not
+// part of Claim: idle workers poll every second, this needs a write transaction.
+func (p *WorkerPool) failExpired() {
+	defer p.wg.Done()
 
+	ticker := time.NewTicker(expiredCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.ctx.Done():
+			return
+		}
+		ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
+		failed, err := Store.FailExpired(ctx, time.Now())
+		cancel()
 		if err != nil {
-			slog.Error(err.Error())
+			slog.Error("Failed to check for expired jobs", "error", err)
+		} else if failed > 0 {
+			slog.Error("Jobs moved to the dead-letter queue, lease expired on the last attempt", "jobs", failed)
 		}
+	}
+}
 
-	case "entry_deleted":
-		slog.Debug("Processing entry deletion", "payload", job.Payload)
-		time.Sleep(1 * time.Second)
+// runJob runs one attempt of job with its handler h.
+// An error means "try again later" (up to MaxAttempts), unless it wraps errPermanent.
+func runJob(h *jobHandler, job Job) error {
+	if h == nil {
+		// Added by a server that knew the type (older or newer version), or replayed
+		// from the dead-letter queue after the type was removed
+		slog.Warn("Unknown job type", "job_type", job.Type)
+		return fmt.Errorf("%w: unknown job type %q", errPermanent, job.Type)
+	}
+	return h.execute(job)
+}
 
+// finishJob reports the outcome to the queue: succeeded, retry later, or failed for good.
+// h (nil for an unknown type) has the retry policy.
+func finishJob(workerID string, job models.Job, h *jobHandler, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var storeErr error
+	switch {
+	case err == nil:
+		storeErr = Store.Succeed(ctx, job.ID, workerID, now)
+	case job.Attempts >= job.MaxAttempts || errors.Is(err, errPermanent):
+		// Not lost: it waits in the dead-letter queue for an admin (deadletter.go)
+		slog.Error("Job moved to the dead-letter queue", "job_type", job.Type, "job_id", job.ID, "attempts", job.Attempts, "error", err)
+		storeErr = Store.Fail(ctx, job.ID, workerID, err.Error(), now)
 	default:
-		slog.Warn("Unknown job type", "job_type", job.Type)
+		// 10s, 20s, 40s ... so a service that is down gets time to come back
+		backoff := h.backoff(job.Attempts)
+		slog.Warn("Job failed, will retry", "job_type", job.Type, "job_id", job.ID, "attempt", job.Attempts,
+			"retry_in_seconds", backoff.Seconds(), "error", err)
+		storeErr = Store.Retry(ctx, job.ID, workerID, err.Error(), now, now.Add(backoff))
+	}
+
+	if errors.Is(storeErr, db.ErrNotFound) {
+		// Took longer than VisibilityTimeout and another worker has it now
+		slog.Warn("Job was claimed by another worker meanwhile", "job_type", job.Type, "job_id", job.ID)
+	} else if storeErr != nil {
+		// The claim expires and the job runs again - at-least-once
+		slog.Error("Failed to record job result", "job_id", job.ID, "error", storeErr)
+	}
+}
+
+// Shutdown stops the pool: AddJob turns jobs away, workers stop claiming, and
+// Shutdown waits for the jobs they are running until ctx is done.
+//
+// With the in-memory Store the workers first run every job that is due (the
+// queue dies with the process); jobs waiting for a retry can't be hurried.
+// With the database nothing has to be drained - pending jobs are already
+// stored, and a job cut off at the deadline is claimed again after the
+// visibility timeout.
+//
+// dropped counts the jobs that are lost: turned away by AddJob, plus whatever
+// the in-memory Store still holds. err is ctx.Err() if the deadline cut jobs off.
+func (p *WorkerPool) Shutdown(ctx context.Context) (dropped int, err error) {
+	if p.stop == nil {
+		return 0, nil // never started
+	}
+	p.closing.Store(true)
+	p.stop()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	var interrupted int64
+	select {
+	case <-done:
+	case <-ctx.Done():
+		interrupted = p.inFlight.Load()
+		close(p.halt) // draining workers stop after their current job
+		err = ctx.Err()
+	}
+
+	dropped = int(p.rejected.Load())
+	if p.drain {
+		// A fresh context: ctx may be over, and counting memory doesn't block
+		counts, _ := Store.Counts(context.Background())
+		dropped += counts[models.JobPending] + counts[models.JobRunning]
+	}
+
+	if interrupted > 0 && !p.drain {
+		slog.Warn("Jobs still running at shutdown, they run again after the visibility timeout",
+			"jobs", interrupted, "visibility_timeout_seconds", VisibilityTimeout.Seconds())
 	}
+	if dropped > 0 {
+		slog.Warn("Worker pool stopped, jobs dropped", "dropped", dropped, "rejected", p.rejected.Load(), "in_memory", p.drain)
+	} else {
+		slog.Info("Worker pool stopped", "dropped", 0)
+	}
+	return dropped, err
+}
+
+// PurgeSucceeded deletes the succeeded jobs older than a day. Run as the
+// purge_jobs job once an hour (handlers/jobs.go), so with several servers
+// only one of them purges (scheduler.go).
+func PurgeSucceeded(ctx context.Context) (int64, error) {
+	if Store == nil {
+		return 0, nil
+	}
+	return Store.PurgeSucceeded(ctx, time.Now().Add(-succeededRetention))
+}
+
+// QueueCounts returns how many jobs are pending, running, succeeded and failed (GET /admin/stats)
+func QueueCounts(ctx context.Context) (map[string]int, error) {
+	if Store == nil {
+		return map[string]int{}, nil
+	}
+	return Store.Counts(ctx)
 }
 
 // ========================================
@@ -114,11 +333,15 @@ With worker pool:
   User gets response in 5ms. Email sends behind the scenes.
 
 HOW:
-- Job struct: ticket with Type (what to do) and Payload (data needed)
-- JobQueue: buffered channel (size 100) — the handoff point between HTTP goroutines and workers
-- StartWorkerPool(n): spins up n goroutines, each running `for job := range JobQueue`
-- range on a channel BLOCKS when empty — worker sleeps with zero CPU until a job arrives
-- AddJob: non-blocking send using select+default — if queue full, drop job and log warning
+- Job struct: ticket with Type (what to do) and Payload (data needed, stored as JSON)
+- Register: one handler per Type with a typed payload, concurrency limit, timeout and retries (registry.go)
+- Store: the jobs table (db/jobs.go) — the handoff point between HTTP goroutines and workers
+- WorkerPool.Start: spins up n goroutines, each claiming the oldest due job in a loop
+- WorkerPool.Shutdown: stop claiming, wait for running jobs (sync.WaitGroup) until the deadline
+- Nothing due: the worker sleeps until AddJob wakes it (wake channel) or a 1s poll
+- AddJob: one INSERT — the job is safe on disk before the HTTP response goes out
+- AddJobAt: the same INSERT with a later run_at — a delayed job is just one that isn't due yet
+- Scheduler: turns cron expressions into AddJobAt calls, one server at a time (scheduler.go)
 
 WHY CAP THE WORKERS:
 HTTP goroutines are short-lived (milliseconds) — Go handles thousands fine.
@@ -126,9 +349,18 @@ Worker goroutines doing outbound calls hold connections open for seconds.
 10,000 simultaneous email sends = 10,000 connections to email server → crashes it.
 The cap protects downstream services, not Go itself.
 
-WHY NON-BLOCKING AddJob:
-If send blocked on full queue, the HTTP handler would hang waiting for space.
-That defeats the purpose. Better to drop a background job than block a user request.
+WHY A TABLE AND NOT JUST THE CHANNEL:
+The channel held 100 jobs in memory: a full queue dropped jobs, a restart lost
+all of them (a password reset mail that never arrives). A row survives both.
+A claimed job has a visibility timeout: if its worker dies, the job becomes
+due again and another worker (maybe on another server) runs it.
+
+WHY THE DATABASE AND NOT REDIS:
+The queue (db/jobs.go) and the scheduler's lease (db/schedules.go) are both
+tables. The server can't run without its database, but it runs on without
+Redis (circuit breaker, see the cache package). A queue in Redis would lose
+jobs, and a lease in Redis (SET NX with a TTL would do) would stop every
+schedule, exactly when Redis is down.
 
 CACHE INVALIDATION ON WRITE:
 CreateEntry deletes the cached GET /entries result after insert.
@@ -137,19 +369,82 @@ Next GET will miss cache, fetch fresh from DB, re-cache updated list.
 Pattern: every write must invalidate related cache keys.
 
 TRADE-OFFS:
-- Jobs dropped when queue full — acceptable for non-critical background work
-- No retry on failed jobs — production would use a persistent queue (Redis, RabbitMQ)
-- No graceful drain on shutdown — production would drain queue before exiting
+- At-least-once: a job can run twice (worker dies after the work, before reporting) — jobs must be safe to repeat
+- One INSERT per AddJob and a poll per idle worker — fine at this scale, a broker (RabbitMQ) scales further
+- Failed jobs (after MaxAttempts) go to a dead-letter queue with every error — an admin replays or discards them
+- Shutdown waits only until SHUTDOWN_TIMEOUT — a job cut off mid-run is picked up again after the visibility timeout
 */
 
 // AddJob adds a new job to the queue
 // This is what handlers call to schedule background work
-func AddJob(jobType string, payload interface{}) {
-	// Non-blocking send (if queue is full, log warning)
+// jobType must be registered (Register) and payload must have its payload type:
+// the worker gets it back decoded from the jobs table.
+// Errors are logged here already; callers check them when they can do something
+// about it (tell the user their mail isn't coming).
+func AddJob(jobType string, payload interface{}) error {
+	return AddJobAt(time.Now(), jobType, payload)
+}
+
+// AddJobAt is AddJob for a job that must not run before runAt ("remind me in
+// an hour"). The job waits in the table like a retry does - no goroutine, no
+// timer - so it survives restarts and any server may run it.
+func AddJobAt(runAt time.Time, jobType string, payload interface{}) error {
+	h := lookupHandler(jobType)
+	if h == nil {
+		// A typo or a forgotten Register - found now, not when a worker fails the job
+		slog.Error("Unknown job type, dropping job", "job_type", jobType)
+		return fmt.Errorf("%w %q", ErrUnknownJobType, jobType)
+	}
+	if !h.accepts(payload) {
+		slog.Error("Wrong job payload type, dropping job", "job_type", jobType,
+			"payload_type", fmt.Sprintf("%T", payload), "want", h.payloadType.String())
+		return fmt.Errorf("job type %q: payload is %T, want %s", jobType, payload, h.payloadType)
+	}
+
+	if Store == nil {
+		slog.Warn("Worker pool not started, dropping job", "job_type", jobType)
+		return errors.New("worker pool not started")
+	}
+	if p := pool.Load(); p != nil && p.closing.Load() {
+		p.rejected.Add(1)
+		slog.Warn("Worker pool shutting down, dropping job", "job_type", jobType)
+		return errors.New("worker pool shutting down")
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Job payload is not JSON, dropping job", "job_type", jobType, "error", err)
+		return err
+	}
+
+	// Don't let a slow database hold up the HTTP response for long
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// run_at is stored to the second: round a later time up, or the job could run up to 1s early
+	if rounded := runAt.Truncate(time.Second); rounded.Before(runAt) && runAt.After(time.Now()) {
+		runAt = rounded.Add(time.Second)
+	}
+	id, err := Store.Enqueue(ctx, jobType, data, runAt, h.maxAttempts())
+	if err != nil {
+		slog.Error("Failed to save job, dropping it", "job_type", jobType, "error", err)
+		return err
+	}
+
+	if delay := time.Until(runAt); delay > 0 {
+		// No worker to wake: the poll finds it once it is due
+		slog.Info("Job added to queue", "job_type", jobType, "job_id", id, "run_in_seconds", delay.Round(time.Second).Seconds())
+		return nil
+	}
+	slog.Info("Job added to queue", "job_type", jobType, "job_id", id)
+	wakeWorker()
+	return nil
+}
+
+// wakeWorker wakes an idle worker (non-blocking: if a wake-up is already pending, one is enough)
+func wakeWorker() {
 	select {
-	case JobQueue <- Job{Type: jobType, Payload: payload}:
-		slog.Info("Job added to queue", "job_type", jobType)
+	case wake <- struct{}{}:
 	default:
-		slog.Warn("Job queue full, dropping job", "job_type", jobType)
 	}
 }
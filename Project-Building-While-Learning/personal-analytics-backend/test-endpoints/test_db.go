@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"personal-analytics-backend/internal/db"
@@ -10,54 +11,57 @@ func main() {
 	fmt.Println("=== DATABASE TEST ===\n")
 
 	// Initialize database
-	err := db.InitDB("./test_data.db")
+	conn, err := db.InitDB(db.DriverSQLite, "./test_data.db")
 	if err != nil {
 		log.Fatalf("Failed to init DB: %v", err)
 	}
-	defer db.CloseDB()
+	defer db.CloseDB(conn)
+
+	ctx := context.Background()
+	entries := db.NewSQLEntryRepository(conn)
 
 	// Insert some test entries
 	fmt.Println("📝 Inserting test entries...")
 
-	id1, err := db.InsertEntry(101, "Feeling great today! Got a lot done.", 5)
+	id1, err := entries.Create(ctx, 101, db.EntryInput{Text: "Feeling great today! Got a lot done.", Mood: 5, Category: "personal"})
 	if err != nil {
 		log.Printf("Error inserting entry 1: %v", err)
 	} else {
 		fmt.Printf("✅ Inserted entry with ID: %d\n", id1)
 	}
 
-	id2, err := db.InsertEntry(101, "Bit stressed with work deadlines", 3)
+	id2, err := entries.Create(ctx, 101, db.EntryInput{Text: "Bit stressed with work deadlines", Mood: 3, Category: "work"})
 	if err != nil {
 		log.Printf("Error inserting entry 2: %v", err)
 	} else {
 		fmt.Printf("✅ Inserted entry with ID: %d\n", id2)
 	}
 
-	id3, err := db.InsertEntry(102, "Had a productive coding session", 4)
+	id3, err := entries.Create(ctx, 102, db.EntryInput{Text: "Had a productive coding session", Mood: 4, Category: "work"})
 	if err != nil {
 		log.Printf("Error inserting entry 3: %v", err)
 	} else {
 		fmt.Printf("✅ Inserted entry with ID: %d\n", id3)
 	}
 
-	// Read all entries
-	fmt.Println("\n📖 Reading all entries from database...")
-	entries, err := db.GetAllEntries()
+	// Read the entries of user 101
+	fmt.Println("\n📖 Reading entries of user 101 from database...")
+	list, total, err := entries.List(ctx, 101, db.EntryFilter{}, 1, 100)
 	if err != nil {
 		log.Fatalf("Error reading entries: %v", err)
 	}
 
-	if len(entries) == 0 {
+	if len(list) == 0 {
 		fmt.Println("No entries found")
 	} else {
-		fmt.Printf("Found %d entries:\n\n", len(entries))
-		for i, entry := range entries {
+		fmt.Printf("Found %d entries:\n\n", total)
+		for i, entry := range list {
 			fmt.Printf("Entry #%d:\n", i+1)
-			fmt.Printf("  ID: %v\n", entry["id"])
-			fmt.Printf("  User ID: %v\n", entry["user_id"])
-			fmt.Printf("  Text: %v\n", entry["text"])
-			fmt.Printf("  Mood: %v\n", entry["mood"])
-			fmt.Printf("  Created: %v\n", entry["created_at"])
+			fmt.Printf("  ID: %v\n", entry.ID)
+			fmt.Printf("  User ID: %v\n", entry.UserID)
+			fmt.Printf("  Text: %v\n", entry.Text)
+			fmt.Printf("  Mood: %v\n", entry.Mood)
+			fmt.Printf("  Created: %v\n", entry.CreatedAt)
 			fmt.Println()
 		}
 	}